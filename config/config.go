@@ -6,9 +6,13 @@ package config
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 	
@@ -27,6 +31,7 @@ type AppConfig struct {
 	HotReload            HotReload            `yaml:"hot_reload"`
 	CsrfEnabled          bool                 `yaml:"csrf_enabled"`
 	Database             Database             `yaml:"database"`
+	TaskRetry            TaskRetry            `yaml:"task_retry"`
 	ThreadpoolMonitoring ThreadpoolMonitoring `yaml:"threadpool_monitoring"`
 	Sandbox              Sandbox              `yaml:"sandbox"`
 	Monitoring           Monitoring           `yaml:"monitoring"`
@@ -108,12 +113,15 @@ type HotReload struct {
 }
 
 type Threadpool struct {
-	MaxWorkers  int    `yaml:"max_workers"`
-	MaxQueue    int    `yaml:"max_queue"`
-	TaskTimeout string `yaml:"task_timeout"`
-	AutoScale   bool   `yaml:"auto_scale"`
-	MinWorkers  int    `yaml:"min_workers"`
-	MemoryLimit string `yaml:"memory_limit"`
+	MaxWorkers   int    `yaml:"max_workers"`
+	MaxQueue     int    `yaml:"max_queue"`
+	TaskTimeout  string `yaml:"task_timeout"`
+	AutoScale    bool   `yaml:"auto_scale"`
+	MinWorkers   int    `yaml:"min_workers"`
+	MemoryLimit  string `yaml:"memory_limit"`
+	ScaleUpWait  string `yaml:"scale_up_wait"`
+	ScaleUpQueue int    `yaml:"scale_up_queue"`
+	IdleTimeout  string `yaml:"idle_timeout"`
 }
 
 type ThreadpoolMonitoring struct {
@@ -137,26 +145,92 @@ type Sandbox struct {
 	Isolation        Isolation      `yaml:"isolation"`
 	ResourceLimits   ResourceLimits `yaml:"resource_limits"`
 	ExecutionTimeout string         `yaml:"execution_timeout"`
+	KillGracePeriod  string         `yaml:"kill_grace_period"`
+	Seccomp          Seccomp        `yaml:"seccomp"`
 	Environments     Environments   `yaml:"environments"`
 	Execution        Execution      `yaml:"execution"`
 }
 
 type Environments struct {
-	BasePath string `yaml:"base_path"`
-	Python   Python `yaml:"python"`
-	Java     Java   `yaml:"java"`
+	BasePath string                `yaml:"base_path"`
+	Python   Python                `yaml:"python"`
+	Java     Java                  `yaml:"java"`
+	Runtimes map[string]RuntimeEnv `yaml:"runtimes"`
+	GC       EnvironmentGC         `yaml:"gc"`
+}
+
+// EnvironmentGC 运行环境缓存回收配置
+type EnvironmentGC struct {
+	Enabled         bool   `yaml:"enabled"`
+	Interval        string `yaml:"interval"`
+	VenvMaxIdleDays int    `yaml:"venv_max_idle_days"`
+}
+
+type RuntimeEnv struct {
+	BasePath       string `yaml:"base_path"`
+	InstallersPath string `yaml:"installers_path"`
+	VersionsPath   string `yaml:"versions_path"`
+	SeccompProfile string `yaml:"seccomp_profile"`
+	DefaultVersion string `yaml:"default_version"`
+}
+
+// Runtime 返回指定运行时的配置
+// python/java 使用各自的配置段，其他运行时使用 runtimes 下的同名配置段，
+// 未配置的路径按 base_path/<name>、base_path/<name>/installers、base_path/<name>/versions 生成
+func (e Environments) Runtime(name string) RuntimeEnv {
+	var env RuntimeEnv
+	switch name {
+	case "python":
+		env = RuntimeEnv{e.Python.BasePath, e.Python.InstallersPath, e.Python.VersionsPath, e.Python.SeccompProfile, e.Python.DefaultVersion}
+	case "java":
+		env = RuntimeEnv{e.Java.BasePath, e.Java.InstallersPath, e.Java.VersionsPath, e.Java.SeccompProfile, e.Java.DefaultVersion}
+	default:
+		env = e.Runtimes[name]
+	}
+	if env.BasePath == "" {
+		env.BasePath = filepath.Join(e.BasePath, name)
+	}
+	if env.InstallersPath == "" {
+		env.InstallersPath = filepath.Join(env.BasePath, "installers")
+	}
+	if env.VersionsPath == "" {
+		env.VersionsPath = filepath.Join(env.BasePath, "versions")
+	}
+	return env
+}
+
+// RuntimeNames 返回配置了独立配置段的运行时名称
+func (e Environments) RuntimeNames() []string {
+	names := []string{"python", "java"}
+	var others []string
+	for name := range e.Runtimes {
+		if name != "python" && name != "java" {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	return append(names, others...)
 }
 
 type Python struct {
 	BasePath       string `yaml:"base_path"`
 	InstallersPath string `yaml:"installers_path"`
 	VersionsPath   string `yaml:"versions_path"`
+	VenvsPath      string `yaml:"venvs_path"`
+	SeccompProfile string `yaml:"seccomp_profile"`
+	DefaultVersion string `yaml:"default_version"`
 }
 
 type Java struct {
 	BasePath       string `yaml:"base_path"`
 	InstallersPath string `yaml:"installers_path"`
 	VersionsPath   string `yaml:"versions_path"`
+	SeccompProfile string `yaml:"seccomp_profile"`
+	DefaultVersion string `yaml:"default_version"`
+}
+
+type Seccomp struct {
+	Profile string `yaml:"profile"`
 }
 
 type Execution struct {
@@ -167,14 +241,19 @@ type Execution struct {
 }
 
 type Isolation struct {
-	Chroot bool   `yaml:"chroot"`
-	User   string `yaml:"user"`
-	Group  string `yaml:"group"`
+	Mode          string   `yaml:"mode"`
+	TmpSize       string   `yaml:"tmp_size"`
+	Chroot        bool     `yaml:"chroot"`
+	User          string   `yaml:"user"`
+	Group         string   `yaml:"group"`
+	ReadonlyPaths []string `yaml:"readonly_paths"`
 }
 
 type ResourceLimits struct {
-	Memory   string `yaml:"memory"`
-	CpuCores int    `yaml:"cpu_cores"`
+	Memory     string `yaml:"memory"`
+	CpuCores   int    `yaml:"cpu_cores"`
+	MaxPids    int    `yaml:"max_pids"`
+	CgroupRoot string `yaml:"cgroup_root"`
 }
 
 type Monitoring struct {
@@ -262,9 +341,31 @@ type BasePaths struct {
 }
 
 type Database struct {
+	// Connection SQLite 数据库文件路径，":memory:" 表示内存数据库
 	Connection string `yaml:"connection"`
+	// MaxOpenConns 最大打开连接数
+	MaxOpenConns int `yaml:"max_open_conns"`
+	// BusyTimeout 数据库被其他连接锁定时的等待时间（Go Duration格式）
+	BusyTimeout string `yaml:"busy_timeout"`
 }
 
+// TaskRetry 任务失败后的默认重试策略，提交任务时可逐项覆盖
+type TaskRetry struct {
+	// MaxAttempts 最多执行次数（含第一次），0 或 1 表示不重试
+	MaxAttempts int `yaml:"max_attempts"`
+	// BackoffBase 第一次重试前的等待时间，之后每次翻倍（Go Duration格式）
+	BackoffBase string `yaml:"backoff_base"`
+	// BackoffMax 重试等待时间的上限（Go Duration格式）
+	BackoffMax string `yaml:"backoff_max"`
+	// Jitter 等待时间的随机浮动比例，0~1
+	Jitter float64 `yaml:"jitter"`
+	// RetryOn 可重试的失败原因：timeout/out_of_memory/non_zero_exit/seccomp_violation/error
+	RetryOn []string `yaml:"retry_on"`
+}
+
+// RetryReasons 可以配置为重试条件的失败原因，取消和服务重启中断的任务不会重试
+var RetryReasons = []string{"timeout", "out_of_memory", "non_zero_exit", "seccomp_violation", "error"}
+
 type Logging struct {
 	RotateSize  int `yaml:"rotate_size"`
 	RotateCount int `yaml:"rotate_count"`
@@ -279,19 +380,7 @@ func (c *AppConfig) LoadConfig(configPath string) error {
 		return fmt.Errorf("加载配置失败: %v", err)
 	}
 
-	snapshot := &ConfigSnapshot{
-		MonitorInterval: newCfg.MonitorInterval,
-		LoggerLevels:    newCfg.LoggerConf.Levels,
-		MaxWorkers:      newCfg.Threadpool.MaxWorkers,
-		MaxQueue:        newCfg.Threadpool.MaxQueue,
-		MemoryLimit:     newCfg.Threadpool.MemoryLimit,
-		TaskTimeout:     newCfg.Threadpool.TaskTimeout,
-		Port:            newCfg.App.Port,
-		BaseURL:         newCfg.App.BaseURL,
-		StoragePath:     newCfg.File.StoragePath,
-		MaxUploadSize:   newCfg.File.MaxUploadSize,
-		AllowedPaths:    newCfg.HotReload.AllowedPaths,
-	}
+	snapshot := newSnapshot(newCfg)
 	c.current = snapshot
 	c.previous = snapshot
 	c.initialized = true
@@ -308,25 +397,55 @@ func (c *AppConfig) ReloadConfig(configPath string) error {
 		return fmt.Errorf("加载新配置失败: %v", err)
 	}
 
-	newSnapshot := &ConfigSnapshot{
-		MonitorInterval: newCfg.MonitorInterval,
-		LoggerLevels:    newCfg.LoggerConf.Levels,
-		MaxWorkers:      newCfg.Threadpool.MaxWorkers,
-		MaxQueue:        newCfg.Threadpool.MaxQueue,
-		MemoryLimit:     newCfg.Threadpool.MemoryLimit,
-		TaskTimeout:     newCfg.Threadpool.TaskTimeout,
-		Port:            newCfg.App.Port,
-		BaseURL:         newCfg.App.BaseURL,
-		StoragePath:     newCfg.File.StoragePath,
-		MaxUploadSize:   newCfg.File.MaxUploadSize,
-		AllowedPaths:    newCfg.HotReload.AllowedPaths,
-	}
-
 	c.previous = c.current
-	c.current = newSnapshot
+	c.current = newSnapshot(newCfg)
 	return nil
 }
 
+// recordSnapshot 按当前配置记录一次配置变更，追加到History
+func (c *AppConfig) recordSnapshot() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snapshot := newSnapshot(c)
+	c.previous = c.current
+	c.current = snapshot
+	c.History = append(c.History, snapshot)
+}
+
+// Update 在配置锁内修改配置，热更新处理函数修改运行中的配置时必须使用
+func (c *AppConfig) Update(fn func(cfg *AppConfig)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fn(c)
+}
+
+// Snapshot 在配置锁内读取支持热更新的配置项，其他协程读取这些配置项时必须使用
+func (c *AppConfig) Snapshot() *ConfigSnapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return newSnapshot(c)
+}
+
+// newSnapshot 根据配置生成快照
+func newSnapshot(cfg *AppConfig) *ConfigSnapshot {
+	return &ConfigSnapshot{
+		MonitorInterval: cfg.MonitorInterval,
+		LoggerLevels:    cfg.LoggerConf.Levels,
+		MaxWorkers:      cfg.Threadpool.MaxWorkers,
+		MaxQueue:        cfg.Threadpool.MaxQueue,
+		MemoryLimit:     cfg.Threadpool.MemoryLimit,
+		TaskTimeout:     cfg.Threadpool.TaskTimeout,
+		Port:            cfg.App.Port,
+		BaseURL:         cfg.App.BaseURL,
+		StoragePath:     cfg.File.StoragePath,
+		MaxUploadSize:   cfg.File.MaxUploadSize,
+		AllowedPaths:    cfg.HotReload.AllowedPaths,
+	}
+}
+
 func (c *AppConfig) loadAndValidateConfig(configPath string) (*AppConfig, error) {
 	content, err := os.ReadFile(configPath)
 	if err != nil {
@@ -376,6 +495,25 @@ func (c *AppConfig) validate() error {
 	if !isValidSize(c.Threadpool.MemoryLimit) {
 		return fmt.Errorf("线程池内存限制 %q 格式不合法", c.Threadpool.MemoryLimit)
 	}
+	if c.Threadpool.AutoScale {
+		if c.Threadpool.MinWorkers < 1 || c.Threadpool.MinWorkers > c.Threadpool.MaxWorkers {
+			return fmt.Errorf("线程池最小工作线程数 %d 不合法，应在 1 到 %d 之间", c.Threadpool.MinWorkers, c.Threadpool.MaxWorkers)
+		}
+		if c.Threadpool.ScaleUpQueue < 0 {
+			return fmt.Errorf("扩容队列深度阈值 %d 不合法", c.Threadpool.ScaleUpQueue)
+		}
+		for name, value := range map[string]string{
+			"scale_up_wait": c.Threadpool.ScaleUpWait,
+			"idle_timeout":  c.Threadpool.IdleTimeout,
+		} {
+			if value == "" {
+				continue
+			}
+			if _, err := time.ParseDuration(value); err != nil {
+				return fmt.Errorf("线程池 %s %q 格式不合法: %v", name, value, err)
+			}
+		}
+	}
 
 	// File验证
 	if c.File.MaxUploadSize <= 0 {
@@ -389,6 +527,85 @@ func (c *AppConfig) validate() error {
 	if c.Sandbox.ResourceLimits.CpuCores < 1 {
 		return fmt.Errorf("CPU核心数 %d 不合法", c.Sandbox.ResourceLimits.CpuCores)
 	}
+	switch c.Sandbox.Isolation.Mode {
+	case "", "none", "chroot", "namespace":
+	default:
+		return fmt.Errorf("沙箱隔离模式 %q 不合法，应为 none/chroot/namespace", c.Sandbox.Isolation.Mode)
+	}
+	if size := c.Sandbox.Isolation.TmpSize; size != "" && !isValidSize(size) {
+		return fmt.Errorf("沙箱临时目录大小 %q 格式不合法", size)
+	}
+	if c.Sandbox.ResourceLimits.MaxPids < 0 {
+		return fmt.Errorf("沙箱最大进程数 %d 不合法", c.Sandbox.ResourceLimits.MaxPids)
+	}
+	profiles := map[string]string{"seccomp.profile": c.Sandbox.Seccomp.Profile}
+	for _, name := range c.Sandbox.Environments.RuntimeNames() {
+		key := "environments.runtimes." + name + ".seccomp_profile"
+		if name == "python" || name == "java" {
+			key = "environments." + name + ".seccomp_profile"
+		}
+		profiles[key] = c.Sandbox.Environments.Runtime(name).SeccompProfile
+	}
+	for name, profile := range profiles {
+		switch profile {
+		case "", "none", "strict", "default", "permissive":
+		default:
+			return fmt.Errorf("沙箱 %s %q 不合法，应为 none/strict/default/permissive", name, profile)
+		}
+	}
+	for name, value := range map[string]string{
+		"execution_timeout": c.Sandbox.ExecutionTimeout,
+		"kill_grace_period": c.Sandbox.KillGracePeriod,
+		"environments.gc.interval": c.Sandbox.Environments.GC.Interval,
+	} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("沙箱 %s %q 格式不合法: %v", name, value, err)
+		}
+	}
+	if c.Sandbox.Environments.GC.VenvMaxIdleDays < 0 {
+		return fmt.Errorf("虚拟环境最长闲置天数 %d 不合法", c.Sandbox.Environments.GC.VenvMaxIdleDays)
+	}
+
+	// Database验证
+	if c.Database.MaxOpenConns < 0 {
+		return fmt.Errorf("数据库最大连接数 %d 不合法", c.Database.MaxOpenConns)
+	}
+	if timeout := c.Database.BusyTimeout; timeout != "" {
+		if _, err := time.ParseDuration(timeout); err != nil {
+			return fmt.Errorf("数据库 busy_timeout %q 格式不合法: %v", timeout, err)
+		}
+	}
+
+	// TaskRetry验证
+	if c.TaskRetry.MaxAttempts < 0 {
+		return fmt.Errorf("任务最多执行次数 %d 不合法", c.TaskRetry.MaxAttempts)
+	}
+	if c.TaskRetry.Jitter < 0 || c.TaskRetry.Jitter > 1 {
+		return fmt.Errorf("任务重试等待时间浮动比例 %v 不合法，应在 0 到 1 之间", c.TaskRetry.Jitter)
+	}
+	for name, value := range map[string]string{
+		"backoff_base": c.TaskRetry.BackoffBase,
+		"backoff_max":  c.TaskRetry.BackoffMax,
+	} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("任务重试 %s %q 格式不合法: %v", name, value, err)
+		}
+	}
+	for _, reason := range c.TaskRetry.RetryOn {
+		valid := false
+		for _, allowed := range RetryReasons {
+			valid = valid || reason == allowed
+		}
+		if !valid {
+			return fmt.Errorf("任务重试条件 %q 不合法，应为 %s", reason, strings.Join(RetryReasons, "/"))
+		}
+	}
 
 	// Monitoring验证
 	if _, err := time.ParseDuration(c.Monitoring.HealthCheck.Interval); err != nil {
@@ -440,6 +657,9 @@ func InitConfig(configPath string) error {
 	if GlobalConfig != nil {
 		return fmt.Errorf("global config already initialized")
 	}
+	newCfg.current = newSnapshot(newCfg)
+	newCfg.History = append(newCfg.History, newCfg.current)
+	newCfg.initialized = true
 	GlobalConfig = newCfg
 	return nil
 }
@@ -449,6 +669,23 @@ func isValidSize(sizeStr string) bool {
 	return matched
 }
 
+// ParseSize 将大小字符串（如 50GB、512MB、1024KB）解析为字节数，按 1024 进制换算
+func ParseSize(sizeStr string) (int64, error) {
+	matches := regexp.MustCompile(`^(\d+)([kKmMgGtTpPeE]?)[bB]$`).FindStringSubmatch(sizeStr)
+	if matches == nil {
+		return 0, fmt.Errorf("大小 %q 格式不合法", sizeStr)
+	}
+	value, err := strconv.ParseInt(matches[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("大小 %q 格式不合法: %v", sizeStr, err)
+	}
+	shift := map[string]uint{"": 0, "k": 10, "m": 20, "g": 30, "t": 40, "p": 50, "e": 60}[strings.ToLower(matches[2])]
+	if shift > 0 && value > math.MaxInt64>>shift {
+		return 0, fmt.Errorf("大小 %q 超出范围", sizeStr)
+	}
+	return value << shift, nil
+}
+
 func GetConfig() *AppConfig {
 	return GlobalConfig
 }
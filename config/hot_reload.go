@@ -6,6 +6,9 @@ package config
 
 import (
 	"fmt"
+	"os"
+	"sort"
+	"strconv"
 	"time"
 )
 
@@ -27,40 +30,114 @@ func InitConfigHandlers() error {
 		if err != nil {
 			return err
 		}
-		// 直接使用全局配置的最新值
-		GlobalConfig.MonitorInterval = newInterval.String()
+		if newInterval <= 0 {
+			return fmt.Errorf("监控间隔 %q 不合法", value)
+		}
+		// 保存配置文件中的原值，重新格式化（如 1m 变为 1m0s）会让下次重载误判为变化
+		GlobalConfig.Update(func(cfg *AppConfig) {
+			cfg.MonitorInterval = value
+		})
 		return nil
 	})
 	return nil
 }
 
 // ReloadConfig 热重载配置核心实现
+// 只有 hot_reload.allowed_paths 中列出且值发生变化的配置项会触发处理函数，
+// 处理函数负责把新值应用到运行中的模块，并通过 GlobalConfig.Update 写回全局配置；变更记录追加到 GlobalConfig.History
 // 参数：configPath 配置文件路径
 // 返回：错误信息
 func ReloadConfig(configPath string) error {
+	if GlobalConfig == nil {
+		return fmt.Errorf("全局配置尚未初始化")
+	}
+
 	// 加载新配置
-	newCfg := &AppConfig{}
-	err := newCfg.LoadConfig(configPath)
+	newCfg, err := loadAndValidateConfig(configPath)
 	if err != nil {
 		return err
 	}
+
 	// 收集需要触发的配置项值
-	values := make(map[string]string)
-	values["monitor_interval"] = newCfg.MonitorInterval
+	GlobalConfig.mu.Lock()
+	oldValues := hotReloadValues(GlobalConfig)
+	GlobalConfig.mu.Unlock()
+	values := hotReloadValues(newCfg)
 
-	// 原子替换全局配置
-	GlobalConfig = newCfg
+	// 按配置路径顺序触发注册的处理函数，保证每次重载的应用顺序一致
+	paths := make([]string, 0, len(ConfigHandlers))
+	for path := range ConfigHandlers {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
 
-	// 触发所有注册的处理函数
-	for path, handler := range ConfigHandlers {
+	changed := false
+	for _, path := range paths {
 		value, ok := values[path]
-		if !ok {
-			continue // 忽略未收集的配置项
+		if !ok || value == oldValues[path] {
+			continue // 忽略未收集或未变化的配置项
 		}
-		if err := handler(value); err != nil {
+		if !newCfg.AllowPath(path) {
+			continue // 未允许热更新的配置项需要重启生效
+		}
+		if err := ConfigHandlers[path](value); err != nil {
+			// 之前的配置项已经生效，先记录变更，保证 History 与运行中的配置一致
+			if changed {
+				GlobalConfig.recordSnapshot()
+			}
 			return fmt.Errorf("处理配置项 %s 失败: %v", path, err)
 		}
+		changed = true
 	}
 
+	if changed {
+		GlobalConfig.recordSnapshot()
+	}
 	return nil
 }
+
+// hotReloadValues 收集支持热更新的配置项的当前值
+func hotReloadValues(cfg *AppConfig) map[string]string {
+	return map[string]string{
+		"monitor_interval":        cfg.MonitorInterval,
+		"threadpool.max_workers":  strconv.Itoa(cfg.Threadpool.MaxWorkers),
+		"threadpool.max_queue":    strconv.Itoa(cfg.Threadpool.MaxQueue),
+		"threadpool.task_timeout": cfg.Threadpool.TaskTimeout,
+	}
+}
+
+// WatchConfig 监听配置文件变化并自动热重载
+// 按 interval 轮询文件修改时间，直到 stop 被关闭
+// 参数：configPath 配置文件路径, interval 轮询间隔, stop 停止信号, onError 重载失败回调
+// 返回：无
+func WatchConfig(configPath string, interval time.Duration, stop <-chan struct{}, onError func(error)) {
+	var lastMod time.Time
+	if info, err := os.Stat(configPath); err == nil {
+		lastMod = info.ModTime()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			info, err := os.Stat(configPath)
+			if err != nil {
+				if onError != nil {
+					onError(err)
+				}
+				continue
+			}
+			if !info.ModTime().After(lastMod) {
+				continue
+			}
+			lastMod = info.ModTime()
+			if err := ReloadConfig(configPath); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
@@ -2,50 +2,104 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"file-flow-service/config"
+	"file-flow-service/utils/logger"
+
 	_ "github.com/mattn/go-sqlite3"
+	"go.uber.org/zap"
 )
 
-type ConnectionPool struct {
-	maxConnections int
-	connections    chan *sql.DB
-}
-
-var (
-	db     *sql.DB
-	pool   *ConnectionPool
+const (
+	// defaultConnection 未配置 database.connection 时使用的数据库文件
+	defaultConnection = "database/database.db"
+	// defaultMaxOpenConns 未配置 database.max_open_conns 时的最大连接数
+	// SQLite 同一时刻只允许一个写入，WAL 模式下读取不阻塞写入，少量连接即可
+	defaultMaxOpenConns = 4
+	// defaultBusyTimeout 未配置 database.busy_timeout 时等待写锁的时间
+	defaultBusyTimeout = 5 * time.Second
 )
 
-func NewPool(maxConnections int) (*ConnectionPool, error) {
-	pool := &ConnectionPool{
-		maxConnections: maxConnections,
-		connections:    make(chan *sql.DB, maxConnections),
+// Open 按 database 配置打开数据库并将表结构升级到最新版本
+// 整个进程共用返回的连接：开启 WAL 日志、外键约束，并设置写锁等待时间；
+// connection 为 ":memory:" 时使用内存数据库，只保留一个连接以免每个连接各自是一个空库
+// 参数: config 数据库配置, logger 日志对象
+// 返回: 数据库连接，错误信息
+func Open(config config.Database, logger logger.Logger) (*sql.DB, error) {
+	connection := config.Connection
+	if connection == "" {
+		connection = defaultConnection
 	}
-
-	for i := 0; i < maxConnections; i++ {
-		db, err := sql.Open("sqlite3", "./database.db")
+	busyTimeout := defaultBusyTimeout
+	if config.BusyTimeout != "" {
+		parsed, err := time.ParseDuration(config.BusyTimeout)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("数据库 busy_timeout %q 格式不合法: %v", config.BusyTimeout, err)
 		}
-		pool.connections <- db
+		busyTimeout = parsed
+	}
+	maxOpenConns := config.MaxOpenConns
+	if maxOpenConns <= 0 {
+		maxOpenConns = defaultMaxOpenConns
 	}
 
-	return pool, nil
-}
+	memory := connection == ":memory:"
+	if !memory {
+		if err := os.MkdirAll(filepath.Dir(connection), 0755); err != nil {
+			return nil, fmt.Errorf("创建数据库目录失败: %v", err)
+		}
+	}
 
-func InitDB() error {
-	var err error
-	pool, err = NewPool(10)
+	conn, err := sql.Open("sqlite3", dataSourceName(connection, busyTimeout, !memory))
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("打开数据库失败: %v", err)
+	}
+	if memory {
+		maxOpenConns = 1
+		conn.SetConnMaxLifetime(0)
+		conn.SetConnMaxIdleTime(0)
+	}
+	conn.SetMaxOpenConns(maxOpenConns)
+	conn.SetMaxIdleConns(maxOpenConns)
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("连接数据库 %s 失败: %v", connection, err)
 	}
-	db = <-pool.connections
-	return nil
-}
 
-func GetConnection() *sql.DB {
-	return <-pool.connections
+	applied, err := Migrate(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("数据库迁移失败: %v", err)
+	}
+	for _, migration := range applied {
+		logger.Info("已执行数据库迁移", zap.Int("version", migration.Version), zap.String("name", migration.Name))
+	}
+	logger.Info("数据库已就绪", zap.String("connection", connection), zap.Int("max_open_conns", maxOpenConns))
+	return conn, nil
 }
 
-func ReleaseConnection(dbConn *sql.DB) {
-	pool.connections <- dbConn
-}
\ No newline at end of file
+// dataSourceName 构造 go-sqlite3 的连接串，连接参数对连接池中的每个连接生效
+func dataSourceName(connection string, busyTimeout time.Duration, wal bool) string {
+	params := url.Values{}
+	params.Set("_busy_timeout", fmt.Sprint(busyTimeout.Milliseconds()))
+	params.Set("_foreign_keys", "on")
+	if wal {
+		params.Set("_journal_mode", "WAL")
+		// WAL 模式下 NORMAL 同步级别已能保证崩溃后数据库一致
+		params.Set("_synchronous", "NORMAL")
+	}
+	if !strings.HasPrefix(connection, "file:") {
+		connection = "file:" + connection
+	}
+	separator := "?"
+	if strings.Contains(connection, "?") {
+		separator = "&"
+	}
+	return connection + separator + params.Encode()
+}
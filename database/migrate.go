@@ -0,0 +1,127 @@
+package database
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles 编译进程序的数据库迁移脚本，文件名格式为 <版本号>_<说明>.sql
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration 一个数据库迁移脚本
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// Migrations 按版本号升序返回全部迁移脚本
+func Migrations() ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, fmt.Errorf("读取迁移脚本失败: %v", err)
+	}
+
+	var migrations []Migration
+	seen := make(map[int]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		prefix, _, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("迁移脚本 %s 的文件名不合法，应为 <版本号>_<说明>.sql", name)
+		}
+		if other, exists := seen[version]; exists {
+			return nil, fmt.Errorf("迁移脚本 %s 与 %s 的版本号重复", name, other)
+		}
+		seen[version] = name
+
+		content, err := migrationFiles.ReadFile(path.Join("migrations", name))
+		if err != nil {
+			return nil, fmt.Errorf("读取迁移脚本 %s 失败: %v", name, err)
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(content)})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Migrate 将数据库升级到最新版本
+// 已执行的版本记录在 schema_migrations 表中，每个迁移脚本及其记录在同一个事务中执行，
+// 失败时回滚，数据库停留在上一个版本
+// 参数: conn 数据库连接
+// 返回: 本次执行的迁移脚本，错误信息
+func Migrate(conn *sql.DB) ([]Migration, error) {
+	if _, err := conn.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			appliedAt TIMESTAMP NOT NULL
+		)`); err != nil {
+		return nil, fmt.Errorf("创建迁移记录表失败: %v", err)
+	}
+
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	current, err := SchemaVersion(conn)
+	if err != nil {
+		return nil, err
+	}
+	if latest := migrations[len(migrations)-1].Version; current > latest {
+		return nil, fmt.Errorf("数据库版本 %d 高于程序支持的版本 %d，请升级程序", current, latest)
+	}
+
+	var applied []Migration
+	for _, migration := range migrations {
+		if migration.Version <= current {
+			continue
+		}
+		if err := applyMigration(conn, migration); err != nil {
+			return applied, err
+		}
+		applied = append(applied, migration)
+	}
+	return applied, nil
+}
+
+// SchemaVersion 查询数据库当前版本，未执行过迁移时返回 0
+func SchemaVersion(conn *sql.DB) (int, error) {
+	var version sql.NullInt64
+	if err := conn.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("查询数据库版本失败: %v", err)
+	}
+	return int(version.Int64), nil
+}
+
+// applyMigration 在事务中执行单个迁移脚本并记录版本
+func applyMigration(conn *sql.DB, migration Migration) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return fmt.Errorf("开始迁移事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(migration.SQL); err != nil {
+		return fmt.Errorf("执行迁移脚本 %s 失败: %v", migration.Name, err)
+	}
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version, name, appliedAt) VALUES (?, ?, ?)",
+		migration.Version, migration.Name, time.Now()); err != nil {
+		return fmt.Errorf("记录迁移版本 %d 失败: %v", migration.Version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交迁移脚本 %s 失败: %v", migration.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"file-flow-service/config"
+
+	"go.uber.org/zap"
+)
+
+// nopLogger 测试中丢弃全部日志
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...zap.Field)    {}
+func (nopLogger) Info(string, ...zap.Field)     {}
+func (nopLogger) Warn(string, ...zap.Field)     {}
+func (nopLogger) Error(string, ...zap.Field)    {}
+func (nopLogger) LogError(string, ...zap.Field) {}
+func (nopLogger) Fatal(string, ...zap.Field)    {}
+func (nopLogger) SetLevel(string) error         { return nil }
+
+// newTestRepository 打开已迁移到最新版本的内存数据库
+func newTestRepository(t *testing.T) *Repository {
+	t.Helper()
+	conn, err := Open(config.Database{Connection: ":memory:"}, nopLogger{})
+	if err != nil {
+		t.Fatalf("打开内存数据库失败: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return NewRepository(conn, nopLogger{})
+}
+
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("没有迁移脚本")
+	}
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Errorf("第 %d 个迁移脚本 %s 的版本号为 %d，版本号应从 1 开始连续", i+1, migration.Name, migration.Version)
+		}
+		if strings.TrimSpace(migration.SQL) == "" {
+			t.Errorf("迁移脚本 %s 为空", migration.Name)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	repo := newTestRepository(t)
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := migrations[len(migrations)-1].Version
+
+	version, err := SchemaVersion(repo.conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != latest {
+		t.Fatalf("数据库版本为 %d，应为 %d", version, latest)
+	}
+
+	// 已是最新版本时不再执行任何脚本
+	applied, err := Migrate(repo.conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 0 {
+		t.Fatalf("重复迁移执行了 %d 个脚本", len(applied))
+	}
+
+	for _, table := range []string{"tasks", "task_attempts", "task_events", "workflows", "schedules", "schedule_runs"} {
+		var name string
+		err := repo.conn.QueryRow("SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&name)
+		if err != nil {
+			t.Errorf("表 %s 不存在: %v", table, err)
+		}
+	}
+}
+
+func TestMigrateRejectsNewerSchema(t *testing.T) {
+	repo := newTestRepository(t)
+	version, err := SchemaVersion(repo.conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.conn.Exec("INSERT INTO schema_migrations (version, name, appliedAt) VALUES (?, ?, ?)",
+		version+1, "9999_future.sql", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Migrate(repo.conn); err == nil {
+		t.Fatal("数据库版本高于程序支持的版本时应返回错误")
+	}
+}
+
+func TestOpenForeignKeys(t *testing.T) {
+	repo := newTestRepository(t)
+	if err := repo.CreateTask(&Task{ID: "task_1", Name: "fk", Status: "pending"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SaveTaskEvent(&TaskEvent{TaskID: "task_1", ToStatus: "pending", Actor: "system", CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteTask("task_1"); err != nil {
+		t.Fatal(err)
+	}
+	events, err := repo.GetTaskEvents("task_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("删除任务后仍有 %d 条状态转换事件，外键级联删除未生效", len(events))
+	}
+}
+
+func TestDataSourceName(t *testing.T) {
+	tests := []struct {
+		name       string
+		connection string
+		wal        bool
+		want       []string
+		notWant    []string
+	}{
+		{
+			name:       "文件数据库",
+			connection: "data/database.db",
+			wal:        true,
+			want:       []string{"file:data/database.db?", "_busy_timeout=5000", "_foreign_keys=on", "_journal_mode=WAL", "_synchronous=NORMAL"},
+		},
+		{
+			name:       "内存数据库",
+			connection: ":memory:",
+			want:       []string{"file::memory:?", "_foreign_keys=on"},
+			notWant:    []string{"_journal_mode"},
+		},
+		{
+			name:       "已带参数的连接串",
+			connection: "file:test.db?cache=shared",
+			wal:        true,
+			want:       []string{"file:test.db?cache=shared&", "_journal_mode=WAL"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsn := dataSourceName(tt.connection, 5*time.Second, tt.wal)
+			for _, want := range tt.want {
+				if !strings.Contains(dsn, want) {
+					t.Errorf("连接串 %q 中缺少 %q", dsn, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(dsn, notWant) {
+					t.Errorf("连接串 %q 中不应包含 %q", dsn, notWant)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+-- 初始任务表，与早期 initialization.InitApp 创建的结构一致，已存在时保留原有数据
+CREATE TABLE IF NOT EXISTS tasks (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	status TEXT,
+	creator TEXT,
+	createdAt TEXT,
+	assignedTo TEXT,
+	description TEXT,
+	resultPath TEXT,
+	progress INTEGER
+);
@@ -0,0 +1,36 @@
+-- 记录任务完整生命周期：运行时、命令、退出码、错误信息和各阶段时间
+-- SQLite 不支持修改列类型，按新结构重建任务表并迁移已有数据
+CREATE TABLE tasks_new (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'queued',
+	creator TEXT NOT NULL DEFAULT '',
+	assignedTo TEXT NOT NULL DEFAULT '',
+	description TEXT NOT NULL DEFAULT '',
+	resultPath TEXT NOT NULL DEFAULT '',
+	progress INTEGER NOT NULL DEFAULT 0,
+	priority TEXT NOT NULL DEFAULT 'normal',
+	expectedMemory INTEGER NOT NULL DEFAULT 0,
+	runtime TEXT NOT NULL DEFAULT '',
+	runtimeVersion TEXT NOT NULL DEFAULT '',
+	command TEXT NOT NULL DEFAULT '',
+	args TEXT NOT NULL DEFAULT '[]',
+	exitCode INTEGER,
+	errorMessage TEXT NOT NULL DEFAULT '',
+	createdAt TIMESTAMP NOT NULL,
+	startedAt TIMESTAMP,
+	finishedAt TIMESTAMP,
+	duration INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT INTO tasks_new (id, name, status, creator, assignedTo, description, resultPath, progress, createdAt)
+SELECT id, name, COALESCE(status, 'queued'), COALESCE(creator, ''), COALESCE(assignedTo, ''),
+	COALESCE(description, ''), COALESCE(resultPath, ''), COALESCE(progress, 0),
+	COALESCE(NULLIF(createdAt, ''), CURRENT_TIMESTAMP)
+FROM tasks;
+
+DROP TABLE tasks;
+ALTER TABLE tasks_new RENAME TO tasks;
+
+CREATE INDEX idx_tasks_status ON tasks (status);
+CREATE INDEX idx_tasks_created_at ON tasks (createdAt);
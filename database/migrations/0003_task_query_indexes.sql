@@ -0,0 +1,20 @@
+-- 任务查询按时间文本排序和翻页，统一已有记录的时间格式为 UTC：
+-- 0002 迁移时保留了 RFC3339 格式的创建时间，此前写入的时间也可能带有本地时区
+UPDATE tasks SET createdAt = strftime('%Y-%m-%d %H:%M:%S', createdAt) || '+00:00'
+WHERE createdAt NOT LIKE '%+00:00' AND strftime('%s', createdAt) IS NOT NULL;
+UPDATE tasks SET startedAt = strftime('%Y-%m-%d %H:%M:%S', startedAt) || '+00:00'
+WHERE startedAt NOT LIKE '%+00:00' AND strftime('%s', startedAt) IS NOT NULL;
+UPDATE tasks SET finishedAt = strftime('%Y-%m-%d %H:%M:%S', finishedAt) || '+00:00'
+WHERE finishedAt NOT LIKE '%+00:00' AND strftime('%s', finishedAt) IS NOT NULL;
+
+-- 翻页按（排序字段, id）比较，索引包含 id 才能直接定位到游标位置
+DROP INDEX IF EXISTS idx_tasks_created_at;
+CREATE INDEX idx_tasks_created_at ON tasks (createdAt, id);
+DROP INDEX IF EXISTS idx_tasks_status;
+CREATE INDEX idx_tasks_status ON tasks (status, createdAt, id);
+CREATE INDEX idx_tasks_creator ON tasks (creator, createdAt);
+CREATE INDEX idx_tasks_assigned_to ON tasks (assignedTo, createdAt);
+CREATE INDEX idx_tasks_runtime ON tasks (runtime, createdAt);
+CREATE INDEX idx_tasks_finished_at ON tasks (finishedAt);
+CREATE INDEX idx_tasks_duration ON tasks (duration, id);
+CREATE INDEX idx_tasks_name ON tasks (name, id);
@@ -0,0 +1,9 @@
+-- 服务重启后恢复任务：记录执行参数以便重新排队，记录任务进程以便重新接管
+ALTER TABLE tasks ADD COLUMN idempotent INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE tasks ADD COLUMN memoryLimit INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE tasks ADD COLUMN cpuCores REAL NOT NULL DEFAULT 0;
+ALTER TABLE tasks ADD COLUMN maxPids INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE tasks ADD COLUMN allowNetwork INTEGER NOT NULL DEFAULT 0;
+-- pidStartTime 为进程启动时间（系统启动后的时钟滴答数），用于识别 PID 被其他进程复用
+ALTER TABLE tasks ADD COLUMN pid INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE tasks ADD COLUMN pidStartTime INTEGER NOT NULL DEFAULT 0;
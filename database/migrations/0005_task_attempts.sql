@@ -0,0 +1,18 @@
+-- 失败重试：任务记录当前尝试次数和重试策略，每次尝试单独保存一行
+ALTER TABLE tasks ADD COLUMN attempt INTEGER NOT NULL DEFAULT 0;
+-- retryPolicy 为 JSON 格式的任务重试策略，为空时使用配置中的 task_retry
+ALTER TABLE tasks ADD COLUMN retryPolicy TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE task_attempts (
+	taskId TEXT NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+	attempt INTEGER NOT NULL,
+	status TEXT NOT NULL,
+	failureReason TEXT NOT NULL DEFAULT '',
+	exitCode INTEGER,
+	errorMessage TEXT NOT NULL DEFAULT '',
+	logPath TEXT NOT NULL DEFAULT '',
+	startedAt TIMESTAMP NOT NULL,
+	finishedAt TIMESTAMP,
+	duration INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (taskId, attempt)
+);
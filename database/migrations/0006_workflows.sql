@@ -0,0 +1,28 @@
+-- 工作流：definition 为 JSON 格式的工作流定义，节点的依赖关系和输出文件以定义为准
+CREATE TABLE workflows (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	status TEXT NOT NULL,
+	onFailure TEXT NOT NULL,
+	definition TEXT NOT NULL,
+	creator TEXT NOT NULL DEFAULT '',
+	errorMessage TEXT NOT NULL DEFAULT '',
+	createdAt TIMESTAMP NOT NULL,
+	finishedAt TIMESTAMP
+);
+
+CREATE INDEX idx_workflows_status ON workflows (status);
+
+-- 工作流节点：每个节点对应一个任务，taskId 在节点提交后写入
+CREATE TABLE workflow_nodes (
+	workflowId TEXT NOT NULL REFERENCES workflows (id) ON DELETE CASCADE,
+	nodeId TEXT NOT NULL,
+	status TEXT NOT NULL,
+	taskId TEXT NOT NULL DEFAULT '',
+	errorMessage TEXT NOT NULL DEFAULT '',
+	startedAt TIMESTAMP,
+	finishedAt TIMESTAMP,
+	PRIMARY KEY (workflowId, nodeId)
+);
+
+CREATE INDEX idx_workflow_nodes_task ON workflow_nodes (taskId);
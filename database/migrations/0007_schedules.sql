@@ -0,0 +1,29 @@
+-- 定时任务：template 为 JSON 格式的任务模板；nextRunAt 为下一次计划执行的时间，暂停期间不更新
+CREATE TABLE schedules (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	cronExpr TEXT NOT NULL,
+	timezone TEXT NOT NULL DEFAULT '',
+	template TEXT NOT NULL,
+	overlapPolicy TEXT NOT NULL,
+	catchUpPolicy TEXT NOT NULL,
+	paused INTEGER NOT NULL DEFAULT 0,
+	creator TEXT NOT NULL DEFAULT '',
+	createdAt TIMESTAMP NOT NULL,
+	nextRunAt TIMESTAMP,
+	lastRunAt TIMESTAMP,
+	lastTaskId TEXT NOT NULL DEFAULT ''
+);
+
+-- 定时任务的每次计划执行：submitted/pending/skipped/missed/failed
+CREATE TABLE schedule_runs (
+	scheduleId TEXT NOT NULL REFERENCES schedules (id) ON DELETE CASCADE,
+	scheduledAt TIMESTAMP NOT NULL,
+	status TEXT NOT NULL,
+	taskId TEXT NOT NULL DEFAULT '',
+	message TEXT NOT NULL DEFAULT '',
+	recordedAt TIMESTAMP NOT NULL,
+	PRIMARY KEY (scheduleId, scheduledAt)
+);
+
+CREATE INDEX idx_schedule_runs_task ON schedule_runs (taskId);
@@ -0,0 +1,16 @@
+-- 任务状态机：成功结束的状态由 completed 改为 succeeded
+UPDATE tasks SET status = 'succeeded' WHERE status = 'completed';
+UPDATE task_attempts SET status = 'succeeded' WHERE status = 'completed';
+
+-- 任务状态转换事件：fromStatus 为空表示任务创建；actor 为发起方（system/recovery/retry/api 等）
+CREATE TABLE task_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	taskId TEXT NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+	fromStatus TEXT NOT NULL DEFAULT '',
+	toStatus TEXT NOT NULL,
+	actor TEXT NOT NULL DEFAULT '',
+	reason TEXT NOT NULL DEFAULT '',
+	createdAt TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_task_events_task ON task_events (taskId, id);
@@ -0,0 +1,211 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrScheduleNotFound 定时任务不存在
+var ErrScheduleNotFound = errors.New("定时任务不存在")
+
+// Schedule 定时任务记录
+type Schedule struct {
+	ID   string
+	Name string
+	// CronExpr cron 表达式，Timezone 解析表达式使用的时区
+	CronExpr string
+	Timezone string
+	// Template JSON 格式的任务模板
+	Template string
+	// OverlapPolicy 上一次的任务未结束时的处理方式（skip/queue/replace）
+	OverlapPolicy string
+	// CatchUpPolicy 停机期间错过的执行的补偿方式（none/latest/all）
+	CatchUpPolicy string
+	Paused        bool
+	Creator       string
+	CreatedAt     time.Time
+	// NextRunAt 下一次计划执行的时间，LastRunAt 最近一次提交任务的计划时间
+	NextRunAt time.Time
+	LastRunAt time.Time
+	// LastTaskID 最近一次提交的任务
+	LastTaskID string
+}
+
+// ScheduleRun 定时任务的一次计划执行
+type ScheduleRun struct {
+	ScheduleID  string
+	ScheduledAt time.Time
+	// Status 执行结果：submitted 已提交任务，pending 等待上一次的任务结束，skipped 因重叠跳过，
+	// missed 停机期间错过且未补偿，failed 提交失败
+	Status     string
+	TaskID     string
+	Message    string
+	RecordedAt time.Time
+}
+
+// scheduleColumns 定时任务表查询的列，顺序与 scanSchedule 一致
+const scheduleColumns = `id, name, cronExpr, timezone, template, overlapPolicy, catchUpPolicy, paused, creator,
+	createdAt, nextRunAt, lastRunAt, lastTaskId`
+
+// scanSchedule 读取一行定时任务记录
+func scanSchedule(row rowScanner) (*Schedule, error) {
+	var schedule Schedule
+	var nextRunAt, lastRunAt sql.NullTime
+	if err := row.Scan(&schedule.ID, &schedule.Name, &schedule.CronExpr, &schedule.Timezone, &schedule.Template,
+		&schedule.OverlapPolicy, &schedule.CatchUpPolicy, &schedule.Paused, &schedule.Creator, &schedule.CreatedAt,
+		&nextRunAt, &lastRunAt, &schedule.LastTaskID); err != nil {
+		return nil, err
+	}
+	if nextRunAt.Valid {
+		schedule.NextRunAt = nextRunAt.Time
+	}
+	if lastRunAt.Valid {
+		schedule.LastRunAt = lastRunAt.Time
+	}
+	return &schedule, nil
+}
+
+// CreateSchedule 保存定时任务
+func (r *Repository) CreateSchedule(schedule *Schedule) error {
+	_, err := r.conn.Exec(`
+		INSERT INTO schedules (id, name, cronExpr, timezone, template, overlapPolicy, catchUpPolicy, paused, creator,
+			createdAt, nextRunAt, lastRunAt, lastTaskId)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		schedule.ID, schedule.Name, schedule.CronExpr, schedule.Timezone, schedule.Template, schedule.OverlapPolicy,
+		schedule.CatchUpPolicy, schedule.Paused, schedule.Creator, schedule.CreatedAt.UTC(),
+		nullTime(schedule.NextRunAt), nullTime(schedule.LastRunAt), schedule.LastTaskID,
+	)
+	if err != nil {
+		r.logger.Error("创建定时任务失败", zap.String("schedule_id", schedule.ID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// UpdateSchedule 更新定时任务的暂停状态和执行进度
+func (r *Repository) UpdateSchedule(schedule *Schedule) error {
+	result, err := r.conn.Exec(`
+		UPDATE schedules SET paused = ?, nextRunAt = ?, lastRunAt = ?, lastTaskId = ? WHERE id = ?`,
+		schedule.Paused, nullTime(schedule.NextRunAt), nullTime(schedule.LastRunAt), schedule.LastTaskID, schedule.ID,
+	)
+	if err != nil {
+		r.logger.Error("更新定时任务失败", zap.String("schedule_id", schedule.ID), zap.Error(err))
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+// DeleteSchedule 删除定时任务及其执行记录，已提交的任务不受影响
+func (r *Repository) DeleteSchedule(id string) error {
+	result, err := r.conn.Exec("DELETE FROM schedules WHERE id = ?", id)
+	if err != nil {
+		r.logger.Error("删除定时任务失败", zap.String("schedule_id", id), zap.Error(err))
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+// GetSchedule 按ID查询定时任务
+// 返回: 定时任务记录，错误信息（不存在时返回 ErrScheduleNotFound）
+func (r *Repository) GetSchedule(id string) (*Schedule, error) {
+	schedule, err := scanSchedule(r.conn.QueryRow("SELECT "+scheduleColumns+" FROM schedules WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrScheduleNotFound
+	}
+	if err != nil {
+		r.logger.Error("查询定时任务失败", zap.String("schedule_id", id), zap.Error(err))
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// GetSchedules 查询全部定时任务，按创建时间升序
+func (r *Repository) GetSchedules() ([]Schedule, error) {
+	rows, err := r.conn.Query("SELECT " + scheduleColumns + " FROM schedules ORDER BY createdAt, id")
+	if err != nil {
+		r.logger.Error("查询定时任务列表失败", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []Schedule{}
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			r.logger.Error("定时任务扫描失败", zap.Error(err))
+			return nil, err
+		}
+		schedules = append(schedules, *schedule)
+	}
+	return schedules, rows.Err()
+}
+
+// SaveScheduleRun 保存一次计划执行，已存在时覆盖
+func (r *Repository) SaveScheduleRun(run *ScheduleRun) error {
+	_, err := r.conn.Exec(`
+		INSERT INTO schedule_runs (scheduleId, scheduledAt, status, taskId, message, recordedAt)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (scheduleId, scheduledAt) DO UPDATE SET
+			status = excluded.status, taskId = excluded.taskId, message = excluded.message, recordedAt = excluded.recordedAt`,
+		run.ScheduleID, run.ScheduledAt.UTC(), run.Status, run.TaskID, run.Message, run.RecordedAt.UTC(),
+	)
+	if err != nil {
+		r.logger.Error("保存定时任务执行记录失败", zap.String("schedule_id", run.ScheduleID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// GetScheduleRuns 查询定时任务的执行记录，按计划时间从新到旧
+// 参数: scheduleID 定时任务ID, status 只返回该状态的记录（为空时不过滤）, limit 最多返回的条数
+func (r *Repository) GetScheduleRuns(scheduleID, status string, limit int) ([]ScheduleRun, error) {
+	query := "SELECT scheduleId, scheduledAt, status, taskId, message, recordedAt FROM schedule_runs WHERE scheduleId = ?"
+	args := []interface{}{scheduleID}
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+	rows, err := r.conn.Query(query+" ORDER BY scheduledAt DESC LIMIT ?", append(args, limit)...)
+	if err != nil {
+		r.logger.Error("查询定时任务执行记录失败", zap.String("schedule_id", scheduleID), zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []ScheduleRun{}
+	for rows.Next() {
+		var run ScheduleRun
+		if err := rows.Scan(&run.ScheduleID, &run.ScheduledAt, &run.Status, &run.TaskID, &run.Message, &run.RecordedAt); err != nil {
+			r.logger.Error("定时任务执行记录扫描失败", zap.Error(err))
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// GetScheduleRunByTask 查询任务对应的计划执行
+// 返回: 执行记录（任务不是定时任务提交的时为 nil），错误信息
+func (r *Repository) GetScheduleRunByTask(taskID string) (*ScheduleRun, error) {
+	var run ScheduleRun
+	err := r.conn.QueryRow(`
+		SELECT scheduleId, scheduledAt, status, taskId, message, recordedAt FROM schedule_runs WHERE taskId = ?`,
+		taskID).Scan(&run.ScheduleID, &run.ScheduledAt, &run.Status, &run.TaskID, &run.Message, &run.RecordedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		r.logger.Error("查询任务对应的定时任务失败", zap.String("task_id", taskID), zap.Error(err))
+		return nil, err
+	}
+	return &run, nil
+}
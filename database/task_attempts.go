@@ -0,0 +1,98 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TaskAttempt 任务的一次执行
+type TaskAttempt struct {
+	TaskID string
+	// Attempt 第几次执行，从 1 开始
+	Attempt int
+	// Status 本次执行的状态（running/succeeded/failed/timeout/cancelled/interrupted）
+	Status string
+	// FailureReason 失败原因（timeout/out_of_memory/non_zero_exit/seccomp_violation/error 等），成功时为空
+	FailureReason string
+	// ExitCode 进程退出码，进程未启动或被信号终止时为 nil
+	ExitCode     *int
+	ErrorMessage string
+	// LogPath 本次执行的日志目录
+	LogPath    string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	// Duration 执行耗时（秒）
+	Duration int64
+}
+
+// SaveAttempt 保存一次执行，已存在时覆盖
+// 参数: attempt 执行记录
+// 返回: 错误信息
+func (r *Repository) SaveAttempt(attempt *TaskAttempt) error {
+	var exitCode interface{}
+	if attempt.ExitCode != nil {
+		exitCode = *attempt.ExitCode
+	}
+	var finishedAt interface{}
+	if !attempt.FinishedAt.IsZero() {
+		finishedAt = attempt.FinishedAt.UTC()
+	}
+	_, err := r.conn.Exec(`
+		INSERT INTO task_attempts (taskId, attempt, status, failureReason, exitCode, errorMessage, logPath,
+			startedAt, finishedAt, duration)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (taskId, attempt) DO UPDATE SET
+			status = excluded.status, failureReason = excluded.failureReason, exitCode = excluded.exitCode,
+			errorMessage = excluded.errorMessage, logPath = excluded.logPath, startedAt = excluded.startedAt,
+			finishedAt = excluded.finishedAt, duration = excluded.duration`,
+		attempt.TaskID, attempt.Attempt, attempt.Status, attempt.FailureReason, exitCode, attempt.ErrorMessage,
+		attempt.LogPath, attempt.StartedAt.UTC(), finishedAt, attempt.Duration,
+	)
+	if err != nil {
+		r.logger.Error("保存任务执行记录失败", zap.String("task_id", attempt.TaskID), zap.Int("attempt", attempt.Attempt), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// GetAttempts 按执行顺序查询任务的全部执行记录
+// 参数: taskID 任务ID
+// 返回: 执行记录列表，错误信息
+func (r *Repository) GetAttempts(taskID string) ([]TaskAttempt, error) {
+	rows, err := r.conn.Query(`
+		SELECT taskId, attempt, status, failureReason, exitCode, errorMessage, logPath,
+			startedAt, finishedAt, duration
+		FROM task_attempts WHERE taskId = ? ORDER BY attempt`, taskID)
+	if err != nil {
+		r.logger.Error("查询任务执行记录失败", zap.String("task_id", taskID), zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []TaskAttempt{}
+	for rows.Next() {
+		var attempt TaskAttempt
+		var exitCode sql.NullInt64
+		var finishedAt sql.NullTime
+		if err := rows.Scan(&attempt.TaskID, &attempt.Attempt, &attempt.Status, &attempt.FailureReason, &exitCode,
+			&attempt.ErrorMessage, &attempt.LogPath, &attempt.StartedAt, &finishedAt, &attempt.Duration); err != nil {
+			r.logger.Error("任务执行记录扫描失败", zap.Error(err))
+			return nil, err
+		}
+		if exitCode.Valid {
+			code := int(exitCode.Int64)
+			attempt.ExitCode = &code
+		}
+		if finishedAt.Valid {
+			attempt.FinishedAt = finishedAt.Time
+		}
+		attempts = append(attempts, attempt)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("查询任务执行记录失败", zap.String("task_id", taskID), zap.Error(err))
+		return nil, err
+	}
+	return attempts, nil
+}
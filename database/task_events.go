@@ -0,0 +1,69 @@
+package database
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TaskEvent 任务的一次状态转换
+type TaskEvent struct {
+	ID     int64
+	TaskID string
+	// FromStatus 转换前的状态，任务创建时为空
+	FromStatus string
+	ToStatus   string
+	// Actor 发起方（system/recovery/retry/api 等）
+	Actor     string
+	Reason    string
+	CreatedAt time.Time
+}
+
+// SaveTaskEvent 保存状态转换事件
+// 参数: event 状态转换事件，保存后填充 ID
+// 返回: 错误信息
+func (r *Repository) SaveTaskEvent(event *TaskEvent) error {
+	result, err := r.conn.Exec(`
+		INSERT INTO task_events (taskId, fromStatus, toStatus, actor, reason, createdAt)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		event.TaskID, event.FromStatus, event.ToStatus, event.Actor, event.Reason, event.CreatedAt.UTC(),
+	)
+	if err != nil {
+		r.logger.Error("保存任务状态转换事件失败", zap.String("task_id", event.TaskID), zap.Error(err))
+		return err
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		event.ID = id
+	}
+	return nil
+}
+
+// GetTaskEvents 按发生顺序查询任务的全部状态转换事件
+// 参数: taskID 任务ID
+// 返回: 事件列表，错误信息
+func (r *Repository) GetTaskEvents(taskID string) ([]TaskEvent, error) {
+	rows, err := r.conn.Query(`
+		SELECT id, taskId, fromStatus, toStatus, actor, reason, createdAt
+		FROM task_events WHERE taskId = ? ORDER BY id`, taskID)
+	if err != nil {
+		r.logger.Error("查询任务状态转换事件失败", zap.String("task_id", taskID), zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []TaskEvent{}
+	for rows.Next() {
+		var event TaskEvent
+		if err := rows.Scan(&event.ID, &event.TaskID, &event.FromStatus, &event.ToStatus, &event.Actor,
+			&event.Reason, &event.CreatedAt); err != nil {
+			r.logger.Error("任务状态转换事件扫描失败", zap.Error(err))
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("查询任务状态转换事件失败", zap.String("task_id", taskID), zap.Error(err))
+		return nil, err
+	}
+	return events, nil
+}
@@ -0,0 +1,270 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultTaskPageSize 未指定每页数量时返回的任务数
+	DefaultTaskPageSize = 20
+	// MaxTaskPageSize 每页最多返回的任务数
+	MaxTaskPageSize = 200
+)
+
+// ErrInvalidTaskFilter 查询条件不合法（排序字段不支持、翻页游标无效等）
+var ErrInvalidTaskFilter = errors.New("任务查询条件不合法")
+
+// taskSortKey 排序字段：column 用于排序和翻页条件，value 用于读取翻页游标中记录的排序值
+type taskSortKey struct {
+	column string
+	value  string
+}
+
+// taskSortKeys 可排序字段
+// 时间列按文本比较（写入时统一为 UTC），读取时转为文本以免驱动解析为时间；
+// 可为空的列用空串代替 NULL，保证翻页条件对每一行都有确定的结果
+var taskSortKeys = map[string]taskSortKey{
+	"created_at":  {"createdAt", "createdAt || ''"},
+	"started_at":  {"COALESCE(startedAt, '')", "COALESCE(startedAt, '')"},
+	"finished_at": {"COALESCE(finishedAt, '')", "COALESCE(finishedAt, '')"},
+	"duration":    {"duration", "duration"},
+	"name":        {"name", "name"},
+}
+
+// TaskFilter 任务查询条件，零值字段不参与过滤
+type TaskFilter struct {
+	// Statuses 任务状态，满足其一即可
+	Statuses []string
+	// Creator 创建者
+	Creator string
+	// AssignedTo 指派人
+	AssignedTo string
+	// NameContains 任务名称包含的子串
+	NameContains string
+	// Runtime 运行时
+	Runtime string
+	// CreatedAfter、CreatedBefore 创建时间范围，左闭右开
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// FinishedAfter、FinishedBefore 结束时间范围，左闭右开，未结束的任务不会匹配
+	FinishedAfter  time.Time
+	FinishedBefore time.Time
+
+	// Sort 排序字段：created_at（默认）/started_at/finished_at/duration/name，相同时按任务ID排序
+	Sort string
+	// Ascending 是否升序，默认降序
+	Ascending bool
+	// Limit 每页数量，0 时使用 DefaultTaskPageSize，不超过 MaxTaskPageSize
+	Limit int
+	// Cursor 上一页返回的 NextCursor，为空时从第一页开始
+	Cursor string
+	// Page 页码（从 1 开始），没有游标时按偏移量翻页，0 表示第一页；不能与 Cursor 同时指定。
+	// 按页码翻页时，翻页过程中写入的任务可能导致重复或遗漏
+	Page int
+}
+
+// TaskPage 一页任务查询结果
+type TaskPage struct {
+	Items []Task
+	// Total 满足过滤条件的任务总数（不受翻页影响）
+	Total int
+	// NextCursor 下一页的游标，没有更多任务时为空
+	NextCursor string
+}
+
+// taskCursor 翻页游标，记录上一页最后一行的排序值和任务ID
+// 排序条件编码在游标中，换了排序方式的游标会被拒绝
+type taskCursor struct {
+	Sort      string `json:"s"`
+	Ascending bool   `json:"a"`
+	Value     string `json:"v"`
+	ID        string `json:"id"`
+}
+
+// ListTasks 按条件分页查询任务
+// 使用游标翻页：下一页从上一页最后一行之后开始，翻页过程中有新任务写入也不会重复或遗漏；
+// 没有游标时可以按页码跳过前面的行
+// 参数: filter 查询条件
+// 返回: 一页任务，错误信息
+func (r *Repository) ListTasks(filter TaskFilter) (*TaskPage, error) {
+	sort := filter.Sort
+	if sort == "" {
+		sort = "created_at"
+	}
+	sortKey, ok := taskSortKeys[sort]
+	if !ok {
+		return nil, fmt.Errorf("%w: 不支持的排序字段 %s", ErrInvalidTaskFilter, sort)
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultTaskPageSize
+	}
+	if limit > MaxTaskPageSize {
+		limit = MaxTaskPageSize
+	}
+	if filter.Page < 0 {
+		return nil, fmt.Errorf("%w: 页码 %d 不合法", ErrInvalidTaskFilter, filter.Page)
+	}
+	if filter.Page > 0 && filter.Cursor != "" {
+		return nil, fmt.Errorf("%w: 页码和翻页游标不能同时指定", ErrInvalidTaskFilter)
+	}
+	offset := 0
+	if filter.Page > 1 {
+		offset = (filter.Page - 1) * limit
+	}
+
+	where, args := filter.conditions()
+	var total int
+	if err := r.conn.QueryRow("SELECT COUNT(*) FROM tasks"+whereClause(where), args...).Scan(&total); err != nil {
+		r.logger.Error("统计任务数失败", zap.Error(err))
+		return nil, err
+	}
+
+	direction, comparison := "DESC", "<"
+	if filter.Ascending {
+		direction, comparison = "ASC", ">"
+	}
+	if filter.Cursor != "" {
+		cursor, err := decodeTaskCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Sort != sort || cursor.Ascending != filter.Ascending {
+			return nil, fmt.Errorf("%w: 翻页游标与排序方式不一致", ErrInvalidTaskFilter)
+		}
+		where = append(where, fmt.Sprintf("(%s, id) %s (?, ?)", sortKey.column, comparison))
+		args = append(args, cursor.Value, cursor.ID)
+	}
+
+	// 多取一行判断是否还有下一页
+	query := fmt.Sprintf("SELECT %s, %s FROM tasks%s ORDER BY %s %s, id %s LIMIT ? OFFSET ?",
+		taskColumns, sortKey.value, whereClause(where), sortKey.column, direction, direction)
+	rows, err := r.conn.Query(query, append(args, limit+1, offset)...)
+	if err != nil {
+		r.logger.Error("查询任务列表失败", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &TaskPage{Items: []Task{}, Total: total}
+	var lastValue string
+	for rows.Next() {
+		if len(page.Items) == limit {
+			next, err := encodeTaskCursor(taskCursor{Sort: sort, Ascending: filter.Ascending, Value: lastValue, ID: page.Items[limit-1].ID})
+			if err != nil {
+				return nil, err
+			}
+			page.NextCursor = next
+			break
+		}
+		var sortValue string
+		task, err := scanTask(sortKeyScanner{rows, &sortValue})
+		if err != nil {
+			r.logger.Error("任务扫描失败", zap.Error(err))
+			return nil, err
+		}
+		page.Items = append(page.Items, *task)
+		lastValue = sortValue
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("查询任务列表失败", zap.Error(err))
+		return nil, err
+	}
+	return page, nil
+}
+
+// conditions 生成过滤条件和参数
+func (f TaskFilter) conditions() ([]string, []interface{}) {
+	var where []string
+	var args []interface{}
+	if len(f.Statuses) > 0 {
+		where = append(where, "status IN (?"+strings.Repeat(", ?", len(f.Statuses)-1)+")")
+		for _, status := range f.Statuses {
+			args = append(args, status)
+		}
+	}
+	for _, match := range []struct {
+		column string
+		value  string
+	}{
+		{"creator", f.Creator},
+		{"assignedTo", f.AssignedTo},
+		{"runtime", f.Runtime},
+	} {
+		if match.value != "" {
+			where = append(where, match.column+" = ?")
+			args = append(args, match.value)
+		}
+	}
+	if f.NameContains != "" {
+		where = append(where, `name LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(f.NameContains)+"%")
+	}
+	for _, bound := range []struct {
+		condition string
+		value     time.Time
+	}{
+		{"createdAt >= ?", f.CreatedAfter},
+		{"createdAt < ?", f.CreatedBefore},
+		{"finishedAt >= ?", f.FinishedAfter},
+		{"finishedAt < ?", f.FinishedBefore},
+	} {
+		if !bound.value.IsZero() {
+			where = append(where, bound.condition)
+			args = append(args, bound.value.UTC())
+		}
+	}
+	return where, args
+}
+
+// whereClause 拼接 WHERE 子句，没有条件时为空
+func whereClause(where []string) string {
+	if len(where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(where, " AND ")
+}
+
+// escapeLike 转义 LIKE 模式中的通配符
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// sortKeyScanner 读取任务列之后追加的排序值
+type sortKeyScanner struct {
+	row       rowScanner
+	sortValue *string
+}
+
+func (s sortKeyScanner) Scan(dest ...interface{}) error {
+	return s.row.Scan(append(dest, s.sortValue)...)
+}
+
+// encodeTaskCursor 将游标编码为 URL 安全的字符串
+func encodeTaskCursor(cursor taskCursor) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", fmt.Errorf("生成翻页游标失败: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeTaskCursor 解析翻页游标
+func decodeTaskCursor(value string) (*taskCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 翻页游标无效", ErrInvalidTaskFilter)
+	}
+	var cursor taskCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" {
+		return nil, fmt.Errorf("%w: 翻页游标无效", ErrInvalidTaskFilter)
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,240 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// queryTestBase 测试任务创建时间的起点
+var queryTestBase = time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+
+// seedQueryTasks 写入用于查询测试的任务，b 和 c、a 和 c、b 和 f 分别有相同的创建时间或耗时
+func seedQueryTasks(t *testing.T, repo *Repository) {
+	t.Helper()
+	tasks := []struct {
+		id, name, status, creator string
+		createdMinute             int
+		duration                  int64
+	}{
+		{"a", "alpha", "failed", "alice", 0, 5},
+		{"b", "beta", "succeeded", "bob", 1, 3},
+		{"c", "gamma", "failed", "alice", 1, 5},
+		{"d", "delta", "timeout", "alice", 2, 1},
+		{"e", "50%_off", "running", "bob", 3, 0},
+		{"f", "epsilon", "succeeded", "alice", 4, 3},
+	}
+	for _, task := range tasks {
+		record := &Task{
+			ID:        task.id,
+			Name:      task.name,
+			Status:    task.status,
+			Creator:   task.creator,
+			CreatedAt: queryTestBase.Add(time.Duration(task.createdMinute) * time.Minute).Format(time.RFC3339),
+			Duration:  task.duration,
+		}
+		if err := repo.CreateTask(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// listAllTasks 按 limit 逐页翻到最后一页，返回全部任务ID
+func listAllTasks(t *testing.T, repo *Repository, filter TaskFilter) ([]string, int) {
+	t.Helper()
+	var ids []string
+	total := -1
+	for page := 0; ; page++ {
+		if page > 10 {
+			t.Fatal("翻页没有结束")
+		}
+		result, err := repo.ListTasks(filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Items) > filter.Limit {
+			t.Fatalf("第 %d 页返回 %d 条，超过每页数量 %d", page+1, len(result.Items), filter.Limit)
+		}
+		if total >= 0 && result.Total != total {
+			t.Fatalf("翻页过程中总数从 %d 变为 %d", total, result.Total)
+		}
+		total = result.Total
+		for _, item := range result.Items {
+			ids = append(ids, item.ID)
+		}
+		if result.NextCursor == "" {
+			return ids, total
+		}
+		filter.Cursor = result.NextCursor
+	}
+}
+
+func TestListTasksSortAndPaginate(t *testing.T) {
+	repo := newTestRepository(t)
+	seedQueryTasks(t, repo)
+
+	tests := []struct {
+		sort      string
+		ascending bool
+		want      []string
+	}{
+		// 排序值相同时按任务ID排序，方向与排序字段一致
+		{"", false, []string{"f", "e", "d", "c", "b", "a"}},
+		{"created_at", true, []string{"a", "b", "c", "d", "e", "f"}},
+		{"duration", false, []string{"c", "a", "f", "b", "d", "e"}},
+		{"duration", true, []string{"e", "d", "b", "f", "a", "c"}},
+		{"name", true, []string{"e", "a", "b", "d", "f", "c"}},
+		{"name", false, []string{"c", "f", "d", "b", "a", "e"}},
+	}
+	for _, tt := range tests {
+		for _, limit := range []int{1, 2, 4, 6} {
+			ids, total := listAllTasks(t, repo, TaskFilter{Sort: tt.sort, Ascending: tt.ascending, Limit: limit})
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("sort=%q ascending=%v limit=%d: 得到 %v，应为 %v", tt.sort, tt.ascending, limit, ids, tt.want)
+			}
+			if total != len(tt.want) {
+				t.Errorf("sort=%q ascending=%v limit=%d: 总数为 %d，应为 %d", tt.sort, tt.ascending, limit, total, len(tt.want))
+			}
+		}
+	}
+}
+
+func TestListTasksFilter(t *testing.T) {
+	repo := newTestRepository(t)
+	seedQueryTasks(t, repo)
+
+	tests := []struct {
+		name   string
+		filter TaskFilter
+		want   []string
+	}{
+		{"状态", TaskFilter{Statuses: []string{"failed", "timeout"}}, []string{"a", "c", "d"}},
+		{"创建者", TaskFilter{Creator: "alice"}, []string{"a", "c", "d", "f"}},
+		{"名称中的通配符按字面匹配", TaskFilter{NameContains: "%_"}, []string{"e"}},
+		{"名称子串", TaskFilter{NameContains: "ta"}, []string{"b", "d"}},
+		{"创建时间左闭右开", TaskFilter{CreatedAfter: queryTestBase.Add(time.Minute), CreatedBefore: queryTestBase.Add(3 * time.Minute)}, []string{"b", "c", "d"}},
+		{"组合条件", TaskFilter{Statuses: []string{"succeeded"}, Creator: "alice"}, []string{"f"}},
+		{"没有匹配", TaskFilter{Creator: "carol"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filter.Sort = "created_at"
+			tt.filter.Ascending = true
+			tt.filter.Limit = 2
+			ids, total := listAllTasks(t, repo, tt.filter)
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("得到 %v，应为 %v", ids, tt.want)
+			}
+			if total != len(tt.want) {
+				t.Errorf("总数为 %d，应为 %d", total, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestListTasksCursorSeesNewTasks(t *testing.T) {
+	repo := newTestRepository(t)
+	seedQueryTasks(t, repo)
+
+	first, err := repo.ListTasks(TaskFilter{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 翻页过程中写入更新的任务，不影响后续页的内容
+	if err := repo.CreateTask(&Task{ID: "g", Name: "new", Status: "pending", CreatedAt: queryTestBase.Add(time.Hour).Format(time.RFC3339)}); err != nil {
+		t.Fatal(err)
+	}
+	second, err := repo.ListTasks(TaskFilter{Limit: 2, Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, item := range second.Items {
+		ids = append(ids, item.ID)
+	}
+	if want := []string{"d", "c"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("第二页为 %v，应为 %v", ids, want)
+	}
+}
+
+func TestListTasksPage(t *testing.T) {
+	repo := newTestRepository(t)
+	seedQueryTasks(t, repo)
+
+	tests := []struct {
+		page int
+		want []string
+	}{
+		{0, []string{"f", "e", "d", "c"}},
+		{1, []string{"f", "e", "d", "c"}},
+		{2, []string{"b", "a"}},
+		{3, nil},
+	}
+	for _, tt := range tests {
+		result, err := repo.ListTasks(TaskFilter{Limit: 4, Page: tt.page})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, item := range result.Items {
+			ids = append(ids, item.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("第 %d 页为 %v，应为 %v", tt.page, ids, tt.want)
+		}
+		if result.Total != 6 {
+			t.Errorf("第 %d 页总数为 %d，应为 6", tt.page, result.Total)
+		}
+	}
+}
+
+func TestListTasksInvalidFilter(t *testing.T) {
+	repo := newTestRepository(t)
+	seedQueryTasks(t, repo)
+
+	first, err := repo.ListTasks(TaskFilter{Sort: "name", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		filter TaskFilter
+	}{
+		{"不支持的排序字段", TaskFilter{Sort: "status"}},
+		{"游标不是 base64", TaskFilter{Cursor: "not a cursor!"}},
+		{"游标不是 JSON", TaskFilter{Cursor: "bm90IGpzb24"}},
+		{"游标与排序字段不一致", TaskFilter{Sort: "created_at", Cursor: first.NextCursor}},
+		{"游标与排序方向不一致", TaskFilter{Sort: "name", Ascending: true, Cursor: first.NextCursor}},
+		{"页码和游标同时指定", TaskFilter{Sort: "name", Limit: 2, Page: 2, Cursor: first.NextCursor}},
+		{"页码为负数", TaskFilter{Page: -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := repo.ListTasks(tt.filter); !errors.Is(err, ErrInvalidTaskFilter) {
+				t.Fatalf("应返回 ErrInvalidTaskFilter，得到 %v", err)
+			}
+		})
+	}
+}
+
+func TestTaskCursorRoundTrip(t *testing.T) {
+	cursors := []taskCursor{
+		{Sort: "created_at", Value: "2024-03-01 08:00:00+00:00", ID: "task_1"},
+		{Sort: "name", Ascending: true, Value: "含有 / + = 的名称", ID: "task_2"},
+		{Sort: "duration", Value: "", ID: "task_3"},
+	}
+	for _, cursor := range cursors {
+		encoded, err := encodeTaskCursor(cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := decodeTaskCursor(encoded)
+		if err != nil {
+			t.Fatalf("解析游标 %q 失败: %v", encoded, err)
+		}
+		if *decoded != cursor {
+			t.Errorf("游标 %+v 解析后为 %+v", cursor, *decoded)
+		}
+	}
+}
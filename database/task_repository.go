@@ -1,6 +1,10 @@
 package database
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
 	"time"
 	"file-flow-service/utils/logger"
 	"go.uber.org/zap"
@@ -20,6 +24,49 @@ type Task struct {
 	Duration    int64
 	FinishedAt  int64
 	StartedAt   int64 // Renamed from StartTime to match service.Task's StartedAt
+	Priority    string
+	// ExpectedMemory 提交时声明的预计内存占用（字节）
+	ExpectedMemory int64
+	// Runtime 运行时（python/java 等），直接执行命令时为空
+	Runtime string
+	// RuntimeVersion 运行时版本
+	RuntimeVersion string
+	// Command 执行的命令或入口文件
+	Command string
+	// Args 命令参数
+	Args []string
+	// ExitCode 进程退出码，进程未运行结束时为 nil
+	ExitCode *int
+	// ErrorMessage 任务失败的原因
+	ErrorMessage string
+	// Idempotent 任务可安全重复执行，服务重启时中断的任务会重新排队而不是标记为 interrupted
+	Idempotent bool
+	// MemoryLimit、CPUCores、MaxPids、AllowNetwork 任务指定的执行参数，重新排队时按原参数执行
+	MemoryLimit  int64
+	CPUCores     float64
+	MaxPids      int64
+	AllowNetwork bool
+	// PID 任务进程ID，PIDStartTime 进程启动时间，用于服务重启后重新接管仍在运行的进程
+	PID          int
+	PIDStartTime int64
+	// Attempt 已开始的执行次数，第一次执行为 1
+	Attempt int
+	// RetryPolicy 任务指定的重试策略，为 nil 或字段为零值时使用配置中的 task_retry
+	RetryPolicy *RetryPolicy
+}
+
+// RetryPolicy 任务失败后的重试策略
+type RetryPolicy struct {
+	// MaxAttempts 最多执行次数（含第一次），1 表示不重试
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	// BackoffBase 第一次重试前的等待时间，之后每次翻倍
+	BackoffBase time.Duration `json:"backoff_base,omitempty"`
+	// BackoffMax 重试等待时间的上限
+	BackoffMax time.Duration `json:"backoff_max,omitempty"`
+	// Jitter 等待时间的随机浮动比例（0~1）
+	Jitter float64 `json:"jitter,omitempty"`
+	// RetryOn 可重试的失败原因（timeout/out_of_memory/non_zero_exit/seccomp_violation/error）
+	RetryOn []string `json:"retry_on,omitempty"`
 }
 
 // TaskInterface methods implementation
@@ -59,6 +106,14 @@ func (t *Task) GetProgress() int64 {
 	return t.Progress
 }
 
+func (t *Task) GetPriority() string {
+	return t.Priority
+}
+
+func (t *Task) GetExpectedMemory() int64 {
+	return t.ExpectedMemory
+}
+
 func (t *Task) GetDuration() int64 {
 	return t.Duration
 }
@@ -87,86 +142,256 @@ func (t *Task) SetFinishedAt(finishTime int64) {
 	t.FinishedAt = finishTime
 }
 
+// Record 返回任务记录本身，嵌入 Task 的任务类型可据此保存完整的任务信息
+func (t *Task) Record() *Task {
+	return t
+}
+
 // Execute implementation for TaskInterface
-func (t *Task) Execute() error {
+func (t *Task) Execute(ctx context.Context) error {
 	// Placeholder for task execution logic
 	return nil
 }
 
+// Repository 任务表的读写，由启动流程创建后注入到任务管理器
+type Repository struct {
+	conn   *sql.DB
+	logger logger.Logger
+}
+
+// NewRepository 创建任务仓库
+// 参数: conn 数据库连接（可以是内存数据库）, logger 日志对象
+func NewRepository(conn *sql.DB, logger logger.Logger) *Repository {
+	return &Repository{conn: conn, logger: logger}
+}
+
+// taskColumns 任务表查询的列，顺序与 scanTask 一致
+const taskColumns = `id, name, status, creator, assignedTo, description, resultPath, progress, priority, expectedMemory,
+	runtime, runtimeVersion, command, args, exitCode, errorMessage, createdAt, startedAt, finishedAt, duration,
+	idempotent, memoryLimit, cpuCores, maxPids, allowNetwork, pid, pidStartTime, attempt, retryPolicy`
+
+// rowScanner 由 *sql.Row 和 *sql.Rows 实现
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTask 读取一行任务记录，时间列转换为 Task 中的 RFC3339 字符串和 Unix 秒
+func scanTask(row rowScanner) (*Task, error) {
+	var task Task
+	var args, retryPolicy string
+	var exitCode sql.NullInt64
+	var createdAt time.Time
+	var startedAt, finishedAt sql.NullTime
+	if err := row.Scan(&task.ID, &task.Name, &task.Status, &task.Creator, &task.AssignedTo, &task.Description,
+		&task.ResultPath, &task.Progress, &task.Priority, &task.ExpectedMemory, &task.Runtime, &task.RuntimeVersion,
+		&task.Command, &args, &exitCode, &task.ErrorMessage, &createdAt, &startedAt, &finishedAt, &task.Duration,
+		&task.Idempotent, &task.MemoryLimit, &task.CPUCores, &task.MaxPids, &task.AllowNetwork, &task.PID, &task.PIDStartTime,
+		&task.Attempt, &retryPolicy); err != nil {
+		return nil, err
+	}
+	if args != "" {
+		if err := json.Unmarshal([]byte(args), &task.Args); err != nil {
+			return nil, err
+		}
+	}
+	if retryPolicy != "" {
+		task.RetryPolicy = &RetryPolicy{}
+		if err := json.Unmarshal([]byte(retryPolicy), task.RetryPolicy); err != nil {
+			return nil, err
+		}
+	}
+	if exitCode.Valid {
+		code := int(exitCode.Int64)
+		task.ExitCode = &code
+	}
+	task.CreatedAt = createdAt.Format(time.RFC3339)
+	if startedAt.Valid {
+		task.StartedAt = startedAt.Time.Unix()
+	}
+	if finishedAt.Valid {
+		task.FinishedAt = finishedAt.Time.Unix()
+	}
+	return &task, nil
+}
+
+// taskValues 转换为写入任务表的列值：参数保存为 JSON 数组，时间为 0 时写入 NULL
+func taskValues(task *Task) (args string, exitCode interface{}, startedAt, finishedAt interface{}, err error) {
+	if task.Args == nil {
+		args = "[]"
+	} else {
+		data, err := json.Marshal(task.Args)
+		if err != nil {
+			return "", nil, nil, nil, err
+		}
+		args = string(data)
+	}
+	if task.ExitCode != nil {
+		exitCode = *task.ExitCode
+	}
+	if task.StartedAt > 0 {
+		startedAt = time.Unix(task.StartedAt, 0).UTC()
+	}
+	if task.FinishedAt > 0 {
+		finishedAt = time.Unix(task.FinishedAt, 0).UTC()
+	}
+	return args, exitCode, startedAt, finishedAt, nil
+}
+
+// retryPolicyValue 转换为写入任务表的重试策略列值：JSON 对象，未指定时为空字符串
+func retryPolicyValue(task *Task) (string, error) {
+	if task.RetryPolicy == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(task.RetryPolicy)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
 // CreateTask inserts a new task into the database
-func CreateTask(task *Task) error {
+func (r *Repository) CreateTask(task *Task) error {
+	createdAt := time.Now()
 	if task.CreatedAt == "" {
-		task.CreatedAt = time.Now().Format(time.RFC3339)
+		task.CreatedAt = createdAt.Format(time.RFC3339)
+	} else if parsed, err := time.Parse(time.RFC3339, task.CreatedAt); err == nil {
+		createdAt = parsed
+	}
+	if task.Priority == "" {
+		task.Priority = "normal"
+	}
+	args, exitCode, startedAt, finishedAt, err := taskValues(task)
+	if err != nil {
+		r.logger.Error("创建任务失败", zap.Error(err))
+		return err
+	}
+	retryPolicy, err := retryPolicyValue(task)
+	if err != nil {
+		r.logger.Error("创建任务失败", zap.Error(err))
+		return err
 	}
 	
-	_, err := db.Exec(`
-		INSERT INTO tasks (id, name, status, creator, createdAt, assignedTo, description, resultPath, progress, duration, finishedAt, startedAt)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		task.ID, task.Name, task.Status, task.Creator, task.CreatedAt, task.AssignedTo, task.Description, task.ResultPath, task.Progress, task.Duration, task.FinishedAt, task.StartedAt,
+	_, err = r.conn.Exec(`
+		INSERT INTO tasks (`+taskColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		task.ID, task.Name, task.Status, task.Creator, task.AssignedTo, task.Description, task.ResultPath, task.Progress,
+		task.Priority, task.ExpectedMemory, task.Runtime, task.RuntimeVersion, task.Command, args, exitCode,
+		task.ErrorMessage, createdAt.UTC(), startedAt, finishedAt, task.Duration,
+		task.Idempotent, task.MemoryLimit, task.CPUCores, task.MaxPids, task.AllowNetwork, task.PID, task.PIDStartTime,
+		task.Attempt, retryPolicy,
 	)
 	if err != nil {
-		logger.GetLogger().Error("创建任务失败", zap.Error(err))
+		r.logger.Error("创建任务失败", zap.Error(err))
 		return err
 	}
-	logger.GetLogger().Error("成功创建任务", zap.String("id", task.ID))
+	r.logger.Info("成功创建任务", zap.String("id", task.ID))
 	return nil
 }
 
 // GetTaskByID retrieves a task by ID
-func GetTaskByID(id string) (*Task, error) {
-	row := db.QueryRow("SELECT id, name, status, creator, createdAt, assignedTo, description, resultPath, progress, duration, finishedAt, startedAt FROM tasks WHERE id = ?", id)
-	
-	var task Task
-	err := row.Scan(&task.ID, &task.Name, &task.Status, &task.Creator, &task.CreatedAt, &task.AssignedTo, &task.Description, &task.ResultPath, &task.Progress, &task.Duration, &task.FinishedAt, &task.StartedAt)
+func (r *Repository) GetTaskByID(id string) (*Task, error) {
+	task, err := scanTask(r.conn.QueryRow("SELECT "+taskColumns+" FROM tasks WHERE id = ?", id))
 	if err != nil {
-		logger.GetLogger().Error("查询任务失败", zap.Error(err))
+		r.logger.Error("查询任务失败", zap.Error(err))
 		return nil, err
 	}
-	return &task, nil
+	return task, nil
 }
 
 // UpdateTask updates an existing task
-func UpdateTask(task *Task) error {
-	_, err := db.Exec(`
+// 除 id 和 createdAt 外的全部列都按 task 写入；priority 为空时保留原值
+func (r *Repository) UpdateTask(task *Task) error {
+	args, exitCode, startedAt, finishedAt, err := taskValues(task)
+	if err != nil {
+		r.logger.Error("更新任务失败", zap.Error(err))
+		return err
+	}
+	retryPolicy, err := retryPolicyValue(task)
+	if err != nil {
+		r.logger.Error("更新任务失败", zap.Error(err))
+		return err
+	}
+	_, err = r.conn.Exec(`
 		UPDATE tasks 
-		SET name = ?, status = ?, creator = ?, assignedTo = ?, description = ?, resultPath = ?, progress = ?, duration = ?, finishedAt = ?, startedAt = ?
+		SET name = ?, status = ?, creator = ?, assignedTo = ?, description = ?, resultPath = ?, progress = ?,
+			priority = COALESCE(NULLIF(?, ''), priority), expectedMemory = ?,
+			runtime = ?, runtimeVersion = ?, command = ?, args = ?, exitCode = ?, errorMessage = ?,
+			startedAt = ?, finishedAt = ?, duration = ?,
+			idempotent = ?, memoryLimit = ?, cpuCores = ?, maxPids = ?, allowNetwork = ?,
+			pid = ?, pidStartTime = ?, attempt = ?, retryPolicy = ?
 		WHERE id = ?`,
-		task.Name, task.Status, task.Creator, task.AssignedTo, task.Description, task.ResultPath, task.Progress, task.Duration, task.FinishedAt, task.StartedAt, task.ID,
+		task.Name, task.Status, task.Creator, task.AssignedTo, task.Description, task.ResultPath, task.Progress,
+		task.Priority, task.ExpectedMemory,
+		task.Runtime, task.RuntimeVersion, task.Command, args, exitCode, task.ErrorMessage,
+		startedAt, finishedAt, task.Duration,
+		task.Idempotent, task.MemoryLimit, task.CPUCores, task.MaxPids, task.AllowNetwork,
+		task.PID, task.PIDStartTime, task.Attempt, retryPolicy, task.ID,
 	)
 	if err != nil {
-		logger.GetLogger().Error("更新任务失败", zap.Error(err))
+		r.logger.Error("更新任务失败", zap.Error(err))
 		return err
 	}
 	return nil
 }
 
+// GetTasksByStatus 查询处于指定状态的任务，按创建时间升序
+// 参数: statuses 任务状态
+// 返回: 任务列表，错误信息
+func (r *Repository) GetTasksByStatus(statuses ...string) ([]Task, error) {
+	if len(statuses) == 0 {
+		return nil, nil
+	}
+	args := make([]interface{}, len(statuses))
+	for i, status := range statuses {
+		args[i] = status
+	}
+	rows, err := r.conn.Query("SELECT "+taskColumns+" FROM tasks WHERE status IN (?"+
+		strings.Repeat(", ?", len(statuses)-1)+") ORDER BY createdAt, id", args...)
+	if err != nil {
+		r.logger.Error("按状态查询任务失败", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			r.logger.Error("任务扫描失败", zap.Error(err))
+			return nil, err
+		}
+		tasks = append(tasks, *task)
+	}
+	return tasks, rows.Err()
+}
+
 // DeleteTask removes a task by ID
-func DeleteTask(id string) error {
-	_, err := db.Exec("DELETE FROM tasks WHERE id = ?", id)
+func (r *Repository) DeleteTask(id string) error {
+	_, err := r.conn.Exec("DELETE FROM tasks WHERE id = ?", id)
 	if err != nil {
-		logger.GetLogger().Error("删除任务失败", zap.Error(err))
+		r.logger.Error("删除任务失败", zap.Error(err))
 		return err
 	}
 	return nil
 }
 
 // GetTasks retrieves all tasks
-func GetTasks() ([]Task, error) {
-	rows, err := db.Query("SELECT id, name, status, creator, createdAt, assignedTo, description, resultPath, progress, duration, finishedAt, startedAt FROM tasks")
+func (r *Repository) GetTasks() ([]Task, error) {
+	rows, err := r.conn.Query("SELECT " + taskColumns + " FROM tasks")
 	if err != nil {
-		logger.GetLogger().Error("获取任务列表失败", zap.Error(err))
+		r.logger.Error("获取任务列表失败", zap.Error(err))
 		return nil, err
 	}
 	defer rows.Close()
 	
 	var tasks []Task
 	for rows.Next() {
-		var task Task
-		if err := rows.Scan(&task.ID, &task.Name, &task.Status, &task.Creator, &task.CreatedAt, &task.AssignedTo, &task.Description, &task.ResultPath, &task.Progress, &task.Duration, &task.FinishedAt, &task.StartedAt); err != nil {
-			logger.GetLogger().Error("任务扫描失败", zap.Error(err))
-			continue
+		task, err := scanTask(rows)
+		if err != nil {
+			r.logger.Error("任务扫描失败", zap.Error(err))
+			return nil, err
 		}
-		tasks = append(tasks, task)
+		tasks = append(tasks, *task)
 	}
-	return tasks, nil
-}
\ No newline at end of file
+	return tasks, rows.Err()
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUpdateTaskPersistsAllFields(t *testing.T) {
+	repo := newTestRepository(t)
+	if err := repo.CreateTask(&Task{ID: "t1", Name: "report", Status: "pending", Priority: "high"}); err != nil {
+		t.Fatal(err)
+	}
+
+	exitCode := 3
+	want := &Task{
+		ID:             "t1",
+		Name:           "report",
+		Status:         "failed",
+		Creator:        "alice",
+		Priority:       "low",
+		ExpectedMemory: 64 << 20,
+		Runtime:        "python",
+		RuntimeVersion: "3.11",
+		Command:        "main.py",
+		Args:           []string{"-v"},
+		ExitCode:       &exitCode,
+		ErrorMessage:   "非零退出",
+		StartedAt:      time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC).Unix(),
+		FinishedAt:     time.Date(2024, 3, 1, 8, 0, 5, 0, time.UTC).Unix(),
+		Duration:       5,
+		Idempotent:     true,
+		MemoryLimit:    128 << 20,
+		CPUCores:       1.5,
+		MaxPids:        32,
+		AllowNetwork:   true,
+		PID:            1234,
+		PIDStartTime:   5678,
+		Attempt:        2,
+		RetryPolicy:    &RetryPolicy{MaxAttempts: 3, BackoffBase: time.Second, RetryOn: []string{"timeout"}},
+	}
+	if err := repo.UpdateTask(want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.GetTaskByID("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.CreatedAt = got.CreatedAt
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("更新后读取的任务为 %+v，应为 %+v", got, want)
+	}
+
+	// 优先级为空时保留原值
+	want.Priority = ""
+	if err := repo.UpdateTask(want); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = repo.GetTaskByID("t1"); err != nil {
+		t.Fatal(err)
+	}
+	if got.Priority != "low" {
+		t.Fatalf("优先级为 %q，应保留 low", got.Priority)
+	}
+}
+
+func TestGetTasksReturnsScanError(t *testing.T) {
+	repo := newTestRepository(t)
+	if err := repo.CreateTask(&Task{ID: "t1", Name: "ok", Status: "pending"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CreateTask(&Task{ID: "t2", Name: "broken", Status: "pending"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.conn.Exec("UPDATE tasks SET args = 'not json' WHERE id = 't2'"); err != nil {
+		t.Fatal(err)
+	}
+
+	tasks, err := repo.GetTasks()
+	if err == nil {
+		t.Fatalf("读取到无法解析的任务时应返回错误，得到 %d 个任务", len(tasks))
+	}
+}
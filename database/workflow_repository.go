@@ -0,0 +1,228 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrWorkflowNotFound 工作流不存在
+var ErrWorkflowNotFound = errors.New("工作流不存在")
+
+// Workflow 工作流记录
+type Workflow struct {
+	ID     string
+	Name   string
+	Status string
+	// OnFailure 节点失败时的处理方式（fail_fast/continue）
+	OnFailure string
+	// Definition JSON 格式的工作流定义
+	Definition   string
+	Creator      string
+	ErrorMessage string
+	CreatedAt    time.Time
+	FinishedAt   time.Time
+}
+
+// WorkflowNode 工作流节点记录
+type WorkflowNode struct {
+	WorkflowID string
+	NodeID     string
+	Status     string
+	// TaskID 节点对应的任务，节点提交前为空
+	TaskID       string
+	ErrorMessage string
+	StartedAt    time.Time
+	FinishedAt   time.Time
+}
+
+// nullTime 时间为零值时写入 NULL
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
+
+// CreateWorkflow 在同一个事务中保存工作流及其全部节点
+// 参数: workflow 工作流记录, nodes 节点记录
+// 返回: 错误信息
+func (r *Repository) CreateWorkflow(workflow *Workflow, nodes []WorkflowNode) error {
+	tx, err := r.conn.Begin()
+	if err != nil {
+		r.logger.Error("创建工作流失败", zap.Error(err))
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO workflows (id, name, status, onFailure, definition, creator, errorMessage, createdAt, finishedAt)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		workflow.ID, workflow.Name, workflow.Status, workflow.OnFailure, workflow.Definition, workflow.Creator,
+		workflow.ErrorMessage, workflow.CreatedAt.UTC(), nullTime(workflow.FinishedAt),
+	); err != nil {
+		r.logger.Error("创建工作流失败", zap.String("workflow_id", workflow.ID), zap.Error(err))
+		return err
+	}
+	for _, node := range nodes {
+		if _, err := tx.Exec(`
+			INSERT INTO workflow_nodes (workflowId, nodeId, status, taskId, errorMessage, startedAt, finishedAt)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			workflow.ID, node.NodeID, node.Status, node.TaskID, node.ErrorMessage,
+			nullTime(node.StartedAt), nullTime(node.FinishedAt),
+		); err != nil {
+			r.logger.Error("创建工作流节点失败", zap.String("workflow_id", workflow.ID), zap.String("node_id", node.NodeID), zap.Error(err))
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		r.logger.Error("创建工作流失败", zap.String("workflow_id", workflow.ID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// UpdateWorkflow 更新工作流的状态
+func (r *Repository) UpdateWorkflow(workflow *Workflow) error {
+	_, err := r.conn.Exec("UPDATE workflows SET status = ?, errorMessage = ?, finishedAt = ? WHERE id = ?",
+		workflow.Status, workflow.ErrorMessage, nullTime(workflow.FinishedAt), workflow.ID)
+	if err != nil {
+		r.logger.Error("更新工作流失败", zap.String("workflow_id", workflow.ID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// UpdateWorkflowNode 更新工作流节点的状态和对应的任务
+func (r *Repository) UpdateWorkflowNode(node *WorkflowNode) error {
+	_, err := r.conn.Exec(`
+		UPDATE workflow_nodes SET status = ?, taskId = ?, errorMessage = ?, startedAt = ?, finishedAt = ?
+		WHERE workflowId = ? AND nodeId = ?`,
+		node.Status, node.TaskID, node.ErrorMessage, nullTime(node.StartedAt), nullTime(node.FinishedAt),
+		node.WorkflowID, node.NodeID,
+	)
+	if err != nil {
+		r.logger.Error("更新工作流节点失败", zap.String("workflow_id", node.WorkflowID), zap.String("node_id", node.NodeID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// workflowColumns 工作流表查询的列，顺序与 scanWorkflow 一致
+const workflowColumns = "id, name, status, onFailure, definition, creator, errorMessage, createdAt, finishedAt"
+
+// scanWorkflow 读取一行工作流记录
+func scanWorkflow(row rowScanner) (*Workflow, error) {
+	var workflow Workflow
+	var finishedAt sql.NullTime
+	if err := row.Scan(&workflow.ID, &workflow.Name, &workflow.Status, &workflow.OnFailure, &workflow.Definition,
+		&workflow.Creator, &workflow.ErrorMessage, &workflow.CreatedAt, &finishedAt); err != nil {
+		return nil, err
+	}
+	if finishedAt.Valid {
+		workflow.FinishedAt = finishedAt.Time
+	}
+	return &workflow, nil
+}
+
+// GetWorkflow 按ID查询工作流
+// 返回: 工作流记录，错误信息（不存在时返回 ErrWorkflowNotFound）
+func (r *Repository) GetWorkflow(id string) (*Workflow, error) {
+	workflow, err := scanWorkflow(r.conn.QueryRow("SELECT "+workflowColumns+" FROM workflows WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWorkflowNotFound
+	}
+	if err != nil {
+		r.logger.Error("查询工作流失败", zap.String("workflow_id", id), zap.Error(err))
+		return nil, err
+	}
+	return workflow, nil
+}
+
+// GetWorkflowsByStatus 查询处于指定状态的工作流，按创建时间升序
+func (r *Repository) GetWorkflowsByStatus(statuses ...string) ([]Workflow, error) {
+	if len(statuses) == 0 {
+		return nil, nil
+	}
+	args := make([]interface{}, len(statuses))
+	for i, status := range statuses {
+		args[i] = status
+	}
+	rows, err := r.conn.Query("SELECT "+workflowColumns+" FROM workflows WHERE status IN (?"+
+		strings.Repeat(", ?", len(statuses)-1)+") ORDER BY createdAt, id", args...)
+	if err != nil {
+		r.logger.Error("按状态查询工作流失败", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var workflows []Workflow
+	for rows.Next() {
+		workflow, err := scanWorkflow(rows)
+		if err != nil {
+			r.logger.Error("工作流扫描失败", zap.Error(err))
+			return nil, err
+		}
+		workflows = append(workflows, *workflow)
+	}
+	return workflows, rows.Err()
+}
+
+// GetWorkflowNodes 查询工作流的全部节点
+func (r *Repository) GetWorkflowNodes(workflowID string) ([]WorkflowNode, error) {
+	rows, err := r.conn.Query(`
+		SELECT workflowId, nodeId, status, taskId, errorMessage, startedAt, finishedAt
+		FROM workflow_nodes WHERE workflowId = ? ORDER BY rowid`, workflowID)
+	if err != nil {
+		r.logger.Error("查询工作流节点失败", zap.String("workflow_id", workflowID), zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var nodes []WorkflowNode
+	for rows.Next() {
+		var node WorkflowNode
+		var startedAt, finishedAt sql.NullTime
+		if err := rows.Scan(&node.WorkflowID, &node.NodeID, &node.Status, &node.TaskID, &node.ErrorMessage,
+			&startedAt, &finishedAt); err != nil {
+			r.logger.Error("工作流节点扫描失败", zap.Error(err))
+			return nil, err
+		}
+		if startedAt.Valid {
+			node.StartedAt = startedAt.Time
+		}
+		if finishedAt.Valid {
+			node.FinishedAt = finishedAt.Time
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, rows.Err()
+}
+
+// GetWorkflowNodeByTask 查询任务所属的工作流节点
+// 返回: 节点记录（任务不属于工作流时为 nil），错误信息
+func (r *Repository) GetWorkflowNodeByTask(taskID string) (*WorkflowNode, error) {
+	var node WorkflowNode
+	var startedAt, finishedAt sql.NullTime
+	err := r.conn.QueryRow(`
+		SELECT workflowId, nodeId, status, taskId, errorMessage, startedAt, finishedAt
+		FROM workflow_nodes WHERE taskId = ?`, taskID).Scan(&node.WorkflowID, &node.NodeID, &node.Status,
+		&node.TaskID, &node.ErrorMessage, &startedAt, &finishedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		r.logger.Error("查询任务所属的工作流节点失败", zap.String("task_id", taskID), zap.Error(err))
+		return nil, err
+	}
+	if startedAt.Valid {
+		node.StartedAt = startedAt.Time
+	}
+	if finishedAt.Valid {
+		node.FinishedAt = finishedAt.Time
+	}
+	return &node, nil
+}
@@ -5,16 +5,25 @@
 package file
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"file-flow-service/utils/logger"
 	"mime/multipart"
+	"time"
 
 	"go.uber.org/zap"
 )
 
+// ErrUploadNotFound 上传ID不合法或对应的上传文件不存在
+var ErrUploadNotFound = errors.New("上传的文件不存在")
+
+// uploadIDPattern 上传ID的格式，作为存储目录名使用
+var uploadIDPattern = regexp.MustCompile(`^upload_[0-9]+$`)
+
 type FileService struct {
 	StoragePath string
 	Logger      logger.Logger
@@ -85,3 +94,66 @@ func (f *FileService) Download(fileID string) (string, error) {
 	
 	return filePath, nil
 }
+
+// Store 保存上传的文件，每次上传存放在以上传ID命名的独立目录中，同名文件不会相互覆盖
+// 参数：file 文件头
+// 返回：上传ID，错误信息
+func (f *FileService) Store(file *multipart.FileHeader) (string, error) {
+	name := filepath.Base(file.Filename)
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		return "", fmt.Errorf("文件名 %q 不合法", file.Filename)
+	}
+	uploadID := fmt.Sprintf("upload_%d", time.Now().UnixNano())
+	stored := *file
+	stored.Filename = name
+	if err := NewFileService(filepath.Join(f.StoragePath, uploadID), f.Logger).Upload(&stored); err != nil {
+		return "", err
+	}
+	return uploadID, nil
+}
+
+// CopyTo 将上传的文件复制到目标目录（如任务目录），保持上传时的文件名
+// 参数：uploadID 上传ID, dstDir 目标目录
+// 返回：错误信息（上传ID不合法或不存在时返回 ErrUploadNotFound）
+func (f *FileService) CopyTo(uploadID, dstDir string) error {
+	if !uploadIDPattern.MatchString(uploadID) {
+		return fmt.Errorf("%w: %s", ErrUploadNotFound, uploadID)
+	}
+	srcDir := filepath.Join(f.StoragePath, uploadID)
+	entries, err := os.ReadDir(srcDir)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", ErrUploadNotFound, uploadID)
+	}
+	if err != nil {
+		return fmt.Errorf("读取上传目录失败: %v", err)
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if err := copyFile(filepath.Join(srcDir, entry.Name()), filepath.Join(dstDir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	f.Logger.Info("上传的文件已复制", zap.String("upload_id", uploadID), zap.String("dir", dstDir))
+	return nil
+}
+
+// copyFile 复制单个文件，目标文件已存在时覆盖
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("读取 %s 失败: %v", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("创建 %s 失败: %v", dst, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("复制 %s 失败: %v", src, err)
+	}
+	return out.Close()
+}
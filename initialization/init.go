@@ -1,7 +1,6 @@
 package initialization
 
 import (
-	"database/sql"
 	"log"
 	"os"
 	"path"
@@ -37,29 +36,7 @@ func InitApp() error {
 		}
 	}
 
-	// 初始化数据库表结构
-	db, err := sql.Open("sqlite3", "database/database.db")
-	if err != nil {
-		return err
-	}
-	defer db.Close()
-
-	createTableSQL := `
-CREATE TABLE IF NOT EXISTS tasks (
-	id TEXT PRIMARY KEY,
-	name TEXT NOT NULL,
-	status TEXT,
-	creator TEXT,
-	createdAt TEXT,
-	assignedTo TEXT,
-	description TEXT,
-	resultPath TEXT,
-	progress INTEGER
-)`
-
-	if _, err := db.Exec(createTableSQL); err != nil {
-		return err
-	}
+	// 数据库按 database.connection 配置在启动时创建并迁移，见 database.Open
 
 	log.Println("项目初始化完成，所有必要资源已就绪")
 	return nil
@@ -2,6 +2,7 @@ package initializer
 
 import (
 	"database/sql"
+	"file-flow-service/database"
 	"log"
 	"os"
 	"path"
@@ -35,19 +36,8 @@ func InitApp() error {
 	}
 	defer db.Close()
 
-	createTableSQL := `
-CREATE TABLE IF NOT EXISTS tasks (
-    id TEXT PRIMARY KEY,
-    name TEXT NOT NULL,
-    status TEXT,
-    creator TEXT,
-    createdAt TEXT,
-    assignedTo TEXT,
-    description TEXT,
-    resultPath TEXT,
-    progress INTEGER
-)`
-	if _, err := db.Exec(createTableSQL); err != nil {
+	// 表结构由 database 包中的迁移脚本维护
+	if _, err := database.Migrate(db); err != nil {
 		return err
 	}
 
@@ -6,6 +6,7 @@ package restart
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"sync"
 	"file-flow-service/utils/logger"
 	"file-flow-service/config"
@@ -208,7 +209,8 @@ func (rm *RestartManager) saveCurrentState() error {
 	
 	// 保存当前配置快照
 	if rm.config != nil {
-		rm.logger.Info("保存配置快照 monitor_interval=" + rm.config.MonitorInterval + " max_workers=" + string(rm.config.Threadpool.MaxWorkers))
+		snapshot := rm.config.Snapshot()
+		rm.logger.Info("保存配置快照 monitor_interval=" + snapshot.MonitorInterval + " max_workers=" + strconv.Itoa(snapshot.MaxWorkers))
 	}
 	
 	// 保存服务状态
@@ -0,0 +1,219 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSearchYears 查找下一次执行时间时最多向后搜索的年数，超过时认为表达式不会再触发（例如 2 月 30 日）
+const cronSearchYears = 5
+
+// cronMacros 预定义的表达式
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var weekdayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// cronField 一个字段的取值范围和可用的名称
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var cronFields = []cronField{
+	{name: "分钟", min: 0, max: 59},
+	{name: "小时", min: 0, max: 23},
+	{name: "日", min: 1, max: 31},
+	{name: "月", min: 1, max: 12, names: monthNames},
+	// 星期中 0 和 7 都表示周日
+	{name: "星期", min: 0, max: 7, names: weekdayNames},
+}
+
+// cronSchedule 解析后的 cron 表达式，每个字段用位图记录允许的取值
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domAny、dowAny 日、星期字段是否以 * 开头（含 */2 等步长），
+	// 两者都不以 * 开头时满足其一即可，否则两者都要满足（与 vixie cron 一致）
+	domAny, dowAny bool
+}
+
+// parseCron 解析标准的 5 字段 cron 表达式：分 时 日 月 星期
+// 支持 *、范围（1-5）、步长（*/15、1-30/5）、列表（1,15）、月份和星期的英文缩写，以及 @daily 等预定义表达式
+func parseCron(expr string) (*cronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("cron 表达式 %q 应为 5 个字段（分 时 日 月 星期）", expr)
+	}
+
+	bits := make([]uint64, len(parts))
+	for i, part := range parts {
+		value, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron 表达式 %q 的%s字段%v", expr, cronFields[i].name, err)
+		}
+		bits[i] = value
+	}
+	// 星期 7 等同于 0
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+	return &cronSchedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAny: unrestricted(parts[2]),
+		dowAny: unrestricted(parts[4]),
+	}, nil
+}
+
+// unrestricted 日或星期字段是否以 * 或 ? 开头，此时该字段不参与日和星期的“或”匹配
+func unrestricted(field string) bool {
+	return strings.HasPrefix(field, "*") || strings.HasPrefix(field, "?")
+}
+
+// parseCronField 解析一个字段，返回允许取值的位图
+func parseCronField(field string, spec cronField) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			rangePart = item[:i]
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf(" %q 的步长不合法", item)
+			}
+			step = n
+		}
+
+		low, high := spec.min, spec.max
+		switch {
+		case rangePart == "*" || rangePart == "?":
+			if spec.max == 7 {
+				high = 6
+			}
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if low, err = cronValue(bounds[0], spec); err != nil {
+				return 0, err
+			}
+			if high, err = cronValue(bounds[1], spec); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf(" %q 的起始值大于结束值", item)
+			}
+		default:
+			value, err := cronValue(rangePart, spec)
+			if err != nil {
+				return 0, err
+			}
+			low = value
+			// 单个值带步长时（如 5/15）表示从该值开始到最大值
+			high = value
+			if step > 1 {
+				high = spec.max
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// cronValue 解析单个取值，允许使用名称
+func cronValue(s string, spec cronField) (int, error) {
+	if value, ok := spec.names[strings.ToLower(s)]; ok {
+		return value, nil
+	}
+	value, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf(" %q 不是有效的取值", s)
+	}
+	if value < spec.min || value > spec.max {
+		return 0, fmt.Errorf("取值 %d 超出范围 %d-%d", value, spec.min, spec.max)
+	}
+	return value, nil
+}
+
+// Next 返回 t 之后（不含 t）的下一次执行时间，按 t 所在的时区计算
+// 夏令时跳过的时刻不会触发；表达式在搜索范围内不会再触发时返回零值
+func (c *cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + cronSearchYears
+
+WRAP:
+	if t.Year() > limit {
+		return time.Time{}
+	}
+	for c.month&(1<<uint(t.Month())) == 0 {
+		t = advance(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc))
+		if t.Month() == time.January {
+			goto WRAP
+		}
+	}
+	for !c.dayMatches(t) {
+		t = advance(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc))
+		if t.Day() == 1 {
+			goto WRAP
+		}
+	}
+	for c.hour&(1<<uint(t.Hour())) == 0 {
+		t = advance(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc))
+		if t.Hour() == 0 {
+			goto WRAP
+		}
+	}
+	for c.minute&(1<<uint(t.Minute())) == 0 {
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto WRAP
+		}
+	}
+	return t
+}
+
+// advance 返回前进后的时刻 next
+// next 落在夏令时跳过的区间内时 time.Date 可能把它换算到 t 或更早，此时按绝对时间逐小时前进，越过跳过的区间
+func advance(t, next time.Time) time.Time {
+	for !next.After(t) {
+		next = next.Add(time.Hour)
+	}
+	return next
+}
+
+// dayMatches 日期是否满足日和星期字段
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
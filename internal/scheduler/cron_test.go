@@ -0,0 +1,132 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	// 测试环境可能没有系统时区数据库
+	_ "time/tzdata"
+)
+
+func TestParseCronInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"@every 5m",
+	}
+	for _, expr := range tests {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("表达式 %q 应解析失败", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, err := time.Parse("2006-01-02 15:04:05", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	tests := []struct {
+		name string
+		expr string
+		from string
+		want string
+	}{
+		{"步长", "*/15 * * * *", "2024-03-06 10:07:00", "2024-03-06 10:15:00"},
+		{"不含起始时刻", "0 10 * * *", "2024-03-06 10:00:00", "2024-03-07 10:00:00"},
+		{"忽略秒", "0 10 * * *", "2024-03-06 09:59:30", "2024-03-06 10:00:00"},
+		{"星期 7 为周日", "0 0 * * 7", "2024-03-06 12:00:00", "2024-03-10 00:00:00"},
+		{"星期缩写", "0 0 * * sun", "2024-03-06 12:00:00", "2024-03-10 00:00:00"},
+		{"星期范围含 7", "0 0 * * 5-7", "2024-03-06 12:00:00", "2024-03-08 00:00:00"},
+		{"预定义表达式", "@weekly", "2024-03-06 12:00:00", "2024-03-10 00:00:00"},
+		{"月份范围带步长", "0 12 * jan-mar/2 *", "2024-02-10 00:00:00", "2024-03-01 12:00:00"},
+		{"跨年", "0 0 1 1 *", "2024-12-31 23:59:00", "2025-01-01 00:00:00"},
+		{"闰日", "0 0 29 2 *", "2024-03-01 00:00:00", "2028-02-29 00:00:00"},
+		// 日和星期都有限制时满足其一即可：3 月 4 日是周一
+		{"日或星期", "0 0 15 * mon", "2024-03-02 00:00:00", "2024-03-04 00:00:00"},
+		// 日以 * 开头时不参与“或”匹配，需要同时满足：单数日的周一
+		{"日为步长时与星期同时满足", "0 0 */2 * mon", "2024-03-02 00:00:00", "2024-03-11 00:00:00"},
+		// 星期以 * 开头时同理：落在周日、二、四、六的 1 日
+		{"星期为步长时与日同时满足", "0 0 1 * */2", "2024-03-02 00:00:00", "2024-06-01 00:00:00"},
+		{"日和星期都是通配", "0 0 * * *", "2024-03-02 08:00:00", "2024-03-03 00:00:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := parseCron(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := schedule.Next(at(tt.from)); !got.Equal(at(tt.want)) {
+				t.Errorf("%q 在 %s 之后应为 %s，得到 %s", tt.expr, tt.from, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCronNextNever(t *testing.T) {
+	schedule, err := parseCron("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := schedule.Next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)); !got.IsZero() {
+		t.Fatalf("2 月 30 日不会触发，得到 %s", got)
+	}
+}
+
+func TestCronNextDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, newYork)
+	}
+	// 纽约 2024-03-10 02:00 EST 拨快到 03:00 EDT，2024-11-03 02:00 EDT 拨回到 01:00 EST；
+	// 圣保罗 2018-11-04 00:00 拨快到 01:00，当天没有零点
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"跳过的时刻不触发", "30 2 * * *", local(2024, 3, 10, 0, 0), local(2024, 3, 11, 2, 30)},
+		{"每小时任务跨过跳过的小时", "0 * * * *", local(2024, 3, 10, 1, 30), time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC)},
+		{"跳过时刻之后的任务当天照常触发", "0 3 * * *", local(2024, 3, 10, 0, 0), time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC)},
+		{"拨回当天按当地时间触发", "0 3 * * *", local(2024, 11, 2, 3, 0), time.Date(2024, 11, 3, 8, 0, 0, 0, time.UTC)},
+		{"跳过零点的日期", "0 6 4 * *", time.Date(2018, 11, 3, 12, 0, 0, 0, saoPaulo), time.Date(2018, 11, 4, 8, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := parseCron(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := schedule.Next(tt.from)
+			if !got.Equal(tt.want) {
+				t.Errorf("%q 在 %s 之后应为 %s，得到 %s", tt.expr, tt.from, tt.want.In(tt.from.Location()), got)
+			}
+			if got.Location() != tt.from.Location() {
+				t.Errorf("结果的时区为 %s，应为 %s", got.Location(), tt.from.Location())
+			}
+		})
+	}
+}
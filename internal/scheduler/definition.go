@@ -0,0 +1,135 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrInvalidDefinition 定时任务定义不合法
+var ErrInvalidDefinition = errors.New("定时任务定义不合法")
+
+// 重叠策略：到达执行时间时上一次提交的任务仍未结束
+const (
+	// OverlapSkip 跳过本次执行（默认）
+	OverlapSkip = "skip"
+	// OverlapQueue 等上一次的任务结束后再提交，最多等待一次，之后到达的执行时间按 skip 处理
+	OverlapQueue = "queue"
+	// OverlapReplace 取消上一次的任务，提交本次任务
+	OverlapReplace = "replace"
+)
+
+// 补偿策略：服务停机或定时任务暂停期间错过的执行时间
+const (
+	// CatchUpNone 只记录为 missed，不补偿（默认）
+	CatchUpNone = "none"
+	// CatchUpLatest 只补偿最近一次
+	CatchUpLatest = "latest"
+	// CatchUpAll 依次补偿每一次，受重叠策略约束
+	CatchUpAll = "all"
+)
+
+// Definition 定时任务定义，JSON 格式
+type Definition struct {
+	// Name 定时任务名称，也作为提交的任务名称
+	Name string `json:"name"`
+	// Cron 5 字段 cron 表达式（分 时 日 月 星期）或 @daily 等预定义表达式
+	Cron string `json:"cron"`
+	// Timezone 计算执行时间使用的 IANA 时区（如 Asia/Shanghai），为空时使用服务器本地时区
+	Timezone string `json:"timezone,omitempty"`
+	// Overlap 重叠策略：skip（默认）/queue/replace
+	Overlap string `json:"overlap,omitempty"`
+	// CatchUp 补偿策略：none（默认）/latest/all
+	CatchUp string `json:"catch_up,omitempty"`
+	// Task 每次执行时提交的任务
+	Task Template `json:"task"`
+}
+
+// Template 定时任务每次执行时提交的任务模板
+type Template struct {
+	// Cmd、Args、EnvType、EnvVersion 与 /api/execute 的参数一致，cmd 和 env_type 可省略，由执行器自动识别
+	Cmd        string   `json:"cmd,omitempty"`
+	Args       []string `json:"args,omitempty"`
+	EnvType    string   `json:"env_type,omitempty"`
+	EnvVersion string   `json:"env_version,omitempty"`
+	// ScriptName、Script 每次执行前写入任务目录的脚本文件名和内容
+	ScriptName string `json:"script_name,omitempty"`
+	Script     string `json:"script,omitempty"`
+	// Priority 任务优先级（high/normal/low）
+	Priority string `json:"priority,omitempty"`
+	// MaxAttempts 任务失败后最多执行次数，0 表示使用 task_retry 配置
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	// Idempotent 任务可安全重复执行，服务重启时中断的任务会重新排队
+	Idempotent bool `json:"idempotent,omitempty"`
+}
+
+// ParseDefinition 解析并校验 JSON 格式的定时任务定义，不认识的字段视为拼写错误
+// 返回: 定时任务定义，错误信息（格式或内容不合法时返回 ErrInvalidDefinition）
+func ParseDefinition(data []byte) (*Definition, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var def Definition
+	if err := decoder.Decode(&def); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDefinition, err)
+	}
+	if err := def.Validate(); err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// Validate 校验定时任务定义，并填充重叠策略和补偿策略的默认值
+func (d *Definition) Validate() error {
+	if strings.TrimSpace(d.Name) == "" {
+		return fmt.Errorf("%w: 缺少定时任务名称", ErrInvalidDefinition)
+	}
+	if _, err := parseCron(d.Cron); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDefinition, err)
+	}
+	if _, err := loadLocation(d.Timezone); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDefinition, err)
+	}
+	switch d.Overlap {
+	case "":
+		d.Overlap = OverlapSkip
+	case OverlapSkip, OverlapQueue, OverlapReplace:
+	default:
+		return fmt.Errorf("%w: overlap %q 应为 %s、%s 或 %s", ErrInvalidDefinition, d.Overlap, OverlapSkip, OverlapQueue, OverlapReplace)
+	}
+	switch d.CatchUp {
+	case "":
+		d.CatchUp = CatchUpNone
+	case CatchUpNone, CatchUpLatest, CatchUpAll:
+	default:
+		return fmt.Errorf("%w: catch_up %q 应为 %s、%s 或 %s", ErrInvalidDefinition, d.CatchUp, CatchUpNone, CatchUpLatest, CatchUpAll)
+	}
+	if d.Task.MaxAttempts < 0 {
+		return fmt.Errorf("%w: max_attempts %d 不合法", ErrInvalidDefinition, d.Task.MaxAttempts)
+	}
+	if d.Task.Script != "" || d.Task.ScriptName != "" {
+		name := d.Task.ScriptName
+		if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+			return fmt.Errorf("%w: script_name %q 应为不含路径的文件名", ErrInvalidDefinition, name)
+		}
+	}
+	if d.Task.Cmd == "" && d.Task.Script == "" {
+		return fmt.Errorf("%w: cmd 和 script 至少指定一个", ErrInvalidDefinition)
+	}
+	return nil
+}
+
+// loadLocation 加载时区，为空时使用服务器本地时区
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("时区 %q 无效: %v", name, err)
+	}
+	return loc, nil
+}
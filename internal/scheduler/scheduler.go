@@ -0,0 +1,471 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"file-flow-service/config"
+	"file-flow-service/database"
+	"file-flow-service/internal/taskmanager"
+	"file-flow-service/sandbox/execution"
+	"file-flow-service/utils/logger"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 计划执行的状态
+const (
+	RunSubmitted = "submitted"
+	RunPending   = "pending"
+	RunSkipped   = "skipped"
+	RunMissed    = "missed"
+	RunFailed    = "failed"
+)
+
+const (
+	// missedGrace 执行时间过去超过该时长仍未触发的视为错过（服务停机），按补偿策略处理
+	missedGrace = time.Minute
+	// maxCatchUp 一次最多处理的错过的执行时间，更早的直接跳过
+	maxCatchUp = 1000
+	// pollInterval 调度循环的最长等待时间，系统时间被调整后也能及时触发
+	pollInterval = time.Minute
+)
+
+// Scheduler 定时任务调度器
+// 定时任务保存在数据库中，到达执行时间时按模板创建任务并通过 TaskManager.SubmitTask 提交；
+// 每次计划执行的结果（提交、跳过、错过等）记录在 schedule_runs 中
+type Scheduler struct {
+	config      *config.AppConfig
+	taskManager taskmanager.TaskManager
+	repository  *database.Repository
+	executor    execution.SandboxExecutor
+	logger      logger.Logger
+	// mu 串行处理定时任务的触发和修改
+	mu sync.Mutex
+	// wake 定时任务变化后唤醒调度循环，重新计算等待时间
+	wake    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	started bool
+}
+
+// NewScheduler 创建定时任务调度器，并注册任务结束的回调
+// 参数: config 配置对象, taskManager 任务管理器, repository 任务仓库, executor 沙盒执行器, logger 日志对象
+func NewScheduler(config *config.AppConfig, taskManager taskmanager.TaskManager, repository *database.Repository, executor execution.SandboxExecutor, logger logger.Logger) *Scheduler {
+	s := &Scheduler{
+		config:      config,
+		taskManager: taskManager,
+		repository:  repository,
+		executor:    executor,
+		logger:      logger,
+		wake:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	taskManager.OnTaskFinished(s.handleTaskFinished)
+	return s
+}
+
+// Start 启动调度循环，应在 TaskManager.Recover 之后调用
+// 服务停机期间错过的执行时间在第一轮调度中按各定时任务的补偿策略处理
+func (s *Scheduler) Start() error {
+	if s.executor == nil {
+		return fmt.Errorf("沙盒执行器未初始化")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return nil
+	}
+	s.started = true
+	go s.loop()
+	s.logger.Info("定时任务调度已启动")
+	return nil
+}
+
+// Stop 停止调度循环，已提交的任务不受影响
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if !s.started {
+		s.mu.Unlock()
+		return
+	}
+	s.started = false
+	s.mu.Unlock()
+
+	close(s.stop)
+	<-s.done
+	s.logger.Info("定时任务调度已停止")
+}
+
+// Create 保存定时任务，从当前时间开始计算下一次执行时间
+// 参数: def 定时任务定义, creator 创建者（也作为提交的任务的创建者）
+// 返回: 定时任务记录，错误信息（定义不合法时返回 ErrInvalidDefinition）
+func (s *Scheduler) Create(def *Definition, creator string) (*database.Schedule, error) {
+	if err := def.Validate(); err != nil {
+		return nil, err
+	}
+	template, err := json.Marshal(def.Task)
+	if err != nil {
+		return nil, fmt.Errorf("序列化任务模板失败: %v", err)
+	}
+	schedule := &database.Schedule{
+		ID:            fmt.Sprintf("sch_%d", time.Now().UnixNano()),
+		Name:          def.Name,
+		CronExpr:      def.Cron,
+		Timezone:      def.Timezone,
+		Template:      string(template),
+		OverlapPolicy: def.Overlap,
+		CatchUpPolicy: def.CatchUp,
+		Creator:       creator,
+		CreatedAt:     time.Now(),
+	}
+	schedule.NextRunAt, err = nextRun(schedule, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if schedule.NextRunAt.IsZero() {
+		return nil, fmt.Errorf("%w: cron 表达式 %q 不会再触发", ErrInvalidDefinition, def.Cron)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.repository.CreateSchedule(schedule); err != nil {
+		return nil, err
+	}
+	s.logger.Info("定时任务已创建", zap.String("schedule_id", schedule.ID), zap.String("name", schedule.Name),
+		zap.String("cron", schedule.CronExpr), zap.Time("next_run_at", schedule.NextRunAt))
+	s.notify()
+	return schedule, nil
+}
+
+// List 查询全部定时任务
+func (s *Scheduler) List() ([]database.Schedule, error) {
+	return s.repository.GetSchedules()
+}
+
+// SetPaused 暂停或恢复定时任务
+// 暂停期间不触发，也不记录错过的执行；恢复时从当前时间重新计算下一次执行时间
+// 返回: 错误信息（不存在时返回 database.ErrScheduleNotFound）
+func (s *Scheduler) SetPaused(scheduleID string, paused bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, err := s.repository.GetSchedule(scheduleID)
+	if err != nil {
+		return err
+	}
+	if schedule.Paused == paused {
+		return nil
+	}
+	schedule.Paused = paused
+	if !paused {
+		if schedule.NextRunAt, err = nextRun(schedule, time.Now()); err != nil {
+			return err
+		}
+	}
+	if err := s.repository.UpdateSchedule(schedule); err != nil {
+		return err
+	}
+	s.logger.Info("定时任务状态已更新", zap.String("schedule_id", scheduleID), zap.Bool("paused", paused))
+	s.notify()
+	return nil
+}
+
+// Delete 删除定时任务及其执行记录，已提交的任务继续执行
+// 返回: 错误信息（不存在时返回 database.ErrScheduleNotFound）
+func (s *Scheduler) Delete(scheduleID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.repository.DeleteSchedule(scheduleID); err != nil {
+		return err
+	}
+	s.logger.Info("定时任务已删除", zap.String("schedule_id", scheduleID))
+	s.notify()
+	return nil
+}
+
+// Runs 查询定时任务最近的执行记录
+// 返回: 执行记录（按计划时间从新到旧），错误信息（不存在时返回 database.ErrScheduleNotFound）
+func (s *Scheduler) Runs(scheduleID string, limit int) ([]database.ScheduleRun, error) {
+	if _, err := s.repository.GetSchedule(scheduleID); err != nil {
+		return nil, err
+	}
+	return s.repository.GetScheduleRuns(scheduleID, "", limit)
+}
+
+// notify 唤醒调度循环，不阻塞
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// loop 调度循环：触发到期的定时任务，然后等待到最近的下一次执行时间
+func (s *Scheduler) loop() {
+	defer close(s.done)
+	for {
+		wait := pollInterval
+		if next := s.runDue(time.Now()); !next.IsZero() {
+			if d := time.Until(next); d < wait {
+				wait = d
+			}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// runDue 触发所有到期的定时任务
+// 返回: 未暂停的定时任务中最近的下一次执行时间，没有时返回零值
+func (s *Scheduler) runDue(now time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedules, err := s.repository.GetSchedules()
+	if err != nil {
+		return time.Time{}
+	}
+	var earliest time.Time
+	for i := range schedules {
+		schedule := &schedules[i]
+		if schedule.Paused {
+			continue
+		}
+		s.dispatchPending(schedule)
+		s.fire(schedule, now)
+		if !schedule.NextRunAt.IsZero() && (earliest.IsZero() || schedule.NextRunAt.Before(earliest)) {
+			earliest = schedule.NextRunAt
+		}
+	}
+	return earliest
+}
+
+// fire 处理定时任务到期的执行时间，调用方需持有 s.mu
+// 过去不超过 missedGrace 的执行时间正常触发，更早的按补偿策略触发或记录为 missed；
+// 下一次执行时间在触发前写回，服务在提交过程中崩溃时不会重复提交
+func (s *Scheduler) fire(schedule *database.Schedule, now time.Time) {
+	cron, err := parseCron(schedule.CronExpr)
+	if err != nil {
+		s.logger.Error("定时任务的 cron 表达式无效", zap.String("schedule_id", schedule.ID), zap.Error(err))
+		return
+	}
+	loc, err := loadLocation(schedule.Timezone)
+	if err != nil {
+		s.logger.Error("定时任务的时区无效", zap.String("schedule_id", schedule.ID), zap.Error(err))
+		return
+	}
+
+	var onTime, missed []time.Time
+	next := schedule.NextRunAt
+	for !next.IsZero() && !next.After(now) {
+		if len(onTime)+len(missed) >= maxCatchUp {
+			s.logger.Warn("定时任务错过的执行过多，跳过更早的执行", zap.String("schedule_id", schedule.ID), zap.Int("limit", maxCatchUp))
+			next = cron.Next(now.In(loc))
+			break
+		}
+		if now.Sub(next) > missedGrace {
+			missed = append(missed, next)
+		} else {
+			onTime = append(onTime, next)
+		}
+		next = cron.Next(next.In(loc))
+	}
+	if len(onTime) == 0 && len(missed) == 0 {
+		return
+	}
+	schedule.NextRunAt = next
+	if err := s.repository.UpdateSchedule(schedule); err != nil {
+		return
+	}
+
+	var due []time.Time
+	switch schedule.CatchUpPolicy {
+	case CatchUpAll:
+		due, missed = missed, nil
+	case CatchUpLatest:
+		if len(onTime) == 0 && len(missed) > 0 {
+			due, missed = missed[len(missed)-1:], missed[:len(missed)-1]
+		}
+	}
+	if len(missed) > 0 {
+		s.logger.Warn("定时任务错过执行", zap.String("schedule_id", schedule.ID), zap.Int("missed", len(missed)),
+			zap.String("catch_up", schedule.CatchUpPolicy))
+	}
+	for _, scheduledAt := range missed {
+		s.saveRun(&database.ScheduleRun{ScheduleID: schedule.ID, ScheduledAt: scheduledAt, Status: RunMissed,
+			Message: "服务停机期间错过的执行"})
+	}
+	for _, scheduledAt := range append(due, onTime...) {
+		s.trigger(schedule, scheduledAt)
+	}
+}
+
+// trigger 按重叠策略处理一次计划执行，调用方需持有 s.mu
+func (s *Scheduler) trigger(schedule *database.Schedule, scheduledAt time.Time) {
+	run := &database.ScheduleRun{ScheduleID: schedule.ID, ScheduledAt: scheduledAt}
+	if s.active(schedule) {
+		switch schedule.OverlapPolicy {
+		case OverlapQueue:
+			pending, err := s.repository.GetScheduleRuns(schedule.ID, RunPending, 1)
+			if err != nil {
+				return
+			}
+			if len(pending) == 0 {
+				run.Status = RunPending
+				run.Message = fmt.Sprintf("等待上一次的任务 %s 结束", schedule.LastTaskID)
+			} else {
+				run.Status = RunSkipped
+				run.Message = "已有等待中的执行"
+			}
+			s.saveRun(run)
+			return
+		case OverlapReplace:
+			s.logger.Info("定时任务取消上一次的任务", zap.String("schedule_id", schedule.ID), zap.String("task_id", schedule.LastTaskID))
+			if err := s.taskManager.CancelTask(schedule.LastTaskID, taskmanager.ActorScheduler,
+				fmt.Sprintf("被定时任务 %s 在 %s 的执行替换", schedule.ID, scheduledAt.Format(time.RFC3339))); err != nil {
+				s.logger.Warn("取消上一次的任务失败", zap.String("task_id", schedule.LastTaskID), zap.Error(err))
+			}
+		default:
+			run.Status = RunSkipped
+			run.Message = fmt.Sprintf("上一次的任务 %s 尚未结束", schedule.LastTaskID)
+			s.saveRun(run)
+			return
+		}
+	}
+	s.submit(schedule, run)
+}
+
+// dispatchPending 上一次的任务已结束时，提交 queue 策略下等待中的执行，调用方需持有 s.mu
+func (s *Scheduler) dispatchPending(schedule *database.Schedule) {
+	if s.active(schedule) {
+		return
+	}
+	pending, err := s.repository.GetScheduleRuns(schedule.ID, RunPending, 1)
+	if err != nil || len(pending) == 0 {
+		return
+	}
+	s.submit(schedule, &pending[0])
+}
+
+// submit 按模板创建任务并提交，调用方需持有 s.mu
+// 先记录执行和任务ID再提交：任务很快结束时，结束回调能找到对应的定时任务
+func (s *Scheduler) submit(schedule *database.Schedule, run *database.ScheduleRun) {
+	run.TaskID = fmt.Sprintf("%s_%d", schedule.ID, run.ScheduledAt.Unix())
+	task, err := s.newTask(schedule, run)
+	if err != nil {
+		run.Status = RunFailed
+		run.Message = err.Error()
+		s.saveRun(run)
+		s.logger.Error("创建定时任务的任务失败", zap.String("schedule_id", schedule.ID), zap.Error(err))
+		return
+	}
+
+	run.Status = RunSubmitted
+	run.Message = ""
+	if err := s.saveRun(run); err != nil {
+		return
+	}
+	schedule.LastRunAt = run.ScheduledAt
+	schedule.LastTaskID = run.TaskID
+	if err := s.repository.UpdateSchedule(schedule); err != nil {
+		return
+	}
+	if err := s.taskManager.SubmitTask(task); err != nil {
+		run.Status = RunFailed
+		run.Message = "提交任务失败: " + err.Error()
+		s.saveRun(run)
+		s.logger.Error("提交定时任务失败", zap.String("schedule_id", schedule.ID), zap.String("task_id", run.TaskID), zap.Error(err))
+		return
+	}
+	s.logger.Info("定时任务已提交", zap.String("schedule_id", schedule.ID), zap.String("task_id", run.TaskID),
+		zap.Time("scheduled_at", run.ScheduledAt))
+}
+
+// newTask 按模板创建任务，模板中有脚本时写入任务目录
+func (s *Scheduler) newTask(schedule *database.Schedule, run *database.ScheduleRun) (*taskmanager.SandboxTask, error) {
+	var template Template
+	if err := json.Unmarshal([]byte(schedule.Template), &template); err != nil {
+		return nil, fmt.Errorf("解析任务模板失败: %v", err)
+	}
+	if template.Script != "" {
+		taskDir, err := s.executor.CreateTaskDirectory(run.TaskID)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(taskDir, template.ScriptName), []byte(template.Script), 0755); err != nil {
+			return nil, fmt.Errorf("写入脚本失败: %v", err)
+		}
+	}
+
+	record := &database.Task{
+		ID:          run.TaskID,
+		Name:        schedule.Name,
+		Creator:     schedule.Creator,
+		Description: fmt.Sprintf("定时任务 %s 在 %s 的执行", schedule.ID, run.ScheduledAt.Format(time.RFC3339)),
+		Priority:    template.Priority,
+		Idempotent:  template.Idempotent,
+	}
+	if template.MaxAttempts > 0 {
+		record.RetryPolicy = &database.RetryPolicy{MaxAttempts: template.MaxAttempts}
+	}
+	return taskmanager.NewSandboxTask(record, s.executor, template.Cmd, template.Args, template.EnvType, template.EnvVersion), nil
+}
+
+// handleTaskFinished 定时任务提交的任务结束后，提交 queue 策略下等待中的执行
+func (s *Scheduler) handleTaskFinished(taskID, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run, err := s.repository.GetScheduleRunByTask(taskID)
+	if err != nil || run == nil {
+		return
+	}
+	schedule, err := s.repository.GetSchedule(run.ScheduleID)
+	if err != nil || schedule.Paused {
+		return
+	}
+	s.dispatchPending(schedule)
+}
+
+// active 定时任务上一次提交的任务是否仍未结束（排队、运行或等待重试等）
+func (s *Scheduler) active(schedule *database.Schedule) bool {
+	if schedule.LastTaskID == "" {
+		return false
+	}
+	task, err := s.repository.GetTaskByID(schedule.LastTaskID)
+	if err != nil {
+		return false
+	}
+	return !taskmanager.IsFinal(task.Status)
+}
+
+// saveRun 保存执行记录，失败只记录日志
+func (s *Scheduler) saveRun(run *database.ScheduleRun) error {
+	run.RecordedAt = time.Now()
+	return s.repository.SaveScheduleRun(run)
+}
+
+// nextRun 计算定时任务在 after 之后的下一次执行时间
+func nextRun(schedule *database.Schedule, after time.Time) (time.Time, error) {
+	cron, err := parseCron(schedule.CronExpr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := loadLocation(schedule.Timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cron.Next(after.In(loc)), nil
+}
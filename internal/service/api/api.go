@@ -62,12 +62,16 @@ func (a *API) GetThreadPoolStats() (*interfaces.ThreadPoolStats, error) {
 	return a.service.GetThreadPoolStats()
 }
 
+func (a *API) GetTaskQueuePosition(taskID string) (int, error) {
+	return a.service.GetTaskQueuePosition(taskID)
+}
+
 func (a *API) UploadFile(file *multipart.FileHeader) (string, error) {
 	return a.service.UploadFile(file)
 }
 
-func (a *API) ExecuteCommand(cmd string, args []string) error {
-	return a.service.ExecuteCommand(cmd, args)
+func (a *API) ExecuteCommand(req interfaces.ExecuteRequest) (string, error) {
+	return a.service.ExecuteCommand(req)
 }
 
 func (a *API) GetCommandHelp() string {
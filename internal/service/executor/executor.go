@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"context"
 	"file-flow-service/config"
 	"file-flow-service/internal/service/interfaces"
 	"file-flow-service/utils/logger"
@@ -14,11 +15,11 @@ type BaseExecutor struct {
 	threadpool *threadpool.ThreadPool
 }
 
-func NewExecutor(config *config.AppConfig, logger logger.Logger) *BaseExecutor {
+func NewExecutor(config *config.AppConfig, logger logger.Logger, pool *threadpool.ThreadPool) *BaseExecutor {
 	return &BaseExecutor{
 		config:    config,
 		logger:    logger,
-		threadpool: threadpool.NewThreadPool(),
+		threadpool: pool,
 	}
 }
 
@@ -32,13 +33,16 @@ func (e *BaseExecutor) Execute(task interfaces.TaskInterface) {
 		e.logger.Info("任务执行完成, task_id=" + task.GetID() + ", duration=" + duration.String())
 	}(time.Now())
 
-	e.threadpool.Submit(func() {
+	err := e.threadpool.Submit(func(ctx context.Context) {
 		e.logger.Info("任务提交到线程池, task_id=" + task.GetID())
-		err := task.Execute()
+		err := task.Execute(ctx)
 		if err != nil {
 			e.logger.Error("任务执行失败, task_id=" + task.GetID() + ", error=" + err.Error())
 		}
 	})
+	if err != nil {
+		e.logger.Error("任务提交到线程池失败, task_id=" + task.GetID() + ", error=" + err.Error())
+	}
 }
 
 func (e *BaseExecutor) Stop() {
@@ -1,16 +1,53 @@
 package interfaces
 
-import "mime/multipart"
+import (
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"time"
+)
 
 type UpdateTaskRequest struct {
 	Name   string
 	Status string
 }
 
+// ExecuteRequest 命令执行请求
+type ExecuteRequest struct {
+	Cmd        string
+	Args       []string
+	EnvType    string
+	EnvVersion string
+	Name       string
+	Creator    string
+	Priority   string
+	// Files 执行前复制到任务目录的上传文件（/api/upload 返回的上传ID），自动识别运行时在复制之后进行
+	Files []string
+	// ExpectedMemory 预计内存占用（字节），0 表示不声明
+	ExpectedMemory int64
+	// MemoryLimit、CPUCores、MaxPids 任务资源限制，只能在配置的上限内调低，0 表示使用配置值
+	MemoryLimit int64
+	CPUCores    float64
+	MaxPids     int64
+	// AllowNetwork namespace 隔离模式下允许任务访问网络，会记录审计日志
+	AllowNetwork bool
+	// Idempotent 任务可安全重复执行，服务重启时中断的任务会重新排队
+	Idempotent bool
+	// MaxAttempts 失败后最多执行次数（含第一次），0 表示使用 task_retry 配置
+	MaxAttempts int
+	// RetryBackoff、RetryBackoffMax 第一次重试前的等待时间及其上限，0 表示使用配置值
+	RetryBackoff    time.Duration
+	RetryBackoffMax time.Duration
+	// RetryJitter 等待时间的随机浮动比例（0~1），0 表示使用配置值
+	RetryJitter float64
+	// RetryOn 可重试的失败原因（timeout/out_of_memory/non_zero_exit/seccomp_violation/error），为空时使用配置值
+	RetryOn []string
+}
+
 type Service interface {
 	GracefulShutdown()
 	UploadFile(file *multipart.FileHeader) (string, error)
-	ExecuteCommand(cmd string, args []string) error
+	ExecuteCommand(req ExecuteRequest) (string, error)
 	GetCommandHelp() string
 	GetStatus() string
 	UpdateTask(taskID string, req UpdateTaskRequest) error
@@ -23,8 +60,23 @@ type Service interface {
 	GetSystemInfo() (*SystemInfo, error)
 	GetTaskStats() (*TaskStats, error)
 	GetThreadPoolStats() (*ThreadPoolStats, error)
+	GetTaskQueuePosition(taskID string) (int, error)
+	ListTasks(query TaskQuery) (*TaskList, error)
+	GetTaskAttempts(taskID string) ([]*TaskAttempt, error)
+	GetTaskEvents(taskID string) ([]*TaskEvent, error)
+	SubmitWorkflow(definition []byte, creator string) (string, error)
+	GetWorkflow(workflowID string) (*Workflow, error)
+	CreateSchedule(definition []byte, creator string) (*Schedule, error)
+	ListSchedules() ([]*Schedule, error)
+	PauseSchedule(scheduleID string, paused bool) error
+	DeleteSchedule(scheduleID string) error
+	GetScheduleRuns(scheduleID string, limit int) ([]*ScheduleRun, error)
 	GetExecutorStatus() string
 	GetConfigList() []map[string]string
+	ListEnvironments() ([]*EnvironmentInfo, error)
+	SetDefaultEnvironment(envType, version string) error
+	UninstallEnvironment(envType, version string) error
+	CollectEnvironmentGarbage() (*EnvironmentGCReport, error)
 }
 
 type TaskInterface interface {
@@ -32,7 +84,7 @@ type TaskInterface interface {
 	GetName() string
 	GetStatus() string
 	SetStatus(status string)
-	Execute() error
+	Execute(ctx context.Context) error
 	GetStartTime() int64
 	SetStartTime(startTime int64)
 	GetDuration() int64
@@ -45,6 +97,10 @@ type TaskInterface interface {
 	GetDescription() string
 	GetResultPath() string
 	GetProgress() int64
+	// GetPriority 任务优先级（high/normal/low），为空时按 normal 调度
+	GetPriority() string
+	// GetExpectedMemory 任务声明的预计内存占用（字节），0 表示不声明
+	GetExpectedMemory() int64
 }
 
 type ProcessInfo struct {
@@ -85,19 +141,125 @@ type ThreadPoolStats struct {
 	TotalTasks     int
 	ActiveTasks    int
 	CompletedTasks int
+	QueueLength    int
+	RejectedTasks  int
+	TimeoutTasks   int
+	MaxWorkers     int
+	MaxQueue       int
+	MinWorkers     int
+	CurrentWorkers int
+	IdleWorkers    int
+	ScaleUps       int
+	ScaleDowns     int
+	MemoryLimit    int64
+	MemoryInUse    int64
+	MemoryDeferred int
+	MemoryBlocked  bool
+}
+
+// EnvironmentInfo 已安装运行时版本的清单信息
+type EnvironmentInfo struct {
+	EnvType         string `json:"env_type"`
+	Version         string `json:"version"`
+	Versioned       bool   `json:"versioned"`
+	Default         bool   `json:"default"`
+	Path            string `json:"path"`
+	Executable      string `json:"executable"`
+	DetectedVersion string `json:"detected_version"`
+	InstalledAt     int64  `json:"installed_at"`
+	SizeBytes       int64  `json:"size_bytes"`
+	LastUsedAt      int64  `json:"last_used_at"`
+	Healthy         bool   `json:"healthy"`
+	Error           string `json:"error,omitempty"`
+}
+
+// EnvironmentGCReport 虚拟环境缓存回收结果
+type EnvironmentGCReport struct {
+	Removed        []string `json:"removed"`
+	Skipped        int      `json:"skipped"`
+	ReclaimedBytes int64    `json:"reclaimed_bytes"`
+}
+
+// TaskQuery 任务列表查询条件，零值字段不参与过滤
+type TaskQuery struct {
+	Statuses     []string
+	Creator      string
+	AssignedTo   string
+	NameContains string
+	Runtime      string
+	// CreatedAfter、CreatedBefore、FinishedAfter、FinishedBefore 时间范围（Unix 秒），左闭右开
+	CreatedAfter   int64
+	CreatedBefore  int64
+	FinishedAfter  int64
+	FinishedBefore int64
+	// Sort 排序字段（created_at/started_at/finished_at/duration/name），Order 为 asc 或 desc（默认）
+	Sort  string
+	Order string
+	// Size 每页数量，Cursor 上一页返回的 next_cursor
+	Size   int
+	Cursor string
+	// Page 页码（从 1 开始），没有游标时按偏移量翻页，不能与 Cursor 同时指定
+	Page int
+}
+
+// TaskList 一页任务列表
+type TaskList struct {
+	Items      []*Task `json:"items"`
+	Total      int     `json:"total"`
+	NextCursor string  `json:"next_cursor,omitempty"`
 }
 
 type Task struct {
 	ID        string    `json:"id"`
 	Name      string    `json:"name"`
 	Status    string    `json:"status"`
+	Creator   string    `json:"creator"`
+	AssignedTo string   `json:"assigned_to"`
+	Description string  `json:"description"`
 	CreatedAt string    `json:"created_at"`
 	StartedAt int64     `json:"started_at"`
 	FinishedAt int64    `json:"finished_at"`
 	Duration  int64     `json:"duration"`
+	Priority  string    `json:"priority"`
+	ExpectedMemory int64 `json:"expected_memory"`
+	Progress  int64     `json:"progress"`
+	ResultPath string   `json:"result_path"`
+	Runtime   string    `json:"runtime"`
+	RuntimeVersion string `json:"runtime_version"`
+	Command   string    `json:"command"`
+	Args      []string  `json:"args"`
+	ExitCode  *int      `json:"exit_code"`
+	ErrorMessage string `json:"error_message,omitempty"`
+	// Attempt 已执行的次数，失败重试时递增
+	Attempt   int       `json:"attempt"`
 	Logs      []string  `json:"logs"`
 }
 
+// TaskAttempt 任务的一次执行
+type TaskAttempt struct {
+	Attempt       int    `json:"attempt"`
+	Status        string `json:"status"`
+	FailureReason string `json:"failure_reason,omitempty"`
+	ExitCode      *int   `json:"exit_code"`
+	ErrorMessage  string `json:"error_message,omitempty"`
+	// LogPath 本次执行的日志目录（stdout.log、stderr.log、result.json）
+	LogPath    string `json:"log_path"`
+	StartedAt  int64  `json:"started_at"`
+	FinishedAt int64  `json:"finished_at"`
+	Duration   int64  `json:"duration"`
+}
+
+// TaskEvent 任务的一次状态转换
+type TaskEvent struct {
+	// From 转换前的状态，任务创建时为空
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Actor 发起方（system/recovery/retry/api/workflow/scheduler）
+	Actor     string `json:"actor"`
+	Reason    string `json:"reason,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+}
+
 func (t *Task) GetID() string {
 	return t.ID
 }
@@ -114,7 +276,7 @@ func (t *Task) SetStatus(status string) {
 	t.Status = status
 }
 
-func (t *Task) Execute() error {
+func (t *Task) Execute(ctx context.Context) error {
 	return nil
 }
 
@@ -144,4 +306,69 @@ func (t *Task) SetFinishedAt(finishTime int64) {
 
 func (t *Task) GetCreatedAt() string {
 	return t.CreatedAt
-}
\ No newline at end of file
+}
+
+func (t *Task) GetPriority() string {
+	return t.Priority
+}
+
+func (t *Task) GetExpectedMemory() int64 {
+	return t.ExpectedMemory
+}
+
+// Workflow 工作流及其节点的状态
+type Workflow struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// OnFailure 节点失败时的处理方式（fail_fast/continue）
+	OnFailure    string          `json:"on_failure"`
+	Creator      string          `json:"creator"`
+	ErrorMessage string          `json:"error_message,omitempty"`
+	CreatedAt    int64           `json:"created_at"`
+	FinishedAt   int64           `json:"finished_at"`
+	Nodes        []*WorkflowNode `json:"nodes"`
+}
+
+// WorkflowNode 工作流节点的状态
+type WorkflowNode struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	// TaskID 节点对应的任务，提交前为空
+	TaskID       string   `json:"task_id,omitempty"`
+	DependsOn    []string `json:"depends_on,omitempty"`
+	ErrorMessage string   `json:"error_message,omitempty"`
+	StartedAt    int64    `json:"started_at"`
+	FinishedAt   int64    `json:"finished_at"`
+}
+
+// Schedule 定时任务
+type Schedule struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Cron     string `json:"cron"`
+	Timezone string `json:"timezone,omitempty"`
+	// Task 每次执行时提交的任务模板
+	Task json.RawMessage `json:"task"`
+	// Overlap 上一次的任务未结束时的处理方式（skip/queue/replace）
+	Overlap string `json:"overlap"`
+	// CatchUp 停机期间错过的执行的补偿方式（none/latest/all）
+	CatchUp   string `json:"catch_up"`
+	Paused    bool   `json:"paused"`
+	Creator   string `json:"creator"`
+	CreatedAt int64  `json:"created_at"`
+	// NextRunAt 下一次计划执行的时间，暂停期间不更新
+	NextRunAt  int64  `json:"next_run_at"`
+	LastRunAt  int64  `json:"last_run_at"`
+	LastTaskID string `json:"last_task_id,omitempty"`
+}
+
+// ScheduleRun 定时任务的一次计划执行
+type ScheduleRun struct {
+	ScheduledAt int64 `json:"scheduled_at"`
+	// Status submitted/pending/skipped/missed/failed
+	Status     string `json:"status"`
+	TaskID     string `json:"task_id,omitempty"`
+	Message    string `json:"message,omitempty"`
+	RecordedAt int64  `json:"recorded_at"`
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"file-flow-service/internal/shutdown"
 	"file-flow-service/internal/service/executor"
 	"file-flow-service/internal/taskmanager"
@@ -11,8 +12,18 @@ import (
 	"file-flow-service/config"
 	"file-flow-service/internal/threadpool"
 	"file-flow-service/internal/service/interfaces"
+	"file-flow-service/internal/workflow"
+	"file-flow-service/internal/scheduler"
+	"file-flow-service/database"
+	"file-flow-service/file"
+	"file-flow-service/sandbox/environments"
+	"file-flow-service/sandbox/execution"
+	"fmt"
 	"mime/multipart"
 	"strings"
+	"time"
+
+	"go.uber.org/zap"
 )
 
 type Service struct {
@@ -22,17 +33,27 @@ type Service struct {
 	RestartManager *restart.RestartManager
 	Monitor       *monitor.MonitorImpl
 	Executor      *executor.BaseExecutor
+	Sandbox       execution.SandboxExecutor
+	Environments  environments.EnvironmentManager
+	Workflows     *workflow.Engine
+	Scheduler     *scheduler.Scheduler
+	// Uploads 上传文件的存储，执行请求和工作流节点按上传ID引用
+	Uploads       *file.FileService
 	logger        logger.Logger
 }
 
-func NewService(config *config.AppConfig, logger logger.Logger) *Service {
-	threadPool := threadpool.NewThreadPool()
+func NewService(config *config.AppConfig, logger logger.Logger, sandbox execution.SandboxExecutor, envManager environments.EnvironmentManager, repository *database.Repository) *Service {
+	threadPool := threadpool.NewThreadPool(config, logger)
+	threadPool.RegisterConfigHandlers()
 
-	taskManager := taskmanager.NewTaskManager(config, threadPool, logger)
+	taskManager := taskmanager.NewTaskManager(config, threadPool, repository, logger)
 	shutdownManager := shutdown.NewShutdownManager(nil, logger, config)
 	restartManager := restart.NewRestartManager(config, logger, nil)
 	monitorImpl := monitor.NewMonitorImpl(logger, config)
-	executor := executor.NewExecutor(config, logger)
+	executor := executor.NewExecutor(config, logger, threadPool)
+	uploads := file.NewFileService(config.File.StoragePath, logger)
+	workflows := workflow.NewEngine(config, taskManager, repository, sandbox, uploads, logger)
+	scheduler := scheduler.NewScheduler(config, taskManager, repository, sandbox, logger)
 
 	return &Service{
 		AppConfig:           config,
@@ -41,6 +62,11 @@ func NewService(config *config.AppConfig, logger logger.Logger) *Service {
 		RestartManager:      restartManager,
 		Monitor:             monitorImpl,
 		Executor:            executor,
+		Sandbox:             sandbox,
+		Environments:        envManager,
+		Workflows:           workflows,
+		Scheduler:           scheduler,
+		Uploads:             uploads,
 		logger:              logger,
 	}
 }
@@ -53,29 +79,98 @@ func (s *Service) Start() {
 
 func (s *Service) Stop() {
 	s.ShutdownManager.GracefulShutdown()
+	s.Scheduler.Stop()
 	s.TaskManager.Stop()
 	s.RestartManager.Stop()
 	s.Monitor.Stop(context.Background())
+	if s.Environments != nil {
+		s.Environments.Stop()
+	}
+}
+
+// RecoverTasks 恢复上次运行时未完成的任务和工作流，应在接收新任务前调用
+func (s *Service) RecoverTasks() error {
+	if s.Sandbox == nil {
+		return fmt.Errorf("沙盒执行器未初始化")
+	}
+	if _, err := s.TaskManager.Recover(s.Sandbox); err != nil {
+		return err
+	}
+	return s.Workflows.Recover()
 }
 
 func (s *Service) GetStatus() string {
 	return "Service Status: Active"
 }
 
-func (s *Service) UploadFile(file *multipart.FileHeader) (string, error) {
-	s.logger.Info("Uploading file: " + file.Filename)
-	return file.Filename, nil
+// UploadFile 保存上传的文件
+// 返回：上传ID（执行请求的 files 参数和工作流节点的 files 字段按该ID引用文件），错误信息
+func (s *Service) UploadFile(header *multipart.FileHeader) (string, error) {
+	if limit := s.File.MaxUploadSize; limit > 0 && header.Size > limit {
+		return "", fmt.Errorf("文件大小 %d 字节超过上限 %d 字节", header.Size, limit)
+	}
+	return s.Uploads.Store(header)
 }
 
-func (s *Service) ExecuteCommand(cmd string, args []string) error {
-	s.logger.Info("Executing command: " + cmd + " " + strings.Join(args, " "))
-	return nil
+// ExecuteCommand 创建沙盒任务并提交到线程池执行
+// 未指定环境类型或命令时，执行器根据任务目录中的文件自动识别运行时和入口
+// 返回：任务ID，错误信息
+func (s *Service) ExecuteCommand(req interfaces.ExecuteRequest) (string, error) {
+	if s.Sandbox == nil {
+		return "", fmt.Errorf("沙盒执行器未初始化")
+	}
+
+	taskID := fmt.Sprintf("task_%d", time.Now().UnixNano())
+	name := req.Name
+	if name == "" {
+		name = strings.TrimSpace(req.Cmd + " " + strings.Join(req.Args, " "))
+	}
+	if name == "" {
+		// 未指定命令时由执行器根据任务目录中的文件自动识别入口
+		name = taskID
+	}
+	task := taskmanager.NewSandboxTask(&database.Task{
+		ID:             taskID,
+		Name:           name,
+		Creator:        req.Creator,
+		Priority:       req.Priority,
+		ExpectedMemory: req.ExpectedMemory,
+		Idempotent:     req.Idempotent,
+		RetryPolicy:    retryPolicy(req),
+	}, s.Sandbox, req.Cmd, req.Args, req.EnvType, req.EnvVersion)
+	task.Limits = execution.ResourceLimits{
+		Memory:   req.MemoryLimit,
+		CPUCores: req.CPUCores,
+		MaxPids:  req.MaxPids,
+	}
+	task.AllowNetwork = req.AllowNetwork
+
+	// 提交前复制上传的文件，执行器识别运行时和入口时文件已在任务目录中
+	if len(req.Files) > 0 {
+		taskDir, err := s.Sandbox.CreateTaskDirectory(taskID)
+		if err != nil {
+			return "", err
+		}
+		for _, uploadID := range req.Files {
+			if err := s.Uploads.CopyTo(uploadID, taskDir); err != nil {
+				s.Sandbox.CleanupTaskDirectory(taskID)
+				return "", err
+			}
+		}
+	}
+
+	if err := s.TaskManager.SubmitTask(task); err != nil {
+		return "", err
+	}
+	s.logger.Info("命令已提交执行", zap.String("task_id", taskID), zap.String("command", req.Cmd))
+	return taskID, nil
 }
 
 func (s *Service) GetCommandHelp() string {
 	return "Available commands: help, status, restart"
 }
 
+// UpdateTask 按任务状态机修改任务状态，不允许的转换返回 taskmanager.ErrInvalidTransition
 func (s *Service) UpdateTask(taskID string, req interfaces.UpdateTaskRequest) error {
 	return s.TaskManager.UpdateTask(taskID, req.Status)
 }
@@ -121,7 +216,359 @@ func (s *Service) GetTaskStats() (*interfaces.TaskStats, error) {
 }
 
 func (s *Service) GetThreadPoolStats() (*interfaces.ThreadPoolStats, error) {
-	return &interfaces.ThreadPoolStats{}, nil
+	poolStats, err := s.TaskManager.GetThreadPoolStats()
+	if err != nil {
+		return nil, err
+	}
+	return &interfaces.ThreadPoolStats{
+		TotalTasks:     poolStats.TotalTasks,
+		ActiveTasks:    poolStats.ActiveTasks,
+		CompletedTasks: poolStats.CompletedTasks,
+		QueueLength:    poolStats.QueueLength,
+		RejectedTasks:  poolStats.RejectedTasks,
+		TimeoutTasks:   poolStats.TimeoutTasks,
+		MaxWorkers:     poolStats.MaxWorkers,
+		MaxQueue:       poolStats.MaxQueue,
+		MinWorkers:     poolStats.MinWorkers,
+		CurrentWorkers: poolStats.CurrentWorkers,
+		IdleWorkers:    poolStats.IdleWorkers,
+		ScaleUps:       poolStats.ScaleUps,
+		ScaleDowns:     poolStats.ScaleDowns,
+		MemoryLimit:    poolStats.MemoryLimit,
+		MemoryInUse:    poolStats.MemoryInUse,
+		MemoryDeferred: poolStats.MemoryDeferred,
+		MemoryBlocked:  poolStats.MemoryBlocked,
+	}, nil
+}
+
+// ListEnvironments 列出已安装的运行时版本，包含可执行文件、占用空间、最近使用时间和冒烟测试结果
+// 返回：运行时清单，错误信息
+func (s *Service) ListEnvironments() ([]*interfaces.EnvironmentInfo, error) {
+	if s.Environments == nil {
+		return nil, fmt.Errorf("环境管理器未初始化")
+	}
+	envs, err := s.Environments.ListEnvironments()
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]*interfaces.EnvironmentInfo, 0, len(envs))
+	for _, env := range envs {
+		info := &interfaces.EnvironmentInfo{
+			EnvType:         env.EnvType,
+			Version:         env.Version,
+			Versioned:       env.Versioned,
+			Default:         env.Default,
+			Path:            env.Path,
+			Executable:      env.Executable,
+			DetectedVersion: env.DetectedVersion,
+			SizeBytes:       env.SizeBytes,
+			Healthy:         env.Healthy,
+			Error:           env.Error,
+		}
+		if env.InstalledAt != nil {
+			info.InstalledAt = env.InstalledAt.Unix()
+		}
+		if env.LastUsedAt != nil {
+			info.LastUsedAt = env.LastUsedAt.Unix()
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// SetDefaultEnvironment 设置运行时的默认版本，任务未指定版本时使用
+func (s *Service) SetDefaultEnvironment(envType, version string) error {
+	if s.Environments == nil {
+		return fmt.Errorf("环境管理器未初始化")
+	}
+	return s.Environments.SetDefaultVersion(envType, version)
+}
+
+// UninstallEnvironment 卸载运行时的指定版本，有任务正在使用时拒绝
+func (s *Service) UninstallEnvironment(envType, version string) error {
+	if s.Environments == nil {
+		return fmt.Errorf("环境管理器未初始化")
+	}
+	return s.Environments.UninstallEnvironment(envType, version)
+}
+
+// CollectEnvironmentGarbage 立即回收长时间未使用的Python虚拟环境缓存
+// 返回：回收结果，错误信息
+func (s *Service) CollectEnvironmentGarbage() (*interfaces.EnvironmentGCReport, error) {
+	if s.Environments == nil {
+		return nil, fmt.Errorf("环境管理器未初始化")
+	}
+	report, err := s.Environments.CollectGarbage()
+	if err != nil {
+		return nil, err
+	}
+	return &interfaces.EnvironmentGCReport{
+		Removed:        report.Removed,
+		Skipped:        report.Skipped,
+		ReclaimedBytes: report.ReclaimedBytes,
+	}, nil
+}
+
+func (s *Service) GetTaskQueuePosition(taskID string) (int, error) {
+	return s.TaskManager.GetQueuePosition(taskID)
+}
+
+// ListTasks 按条件分页查询任务
+func (s *Service) ListTasks(query interfaces.TaskQuery) (*interfaces.TaskList, error) {
+	filter := database.TaskFilter{
+		Statuses:       query.Statuses,
+		Creator:        query.Creator,
+		AssignedTo:     query.AssignedTo,
+		NameContains:   query.NameContains,
+		Runtime:        query.Runtime,
+		CreatedAfter:   unixTime(query.CreatedAfter),
+		CreatedBefore:  unixTime(query.CreatedBefore),
+		FinishedAfter:  unixTime(query.FinishedAfter),
+		FinishedBefore: unixTime(query.FinishedBefore),
+		Sort:           query.Sort,
+		Limit:          query.Size,
+		Cursor:         query.Cursor,
+		Page:           query.Page,
+	}
+	switch query.Order {
+	case "", "desc":
+	case "asc":
+		filter.Ascending = true
+	default:
+		return nil, fmt.Errorf("%w: 排序方向 %q 应为 asc 或 desc", database.ErrInvalidTaskFilter, query.Order)
+	}
+
+	page, err := s.TaskManager.ListTasks(filter)
+	if err != nil {
+		return nil, err
+	}
+	list := &interfaces.TaskList{Items: make([]*interfaces.Task, 0, len(page.Items)), Total: page.Total, NextCursor: page.NextCursor}
+	for _, task := range page.Items {
+		list.Items = append(list.Items, &interfaces.Task{
+			ID:             task.ID,
+			Name:           task.Name,
+			Status:         task.Status,
+			Creator:        task.Creator,
+			AssignedTo:     task.AssignedTo,
+			Description:    task.Description,
+			CreatedAt:      task.CreatedAt,
+			StartedAt:      task.StartedAt,
+			FinishedAt:     task.FinishedAt,
+			Duration:       task.Duration,
+			Priority:       task.Priority,
+			ExpectedMemory: task.ExpectedMemory,
+			Progress:       task.Progress,
+			ResultPath:     task.ResultPath,
+			Runtime:        task.Runtime,
+			RuntimeVersion: task.RuntimeVersion,
+			Command:        task.Command,
+			Args:           task.Args,
+			ExitCode:       task.ExitCode,
+			ErrorMessage:   task.ErrorMessage,
+			Attempt:        task.Attempt,
+		})
+	}
+	return list, nil
+}
+
+// GetTaskAttempts 查询任务每次执行的记录
+func (s *Service) GetTaskAttempts(taskID string) ([]*interfaces.TaskAttempt, error) {
+	attempts, err := s.TaskManager.GetAttempts(taskID)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*interfaces.TaskAttempt, 0, len(attempts))
+	for _, attempt := range attempts {
+		item := &interfaces.TaskAttempt{
+			Attempt:       attempt.Attempt,
+			Status:        attempt.Status,
+			FailureReason: attempt.FailureReason,
+			ExitCode:      attempt.ExitCode,
+			ErrorMessage:  attempt.ErrorMessage,
+			LogPath:       attempt.LogPath,
+			StartedAt:     attempt.StartedAt.Unix(),
+			Duration:      attempt.Duration,
+		}
+		if !attempt.FinishedAt.IsZero() {
+			item.FinishedAt = attempt.FinishedAt.Unix()
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// GetTaskEvents 按发生顺序查询任务的状态转换事件
+func (s *Service) GetTaskEvents(taskID string) ([]*interfaces.TaskEvent, error) {
+	events, err := s.TaskManager.GetTaskEvents(taskID)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*interfaces.TaskEvent, 0, len(events))
+	for _, event := range events {
+		items = append(items, &interfaces.TaskEvent{
+			From:      event.FromStatus,
+			To:        event.ToStatus,
+			Actor:     event.Actor,
+			Reason:    event.Reason,
+			CreatedAt: event.CreatedAt.Unix(),
+		})
+	}
+	return items, nil
+}
+
+// SubmitWorkflow 解析 YAML 或 JSON 格式的工作流定义并提交
+// 返回：工作流ID，错误信息（定义不合法时返回 workflow.ErrInvalidDefinition）
+func (s *Service) SubmitWorkflow(definition []byte, creator string) (string, error) {
+	def, err := workflow.ParseDefinition(definition)
+	if err != nil {
+		return "", err
+	}
+	return s.Workflows.Submit(def, creator)
+}
+
+// GetWorkflow 查询工作流及其节点的状态
+func (s *Service) GetWorkflow(workflowID string) (*interfaces.Workflow, error) {
+	snapshot, err := s.Workflows.Get(workflowID)
+	if err != nil {
+		return nil, err
+	}
+	wf := snapshot.Workflow
+	result := &interfaces.Workflow{
+		ID:           wf.ID,
+		Name:         wf.Name,
+		Status:       wf.Status,
+		OnFailure:    wf.OnFailure,
+		Creator:      wf.Creator,
+		ErrorMessage: wf.ErrorMessage,
+		CreatedAt:    wf.CreatedAt.Unix(),
+		Nodes:        make([]*interfaces.WorkflowNode, 0, len(snapshot.Nodes)),
+	}
+	if !wf.FinishedAt.IsZero() {
+		result.FinishedAt = wf.FinishedAt.Unix()
+	}
+	dependencies := make(map[string][]string, len(snapshot.Definition.Nodes))
+	for _, node := range snapshot.Definition.Nodes {
+		dependencies[node.ID] = node.DependsOn
+	}
+	for _, node := range snapshot.Nodes {
+		item := &interfaces.WorkflowNode{
+			ID:           node.NodeID,
+			Status:       node.Status,
+			TaskID:       node.TaskID,
+			DependsOn:    dependencies[node.NodeID],
+			ErrorMessage: node.ErrorMessage,
+		}
+		if !node.StartedAt.IsZero() {
+			item.StartedAt = node.StartedAt.Unix()
+		}
+		if !node.FinishedAt.IsZero() {
+			item.FinishedAt = node.FinishedAt.Unix()
+		}
+		result.Nodes = append(result.Nodes, item)
+	}
+	return result, nil
+}
+
+// CreateSchedule 解析 JSON 格式的定时任务定义并保存
+// 返回：定时任务，错误信息（定义不合法时返回 scheduler.ErrInvalidDefinition）
+func (s *Service) CreateSchedule(definition []byte, creator string) (*interfaces.Schedule, error) {
+	def, err := scheduler.ParseDefinition(definition)
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := s.Scheduler.Create(def, creator)
+	if err != nil {
+		return nil, err
+	}
+	return toSchedule(schedule), nil
+}
+
+// ListSchedules 查询全部定时任务
+func (s *Service) ListSchedules() ([]*interfaces.Schedule, error) {
+	schedules, err := s.Scheduler.List()
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*interfaces.Schedule, 0, len(schedules))
+	for i := range schedules {
+		items = append(items, toSchedule(&schedules[i]))
+	}
+	return items, nil
+}
+
+// PauseSchedule 暂停或恢复定时任务
+func (s *Service) PauseSchedule(scheduleID string, paused bool) error {
+	return s.Scheduler.SetPaused(scheduleID, paused)
+}
+
+// DeleteSchedule 删除定时任务，已提交的任务继续执行
+func (s *Service) DeleteSchedule(scheduleID string) error {
+	return s.Scheduler.Delete(scheduleID)
+}
+
+// GetScheduleRuns 查询定时任务最近的执行记录
+func (s *Service) GetScheduleRuns(scheduleID string, limit int) ([]*interfaces.ScheduleRun, error) {
+	runs, err := s.Scheduler.Runs(scheduleID, limit)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*interfaces.ScheduleRun, 0, len(runs))
+	for _, run := range runs {
+		items = append(items, &interfaces.ScheduleRun{
+			ScheduledAt: run.ScheduledAt.Unix(),
+			Status:      run.Status,
+			TaskID:      run.TaskID,
+			Message:     run.Message,
+			RecordedAt:  run.RecordedAt.Unix(),
+		})
+	}
+	return items, nil
+}
+
+// toSchedule 转换定时任务记录
+func toSchedule(schedule *database.Schedule) *interfaces.Schedule {
+	item := &interfaces.Schedule{
+		ID:         schedule.ID,
+		Name:       schedule.Name,
+		Cron:       schedule.CronExpr,
+		Timezone:   schedule.Timezone,
+		Task:       json.RawMessage(schedule.Template),
+		Overlap:    schedule.OverlapPolicy,
+		CatchUp:    schedule.CatchUpPolicy,
+		Paused:     schedule.Paused,
+		Creator:    schedule.Creator,
+		CreatedAt:  schedule.CreatedAt.Unix(),
+		LastTaskID: schedule.LastTaskID,
+	}
+	if !schedule.NextRunAt.IsZero() {
+		item.NextRunAt = schedule.NextRunAt.Unix()
+	}
+	if !schedule.LastRunAt.IsZero() {
+		item.LastRunAt = schedule.LastRunAt.Unix()
+	}
+	return item
+}
+
+// retryPolicy 请求中指定的重试策略，都未指定时返回 nil，使用 task_retry 配置
+func retryPolicy(req interfaces.ExecuteRequest) *database.RetryPolicy {
+	if req.MaxAttempts == 0 && req.RetryBackoff == 0 && req.RetryBackoffMax == 0 && req.RetryJitter == 0 && len(req.RetryOn) == 0 {
+		return nil
+	}
+	return &database.RetryPolicy{
+		MaxAttempts: req.MaxAttempts,
+		BackoffBase: req.RetryBackoff,
+		BackoffMax:  req.RetryBackoffMax,
+		Jitter:      req.RetryJitter,
+		RetryOn:     req.RetryOn,
+	}
+}
+
+// unixTime 将 Unix 秒转换为时间，0 表示不限制
+func unixTime(seconds int64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
 }
 
 func (s *Service) GracefulShutdown() {
@@ -0,0 +1,149 @@
+package taskmanager
+
+import (
+	"context"
+	"file-flow-service/database"
+	"file-flow-service/internal/threadpool"
+	"file-flow-service/sandbox/execution"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RecoveryReport 启动时恢复任务的结果
+type RecoveryReport struct {
+	// Reattached 重新接管的仍在运行的任务数
+	Reattached int
+	// Requeued 重新排队的任务数
+	Requeued int
+	// Interrupted 标记为 interrupted 的任务数
+	Interrupted int
+	// Deferred 未能提交到线程池（如线程池已停止）、在数据库中保持 queued 等待下次启动的任务数
+	Deferred int
+}
+
+// Recover 服务启动时根据数据库恢复上次未完成的任务
+// running 状态的任务：进程仍在运行时重新接管；进程已不存在时，可重复执行的任务重新排队，
+// 其他任务标记为 interrupted。pending、queued 和 retrying 状态的任务按优先级（相同优先级按创建时间）重新排队，
+// 这些任务在重启前已被接收，不受 max_queue 限制；提交失败时保持 queued，下次启动时再恢复，不标记为 interrupted。
+// 状态转换的发起方记录为 recovery
+// 重新接管的任务不占用线程池的工作线程，结束后照常写回最终状态
+// 参数: executor 沙盒执行器
+// 返回: 恢复结果，错误信息
+func (tm *taskManager) Recover(executor execution.SandboxExecutor) (*RecoveryReport, error) {
+	records, err := tm.repository.GetTasksByStatus(StatusRunning, StatusPending, StatusQueued, StatusRetrying)
+	if err != nil {
+		return nil, err
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	report := &RecoveryReport{}
+	var queued []*SandboxTask
+	for i := range records {
+		record := &records[i]
+		if _, exists := tm.tasks[record.ID]; exists {
+			continue
+		}
+		if record.Status == StatusRunning {
+			attempt := tm.currentAttempt(record)
+			if execution.ProcessAlive(record.PID, record.PIDStartTime) {
+				tm.reattach(RestoreSandboxTask(record, executor, attempt))
+				report.Reattached++
+				continue
+			}
+			tm.interruptAttempt(attempt, "服务重启时任务进程已不存在")
+			if !record.Idempotent {
+				tm.interrupt(record, "服务重启时任务进程已不存在")
+				report.Interrupted++
+				continue
+			}
+			resetForRetry(record)
+			tm.setStatus(record, StatusQueued, ActorRecovery, "服务重启时任务进程已不存在，重新排队")
+		}
+		// 等待重试的任务不再等待，直接重新排队
+		if record.Status != StatusQueued {
+			tm.setStatus(record, StatusQueued, ActorRecovery, "服务重启，重新排队")
+		}
+		queued = append(queued, RestoreSandboxTask(record, executor, nil))
+	}
+
+	// 记录已按创建时间排序，稳定排序后相同优先级的任务保持提交顺序
+	sort.SliceStable(queued, func(i, j int) bool {
+		return threadpool.ParsePriority(queued[i].Priority) > threadpool.ParsePriority(queued[j].Priority)
+	})
+	for _, task := range queued {
+		opts := jobOptions(task)
+		opts.Admitted = true
+		// 状态已转换为 queued，提交失败时同样保存，下次启动时仍按 queued 恢复
+		if err := tm.enqueue(task, opts); err != nil {
+			report.Deferred++
+		} else {
+			report.Requeued++
+		}
+		tm.saveTask(task)
+	}
+
+	tm.logger.Info("任务恢复完成",
+		zap.Int("reattached", report.Reattached),
+		zap.Int("requeued", report.Requeued),
+		zap.Int("interrupted", report.Interrupted),
+		zap.Int("deferred", report.Deferred))
+	return report, nil
+}
+
+// reattach 在独立的 goroutine 中等待重新接管的任务结束，调用方需持有 tm.mu
+func (tm *taskManager) reattach(task *SandboxTask) {
+	taskCtx, cancel := context.WithCancel(context.Background())
+	tm.register(task, cancel)
+	tm.logger.Info("重新接管运行中的任务", zap.String("task_id", task.ID), zap.Int("pid", task.PID))
+	go tm.runTask(taskCtx, task)(context.Background())
+}
+
+// interrupt 将任务标记为 interrupted 并保存
+func (tm *taskManager) interrupt(record *database.Task, reason string) {
+	if err := tm.setStatus(record, StatusInterrupted, ActorRecovery, reason); err != nil {
+		return
+	}
+	now := time.Now().Unix()
+	record.ErrorMessage = reason
+	record.FinishedAt = now
+	if record.StartedAt > 0 {
+		record.Duration = now - record.StartedAt
+	}
+	record.PID = 0
+	record.PIDStartTime = 0
+	if err := tm.repository.UpdateTask(record); err != nil {
+		tm.logger.Error("保存任务状态失败", zap.String("task_id", record.ID), zap.Error(err))
+		return
+	}
+	tm.logger.Warn("任务已中断", zap.String("task_id", record.ID), zap.String("reason", reason))
+	tm.notifyFinished(record.ID, StatusInterrupted)
+}
+
+// interruptAttempt 将服务重启时未结束的执行记录标记为 interrupted
+func (tm *taskManager) interruptAttempt(attempt *database.TaskAttempt, reason string) {
+	if attempt.Status != StatusRunning {
+		return
+	}
+	now := time.Now()
+	attempt.Status = StatusInterrupted
+	attempt.FailureReason = "interrupted"
+	attempt.ErrorMessage = reason
+	attempt.FinishedAt = now
+	attempt.Duration = int64(now.Sub(attempt.StartedAt).Seconds())
+	tm.saveAttempt(attempt)
+}
+
+// resetForRetry 清除上次执行的结果，任务按新任务重新排队，状态由调用方转换
+func resetForRetry(record *database.Task) {
+	record.StartedAt = 0
+	record.FinishedAt = 0
+	record.Duration = 0
+	record.ExitCode = nil
+	record.ErrorMessage = ""
+	record.PID = 0
+	record.PIDStartTime = 0
+}
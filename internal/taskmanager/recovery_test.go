@@ -0,0 +1,63 @@
+package taskmanager
+
+import (
+	"testing"
+
+	"file-flow-service/config"
+	"file-flow-service/database"
+)
+
+// seedQueuedTasks 写入服务停止前已排队的任务
+func seedQueuedTasks(t *testing.T, repository *database.Repository, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		record := &database.Task{ID: id, Name: id, Status: StatusQueued, Creator: "alice", Runtime: "python", Command: "main.py"}
+		if err := repository.CreateTask(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRecoverIgnoresQueueLimit(t *testing.T) {
+	tm, repository, finished := newTestManager(t, config.Threadpool{MaxWorkers: 1, MaxQueue: 1})
+	ids := []string{"task_1", "task_2", "task_3", "task_4"}
+	seedQueuedTasks(t, repository, ids...)
+
+	executor := newStubExecutor()
+	report, err := tm.Recover(executor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Requeued != len(ids) || report.Interrupted != 0 || report.Deferred != 0 {
+		t.Fatalf("恢复结果为 %+v，应全部重新排队", *report)
+	}
+
+	executor.waitStarted(t, 1)
+	close(executor.release)
+	statuses := waitFinished(t, finished, len(ids))
+	for _, id := range ids {
+		if statuses[id] != StatusSucceeded {
+			t.Errorf("任务 %s 的最终状态为 %s，应为 %s", id, statuses[id], StatusSucceeded)
+		}
+	}
+}
+
+func TestRecoverKeepsTasksQueuedWhenPoolStopped(t *testing.T) {
+	tm, repository, _ := newTestManager(t, config.Threadpool{MaxWorkers: 1})
+	ids := []string{"task_1", "task_2"}
+	seedQueuedTasks(t, repository, ids...)
+	tm.threadpool.Stop()
+
+	report, err := tm.Recover(newStubExecutor())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Deferred != len(ids) || report.Interrupted != 0 {
+		t.Fatalf("恢复结果为 %+v，提交失败的任务应保持排队", *report)
+	}
+	for _, id := range ids {
+		if status := taskStatus(t, repository, id); status != StatusQueued {
+			t.Errorf("任务 %s 的状态为 %s，应保持 %s", id, status, StatusQueued)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package taskmanager
+
+import (
+	"context"
+	"errors"
+	"file-flow-service/database"
+	"file-flow-service/internal/service/interfaces"
+	"file-flow-service/sandbox/execution"
+	"math/rand"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultRetryBackoffBase 未配置 task_retry.backoff_base 时第一次重试前的等待时间
+	defaultRetryBackoffBase = 10 * time.Second
+	// defaultRetryBackoffMax 未配置 task_retry.backoff_max 时重试等待时间的上限
+	defaultRetryBackoffMax = 10 * time.Minute
+	// failureError 执行出错（运行时不可用、进程无法启动等）的失败原因
+	failureError = "error"
+)
+
+// defaultRetryOn 未配置 task_retry.retry_on 时可重试的失败原因
+var defaultRetryOn = []string{"timeout", execution.FailureOutOfMemory, execution.FailureNonZeroExit}
+
+// attemptOutcome 根据执行错误确定本次执行的状态和失败原因
+// 只有执行返回错误时才参考 ctx：执行成功之后 ctx 才到期或被取消不影响结果。
+// taskCancelled 表示任务通过 CancelTask 取消；其余取消来自线程池强制停止（服务关闭），记为 interrupted
+// 失败原因：timeout/cancelled/interrupted，或 out_of_memory/non_zero_exit/seccomp_violation/error
+func attemptOutcome(ctx context.Context, err error, taskCancelled bool) (status, reason string) {
+	if err == nil {
+		return StatusSucceeded, ""
+	}
+	switch {
+	case errors.Is(err, execution.ErrExecutionTimeout) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return StatusTimeout, "timeout"
+	case errors.Is(err, execution.ErrExecutionCancelled) || errors.Is(ctx.Err(), context.Canceled):
+		if taskCancelled {
+			return StatusCancelled, "cancelled"
+		}
+		return StatusInterrupted, "interrupted"
+	case errors.Is(err, execution.ErrExitStatusUnknown):
+		return StatusInterrupted, "interrupted"
+	case errors.Is(err, execution.ErrSeccompViolation):
+		return StatusFailed, execution.FailureSeccompViolation
+	case errors.Is(err, execution.ErrOutOfMemory):
+		return StatusFailed, execution.FailureOutOfMemory
+	case errors.Is(err, execution.ErrNonZeroExit):
+		return StatusFailed, execution.FailureNonZeroExit
+	}
+	return StatusFailed, failureError
+}
+
+// retryPolicy 任务生效的重试策略：任务指定的字段优先，其余使用 task_retry 配置
+func (tm *taskManager) retryPolicy(record *database.Task) database.RetryPolicy {
+	cfg := tm.config.TaskRetry
+	policy := database.RetryPolicy{
+		MaxAttempts: cfg.MaxAttempts,
+		BackoffBase: defaultRetryBackoffBase,
+		BackoffMax:  defaultRetryBackoffMax,
+		Jitter:      cfg.Jitter,
+		RetryOn:     cfg.RetryOn,
+	}
+	// 配置加载时已校验格式
+	if base, err := time.ParseDuration(cfg.BackoffBase); err == nil {
+		policy.BackoffBase = base
+	}
+	if max, err := time.ParseDuration(cfg.BackoffMax); err == nil {
+		policy.BackoffMax = max
+	}
+	if len(policy.RetryOn) == 0 {
+		policy.RetryOn = defaultRetryOn
+	}
+
+	if custom := record.RetryPolicy; custom != nil {
+		if custom.MaxAttempts > 0 {
+			policy.MaxAttempts = custom.MaxAttempts
+		}
+		if custom.BackoffBase > 0 {
+			policy.BackoffBase = custom.BackoffBase
+		}
+		if custom.BackoffMax > 0 {
+			policy.BackoffMax = custom.BackoffMax
+		}
+		if custom.Jitter > 0 {
+			policy.Jitter = custom.Jitter
+		}
+		if len(custom.RetryOn) > 0 {
+			policy.RetryOn = custom.RetryOn
+		}
+	}
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	return policy
+}
+
+// retryBackoff 第 attempt 次执行失败后的等待时间
+// 从 BackoffBase 开始每次翻倍，不超过 BackoffMax，再按 Jitter 随机浮动
+func retryBackoff(policy database.RetryPolicy, attempt int) time.Duration {
+	delay := policy.BackoffBase
+	for i := 1; i < attempt && delay < policy.BackoffMax; i++ {
+		delay *= 2
+	}
+	if policy.BackoffMax > 0 && delay > policy.BackoffMax {
+		delay = policy.BackoffMax
+	}
+	if policy.Jitter > 0 {
+		delay += time.Duration(float64(delay) * policy.Jitter * (2*rand.Float64() - 1))
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return delay
+}
+
+// retryDelay 判断失败的任务是否重试
+// 返回: 重试前的等待时间，是否重试（失败原因不在重试条件中或已达到最多执行次数时不重试）
+func (tm *taskManager) retryDelay(task interfaces.TaskInterface, reason string) (time.Duration, bool) {
+	record, ok := task.(taskRecord)
+	if !ok || reason == "" {
+		return 0, false
+	}
+	r := record.Record()
+	policy := tm.retryPolicy(r)
+	if r.Attempt >= policy.MaxAttempts || !containsString(policy.RetryOn, reason) {
+		return 0, false
+	}
+	return retryBackoff(policy, r.Attempt), true
+}
+
+// scheduleRetry 等待 delay 后将任务重新提交到线程池，调用方需持有 tm.mu
+// 等待期间任务状态为 retrying，仍登记取消函数，可以被 CancelTask 取消
+func (tm *taskManager) scheduleRetry(task interfaces.TaskInterface, delay time.Duration) {
+	taskCtx, cancel := context.WithCancel(context.Background())
+	tm.cancels[task.GetID()] = cancel
+	tm.logger.Info("任务将在等待后重试", zap.String("task_id", task.GetID()), zap.Duration("delay", delay))
+
+	time.AfterFunc(delay, func() {
+		tm.mu.Lock()
+		defer tm.mu.Unlock()
+
+		// 等待期间被取消时 CancelTask 已写回状态
+		if taskCtx.Err() != nil {
+			return
+		}
+		if err := tm.setStatus(task, StatusQueued, ActorRetry, "等待结束，重新排队"); err != nil {
+			// 状态已被改变，不再重新提交
+			cancel()
+			delete(tm.cancels, task.GetID())
+			tm.activeTaskCount--
+			return
+		}
+		tm.saveTask(task)
+		if err := tm.threadpool.SubmitWithOptions(tm.runTask(taskCtx, task), jobOptions(task)); err != nil {
+			cancel()
+			delete(tm.cancels, task.GetID())
+			tm.activeTaskCount--
+			tm.logger.Error("重试的任务提交到线程池失败", zap.String("task_id", task.GetID()), zap.Error(err))
+			message := "重试时无法提交到线程池: " + err.Error()
+			tm.setStatus(task, StatusFailed, ActorRetry, message)
+			task.SetFinishedAt(time.Now().Unix())
+			if record, ok := task.(taskRecord); ok {
+				record.Record().ErrorMessage = message
+			}
+			tm.saveTask(task)
+			tm.notifyFinished(task.GetID(), StatusFailed)
+		}
+	})
+}
+
+// beginAttempt 开始一次执行：递增执行次数，清除上次执行的结果并保存执行记录
+// 重新接管的任务沿用进程启动时的执行记录；任务没有完整记录时返回 nil
+func (tm *taskManager) beginAttempt(task interfaces.TaskInterface, startedAt time.Time) *database.TaskAttempt {
+	record, ok := task.(taskRecord)
+	if !ok {
+		return nil
+	}
+	sandboxTask, _ := task.(*SandboxTask)
+	if sandboxTask != nil && sandboxTask.attach && sandboxTask.attempt != nil {
+		return sandboxTask.attempt
+	}
+
+	r := record.Record()
+	r.Attempt++
+	r.ExitCode = nil
+	r.ErrorMessage = ""
+	r.ResultPath = ""
+	attempt := &database.TaskAttempt{
+		TaskID:    r.ID,
+		Attempt:   r.Attempt,
+		Status:    StatusRunning,
+		StartedAt: startedAt,
+	}
+	if sandboxTask != nil {
+		sandboxTask.attempt = attempt
+		sandboxTask.Result = nil
+	}
+	tm.saveAttempt(attempt)
+	return attempt
+}
+
+// finishAttempt 记录本次执行的结果
+func (tm *taskManager) finishAttempt(task interfaces.TaskInterface, attempt *database.TaskAttempt, status, reason string, finishedAt time.Time) {
+	if attempt == nil {
+		return
+	}
+	r := task.(taskRecord).Record()
+	attempt.Status = status
+	attempt.FailureReason = reason
+	attempt.ExitCode = r.ExitCode
+	attempt.ErrorMessage = r.ErrorMessage
+	attempt.LogPath = r.ResultPath
+	attempt.FinishedAt = finishedAt
+	attempt.Duration = int64(finishedAt.Sub(attempt.StartedAt).Seconds())
+	tm.saveAttempt(attempt)
+}
+
+// saveAttempt 保存执行记录，失败只记录日志
+// 升级前启动、重新接管的任务没有执行记录（Attempt 为 0），不保存
+func (tm *taskManager) saveAttempt(attempt *database.TaskAttempt) {
+	if attempt.Attempt == 0 {
+		return
+	}
+	if err := tm.repository.SaveAttempt(attempt); err != nil {
+		tm.logger.Error("保存任务执行记录失败", zap.String("task_id", attempt.TaskID), zap.Error(err))
+	}
+}
+
+// currentAttempt 查询任务最近一次执行的记录，没有时按任务记录构造
+func (tm *taskManager) currentAttempt(record *database.Task) *database.TaskAttempt {
+	if record.Attempt > 0 {
+		attempts, err := tm.repository.GetAttempts(record.ID)
+		if err != nil {
+			tm.logger.Warn("查询任务执行记录失败", zap.String("task_id", record.ID), zap.Error(err))
+		}
+		for i := range attempts {
+			if attempts[i].Attempt == record.Attempt {
+				return &attempts[i]
+			}
+		}
+	}
+	return &database.TaskAttempt{
+		TaskID:    record.ID,
+		Attempt:   record.Attempt,
+		Status:    StatusRunning,
+		StartedAt: time.Unix(record.StartedAt, 0),
+	}
+}
+
+// containsString 判断列表中是否包含指定字符串
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package taskmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"file-flow-service/sandbox/execution"
+)
+
+func TestAttemptOutcome(t *testing.T) {
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	active := context.Background()
+
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		err           error
+		taskCancelled bool
+		wantStatus    string
+		wantReason    string
+	}{
+		{"成功", active, nil, false, StatusSucceeded, ""},
+		// 执行已成功返回，之后 ctx 才到期或被取消不影响结果
+		{"成功后到期", expired, nil, false, StatusSucceeded, ""},
+		{"成功后取消", cancelled, nil, true, StatusSucceeded, ""},
+		{"执行超时", active, execution.ErrExecutionTimeout, false, StatusTimeout, "timeout"},
+		{"线程池超时", expired, execution.ErrExecutionCancelled, false, StatusTimeout, "timeout"},
+		{"任务被取消", cancelled, execution.ErrExecutionCancelled, true, StatusCancelled, "cancelled"},
+		{"开始前被取消", cancelled, context.Canceled, true, StatusCancelled, "cancelled"},
+		{"线程池强制停止", cancelled, execution.ErrExecutionCancelled, false, StatusInterrupted, "interrupted"},
+		{"无法确定结果", active, execution.ErrExitStatusUnknown, false, StatusInterrupted, "interrupted"},
+		{"违反系统调用过滤", active, fmt.Errorf("执行失败: %w", execution.ErrSeccompViolation), false, StatusFailed, execution.FailureSeccompViolation},
+		{"内存超限", active, execution.ErrOutOfMemory, false, StatusFailed, execution.FailureOutOfMemory},
+		{"非零退出码", active, execution.ErrNonZeroExit, false, StatusFailed, execution.FailureNonZeroExit},
+		{"其他错误", active, errors.New("运行时不可用"), false, StatusFailed, failureError},
+		// ctx 到期后进程被终止，退出码非零，按超时处理
+		{"到期后非零退出", expired, execution.ErrNonZeroExit, false, StatusTimeout, "timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, reason := attemptOutcome(tt.ctx, tt.err, tt.taskCancelled)
+			if status != tt.wantStatus || reason != tt.wantReason {
+				t.Fatalf("得到 %s/%s，应为 %s/%s", status, reason, tt.wantStatus, tt.wantReason)
+			}
+		})
+	}
+}
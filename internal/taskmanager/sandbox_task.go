@@ -0,0 +1,145 @@
+package taskmanager
+
+import (
+	"context"
+	"file-flow-service/database"
+	"file-flow-service/internal/threadpool"
+	"file-flow-service/sandbox/execution"
+	"path/filepath"
+)
+
+// SandboxTask 在沙盒执行器中运行命令的任务
+type SandboxTask struct {
+	*database.Task
+	// Command 要执行的命令或脚本，为空时自动识别入口
+	Command string
+	// Args 命令参数
+	Args []string
+	// EnvType 运行环境类型（python/java 等），为空时根据任务文件自动识别
+	EnvType string
+	// EnvVersion 运行环境版本
+	EnvVersion string
+	// Limits 任务指定的资源限制，零值字段使用配置中的默认值
+	Limits execution.ResourceLimits
+	// AllowNetwork namespace 隔离模式下是否允许访问网络
+	AllowNetwork bool
+	// Result 最近一次执行的结果
+	Result *execution.ExecutionResult
+
+	executor execution.SandboxExecutor
+	// attach 为 true 时重新接管记录中仍在运行的任务进程，而不是启动新进程
+	attach bool
+	// attempt 当前这次执行的记录，日志写入该次执行的目录
+	attempt *database.TaskAttempt
+	// onProcessStart 任务进程启动后调用，用于及时保存进程ID
+	onProcessStart func()
+}
+
+// NewSandboxTask 创建沙盒任务
+// 参数：task 任务记录, executor 沙盒执行器, cmd 命令, args 参数, envType 环境类型, envVersion 环境版本
+func NewSandboxTask(task *database.Task, executor execution.SandboxExecutor, cmd string, args []string, envType, envVersion string) *SandboxTask {
+	task.Runtime = envType
+	task.RuntimeVersion = envVersion
+	task.Command = cmd
+	task.Args = args
+	return &SandboxTask{
+		Task:       task,
+		Command:    cmd,
+		Args:       args,
+		EnvType:    envType,
+		EnvVersion: envVersion,
+		executor:   executor,
+	}
+}
+
+// RestoreSandboxTask 根据数据库中的任务记录重建沙盒任务，用于服务重启后恢复任务
+// 参数：task 任务记录, executor 沙盒执行器, attempt 重新接管的进程所属的执行记录，为 nil 时重新执行任务
+func RestoreSandboxTask(task *database.Task, executor execution.SandboxExecutor, attempt *database.TaskAttempt) *SandboxTask {
+	return &SandboxTask{
+		Task:       task,
+		Command:    task.Command,
+		Args:       task.Args,
+		EnvType:    task.Runtime,
+		EnvVersion: task.RuntimeVersion,
+		Limits: execution.ResourceLimits{
+			Memory:   task.MemoryLimit,
+			CPUCores: task.CPUCores,
+			MaxPids:  task.MaxPids,
+		},
+		AllowNetwork: task.AllowNetwork,
+		executor:     executor,
+		attach:       attempt != nil,
+		attempt:      attempt,
+	}
+}
+
+// Record 返回任务记录，并同步执行参数，服务重启后可按原参数重新排队
+func (t *SandboxTask) Record() *database.Task {
+	t.Task.MemoryLimit = t.Limits.Memory
+	t.Task.CPUCores = t.Limits.CPUCores
+	t.Task.MaxPids = t.Limits.MaxPids
+	t.Task.AllowNetwork = t.AllowNetwork
+	return t.Task
+}
+
+// Execute 在任务目录中执行命令，任务进程会上报给线程池用于内存采样
+// 执行结果的输出目录记录为任务的结果路径
+func (t *SandboxTask) Execute(ctx context.Context) error {
+	taskDir, err := t.executor.CreateTaskDirectory(t.ID)
+	if err != nil {
+		return err
+	}
+
+	attempt := 0
+	if t.attempt != nil {
+		attempt = t.attempt.Attempt
+	}
+	if t.attach {
+		t.attach = false
+		result, err := t.executor.AttachTask(ctx, execution.AttachRequest{
+			TaskID:    t.ID,
+			PID:       t.PID,
+			StartTime: t.PIDStartTime,
+			StartedAt: t.attempt.StartedAt,
+			Attempt:   t.attempt.Attempt,
+		})
+		if result != nil {
+			t.Result = result
+			t.ResultPath = filepath.Dir(result.StdoutPath)
+		}
+		return err
+	}
+
+	execCtx := execution.WithProcessObserver(ctx, func(pid int) {
+		threadpool.ReportProcess(ctx, pid)
+		t.Task.PID = pid
+		t.Task.PIDStartTime, _ = execution.ProcessStartTime(pid)
+		if t.onProcessStart != nil {
+			t.onProcessStart()
+		}
+	})
+	result, err := t.executor.ExecuteTask(execCtx, execution.ExecutionRequest{
+		TaskID:       t.ID,
+		TaskDir:      taskDir,
+		Command:      t.Command,
+		Args:         t.Args,
+		EnvType:      t.EnvType,
+		EnvVersion:   t.EnvVersion,
+		Limits:       t.Limits,
+		AllowNetwork: t.AllowNetwork,
+		Attempt:      attempt,
+	})
+	if result != nil {
+		t.Result = result
+		t.ResultPath = filepath.Dir(result.StdoutPath)
+		// 未指定运行时的任务记录自动识别出的运行时
+		if result.EnvType != "" {
+			t.Task.Runtime = result.EnvType
+		}
+		if result.ExitCode >= 0 {
+			exitCode := result.ExitCode
+			t.Task.ExitCode = &exitCode
+		}
+	}
+	return err
+}
@@ -0,0 +1,144 @@
+package taskmanager
+
+import (
+	"errors"
+	"file-flow-service/database"
+	"file-flow-service/internal/service/interfaces"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 任务状态
+// pending → queued → running → succeeded/failed/cancelled/timeout/interrupted；
+// 失败后满足重试策略时 running → retrying → queued，服务重启时 running/retrying 的任务可能重新排队
+const (
+	StatusPending     = "pending"
+	StatusQueued      = "queued"
+	StatusRunning     = "running"
+	StatusRetrying    = "retrying"
+	StatusSucceeded   = "succeeded"
+	StatusFailed      = "failed"
+	StatusCancelled   = "cancelled"
+	StatusTimeout     = "timeout"
+	StatusInterrupted = "interrupted"
+)
+
+// 状态转换的发起方
+const (
+	// ActorSystem 任务管理器根据执行结果转换状态
+	ActorSystem = "system"
+	// ActorRecovery 服务启动时恢复任务
+	ActorRecovery = "recovery"
+	// ActorRetry 失败重试
+	ActorRetry = "retry"
+	// ActorAPI 通过接口修改任务状态
+	ActorAPI = "api"
+	// ActorWorkflow 工作流引擎（fail_fast 时取消其余节点）
+	ActorWorkflow = "workflow"
+	// ActorScheduler 定时任务调度器（replace 策略取消上一次的任务）
+	ActorScheduler = "scheduler"
+)
+
+var (
+	// ErrUnknownStatus 不是定义的任务状态
+	ErrUnknownStatus = errors.New("未知的任务状态")
+	// ErrInvalidTransition 状态机不允许的状态转换
+	ErrInvalidTransition = errors.New("非法的任务状态转换")
+)
+
+// TransitionError 状态转换被拒绝，errors.Is 可与 ErrInvalidTransition 匹配
+type TransitionError struct {
+	TaskID string
+	From   string
+	To     string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%v: 任务 %s 不能从 %s 转换为 %s", ErrInvalidTransition, e.TaskID, e.From, e.To)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
+
+// transitions 每个状态允许转换到的状态，结束状态不能再转换
+var transitions = map[string][]string{
+	StatusPending: {StatusQueued, StatusCancelled},
+	// 排队中的任务被取消；重试时无法提交到线程池标记为 failed；服务重启后无法重新排队标记为 interrupted
+	StatusQueued: {StatusRunning, StatusCancelled, StatusFailed, StatusInterrupted},
+	// 服务重启时进程已不存在、可重复执行的任务重新排队
+	StatusRunning:     {StatusSucceeded, StatusFailed, StatusCancelled, StatusTimeout, StatusInterrupted, StatusRetrying, StatusQueued},
+	StatusRetrying:    {StatusQueued, StatusCancelled},
+	StatusSucceeded:   nil,
+	StatusFailed:      nil,
+	StatusCancelled:   nil,
+	StatusTimeout:     nil,
+	StatusInterrupted: nil,
+}
+
+// ValidStatus 是否为定义的任务状态
+func ValidStatus(status string) bool {
+	_, ok := transitions[status]
+	return ok
+}
+
+// IsFinal 任务是否已结束（不会再执行或重试）
+func IsFinal(status string) bool {
+	next, ok := transitions[status]
+	return ok && len(next) == 0
+}
+
+// CanTransition 状态机是否允许从 from 转换为 to
+func CanTransition(from, to string) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTransition 校验状态转换
+// 返回: 错误信息（目标状态未定义时返回 ErrUnknownStatus，不允许转换时返回 *TransitionError）
+func checkTransition(taskID, from, to string) error {
+	if !ValidStatus(to) {
+		return fmt.Errorf("%w: %s", ErrUnknownStatus, to)
+	}
+	if !CanTransition(from, to) {
+		return &TransitionError{TaskID: taskID, From: from, To: to}
+	}
+	return nil
+}
+
+// setStatus 校验并转换任务状态，记录状态转换事件；任务记录由调用方保存
+// 调用方需持有 tm.mu，校验和转换之间状态不会被 CancelTask 等其他发起方修改
+// 参数: task 任务, to 目标状态, actor 发起方, reason 原因
+// 返回: 错误信息（转换被拒绝时任务状态不变）
+func (tm *taskManager) setStatus(task interfaces.TaskInterface, to, actor, reason string) error {
+	from := task.GetStatus()
+	if err := checkTransition(task.GetID(), from, to); err != nil {
+		tm.logger.Warn("拒绝任务状态转换", zap.String("task_id", task.GetID()), zap.String("from", from),
+			zap.String("to", to), zap.String("actor", actor), zap.Error(err))
+		return err
+	}
+	task.SetStatus(to)
+	tm.recordEvent(task.GetID(), from, to, actor, reason)
+	return nil
+}
+
+// recordEvent 保存状态转换事件，失败只记录日志
+func (tm *taskManager) recordEvent(taskID, from, to, actor, reason string) {
+	event := &database.TaskEvent{
+		TaskID:     taskID,
+		FromStatus: from,
+		ToStatus:   to,
+		Actor:      actor,
+		Reason:     reason,
+		CreatedAt:  time.Now(),
+	}
+	if err := tm.repository.SaveTaskEvent(event); err != nil {
+		tm.logger.Error("保存任务状态转换事件失败", zap.String("task_id", taskID), zap.Error(err))
+	}
+}
@@ -0,0 +1,248 @@
+package taskmanager
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"file-flow-service/config"
+	"file-flow-service/database"
+)
+
+// allStatuses 全部任务状态
+var allStatuses = []string{
+	StatusPending, StatusQueued, StatusRunning, StatusRetrying,
+	StatusSucceeded, StatusFailed, StatusCancelled, StatusTimeout, StatusInterrupted,
+}
+
+func TestFinalStatuses(t *testing.T) {
+	final := map[string]bool{
+		StatusSucceeded:   true,
+		StatusFailed:      true,
+		StatusCancelled:   true,
+		StatusTimeout:     true,
+		StatusInterrupted: true,
+	}
+	for _, status := range allStatuses {
+		if !ValidStatus(status) {
+			t.Errorf("%s 应为定义的状态", status)
+		}
+		if got := IsFinal(status); got != final[status] {
+			t.Errorf("IsFinal(%s) = %v，应为 %v", status, got, final[status])
+		}
+	}
+	for _, status := range []string{"", "completed", "done", "RUNNING"} {
+		if ValidStatus(status) || IsFinal(status) {
+			t.Errorf("%q 不是定义的状态", status)
+		}
+	}
+}
+
+func TestCheckTransition(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		wantErr  error
+	}{
+		{"允许的转换", StatusQueued, StatusRunning, nil},
+		// 空状态不在状态机中，创建任务的事件由 SubmitTask 直接记录
+		{"从空状态转换", "", StatusPending, ErrInvalidTransition},
+		{"未知的目标状态", StatusRunning, "completed", ErrUnknownStatus},
+		{"跳过排队", StatusPending, StatusRunning, ErrInvalidTransition},
+		{"结束状态不能再转换", StatusSucceeded, StatusRunning, ErrInvalidTransition},
+		{"结束状态之间不能转换", StatusFailed, StatusCancelled, ErrInvalidTransition},
+		{"等待重试不能直接执行", StatusRetrying, StatusRunning, ErrInvalidTransition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkTransition("task_1", tt.from, tt.to)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("应允许转换，得到 %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("应返回 %v，得到 %v", tt.wantErr, err)
+			}
+			if tt.wantErr == ErrInvalidTransition {
+				var transitionErr *TransitionError
+				if !errors.As(err, &transitionErr) {
+					t.Fatalf("拒绝转换时应返回 *TransitionError，得到 %T", err)
+				}
+				if transitionErr.TaskID != "task_1" || transitionErr.From != tt.from || transitionErr.To != tt.to {
+					t.Errorf("TransitionError 为 %+v", *transitionErr)
+				}
+			}
+		})
+	}
+}
+
+func TestSetStatusRejectsInvalidTransition(t *testing.T) {
+	tm, repository, _ := newTestManager(t, config.Threadpool{MaxWorkers: 1})
+	tests := []struct {
+		name     string
+		from, to string
+	}{
+		{"结束状态不能再执行", StatusSucceeded, StatusRunning},
+		{"未排队不能执行", StatusPending, StatusRunning},
+		{"等待重试不能直接执行", StatusRetrying, StatusRunning},
+		{"结束状态之间不能转换", StatusFailed, StatusCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &database.Task{ID: "task_" + tt.from, Status: tt.from}
+			if err := repository.CreateTask(task); err != nil {
+				t.Fatal(err)
+			}
+			err := tm.setStatus(task, tt.to, ActorAPI, "测试")
+			if !errors.Is(err, ErrInvalidTransition) {
+				t.Fatalf("应返回 %v，得到 %v", ErrInvalidTransition, err)
+			}
+			var transitionErr *TransitionError
+			if !errors.As(err, &transitionErr) || transitionErr.From != tt.from || transitionErr.To != tt.to {
+				t.Fatalf("应返回 %s -> %s 的 *TransitionError，得到 %v", tt.from, tt.to, err)
+			}
+			if task.GetStatus() != tt.from {
+				t.Errorf("转换被拒绝后状态为 %s，应保持 %s", task.GetStatus(), tt.from)
+			}
+			events, err := repository.GetTaskEvents(task.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != 0 {
+				t.Errorf("转换被拒绝时不应记录事件，得到 %+v", events)
+			}
+		})
+	}
+}
+
+func TestSetStatusRecordsEvent(t *testing.T) {
+	tm, repository, _ := newTestManager(t, config.Threadpool{MaxWorkers: 1})
+	task := &database.Task{ID: "task_1", Status: StatusRunning}
+	if err := repository.CreateTask(task); err != nil {
+		t.Fatal(err)
+	}
+	if err := tm.setStatus(task, StatusCancelled, ActorAPI, "用户取消"); err != nil {
+		t.Fatal(err)
+	}
+	if task.GetStatus() != StatusCancelled {
+		t.Fatalf("状态为 %s，应为 %s", task.GetStatus(), StatusCancelled)
+	}
+	events, err := repository.GetTaskEvents(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("应记录 1 条事件，得到 %+v", events)
+	}
+	e := events[0]
+	if e.FromStatus != StatusRunning || e.ToStatus != StatusCancelled || e.Actor != ActorAPI || e.Reason != "用户取消" {
+		t.Errorf("事件为 %+v", e)
+	}
+}
+
+// transitionsOf 按时间顺序返回任务的状态转换事件
+func transitionsOf(t *testing.T, repository *database.Repository, taskID string) []database.TaskEvent {
+	t.Helper()
+	events, err := repository.GetTaskEvents(taskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestLifecycleRecordsOneEventPerTransition(t *testing.T) {
+	tm, repository, finished := newTestManager(t, config.Threadpool{MaxWorkers: 1})
+	executor := newStubExecutor()
+	close(executor.release)
+	task := NewSandboxTask(&database.Task{ID: "task_1", Name: "report", Creator: "alice"}, executor, "main.py", nil, "python", "")
+	if err := tm.SubmitTask(task); err != nil {
+		t.Fatal(err)
+	}
+	if statuses := waitFinished(t, finished, 1); statuses["task_1"] != StatusSucceeded {
+		t.Fatalf("最终状态为 %s，应为 %s", statuses["task_1"], StatusSucceeded)
+	}
+
+	want := [][2]string{
+		{"", StatusPending},
+		{StatusPending, StatusQueued},
+		{StatusQueued, StatusRunning},
+		{StatusRunning, StatusSucceeded},
+	}
+	events := transitionsOf(t, repository, "task_1")
+	if len(events) != len(want) {
+		t.Fatalf("应记录 %d 条事件，得到 %+v", len(want), events)
+	}
+	for i, e := range events {
+		if e.FromStatus != want[i][0] || e.ToStatus != want[i][1] {
+			t.Errorf("第 %d 条事件为 %s -> %s，应为 %s -> %s", i+1, e.FromStatus, e.ToStatus, want[i][0], want[i][1])
+		}
+		if e.Actor != ActorSystem || e.Reason == "" {
+			t.Errorf("第 %d 条事件的发起方为 %q、原因为 %q", i+1, e.Actor, e.Reason)
+		}
+	}
+}
+
+func TestCancelRunningTaskRecordsActorAndReason(t *testing.T) {
+	tm, repository, finished := newTestManager(t, config.Threadpool{MaxWorkers: 1})
+	executor := newStubExecutor()
+	task := NewSandboxTask(&database.Task{ID: "task_1", Name: "report"}, executor, "main.py", nil, "python", "")
+	if err := tm.SubmitTask(task); err != nil {
+		t.Fatal(err)
+	}
+	executor.waitStarted(t, 1)
+	if err := tm.CancelTask("task_1", ActorAPI, "用户取消"); err != nil {
+		t.Fatal(err)
+	}
+	if statuses := waitFinished(t, finished, 1); statuses["task_1"] != StatusCancelled {
+		t.Fatalf("最终状态为 %s，应为 %s", statuses["task_1"], StatusCancelled)
+	}
+
+	events := transitionsOf(t, repository, "task_1")
+	last := events[len(events)-1]
+	if last.FromStatus != StatusRunning || last.ToStatus != StatusCancelled || last.Actor != ActorAPI || last.Reason != "用户取消" {
+		t.Errorf("最后一条事件为 %+v", last)
+	}
+	cancelled := 0
+	for _, e := range events {
+		if e.ToStatus == StatusCancelled {
+			cancelled++
+		}
+	}
+	if cancelled != 1 {
+		t.Errorf("应只记录 1 条取消事件，得到 %+v", events)
+	}
+}
+
+// selfFinishingTask 执行期间自行把状态改为 succeeded，模拟最终状态被其他操作抢先写入
+type selfFinishingTask struct {
+	*database.Task
+}
+
+func (t *selfFinishingTask) Execute(ctx context.Context) error {
+	t.SetStatus(StatusSucceeded)
+	return errors.New("执行失败")
+}
+
+func TestRejectedFinalTransitionKeepsStatus(t *testing.T) {
+	tm, repository, finished := newTestManager(t, config.Threadpool{MaxWorkers: 1})
+	task := &selfFinishingTask{Task: &database.Task{ID: "task_1", Name: "report"}}
+	if err := tm.SubmitTask(task); err != nil {
+		t.Fatal(err)
+	}
+	if statuses := waitFinished(t, finished, 1); statuses["task_1"] != StatusSucceeded {
+		t.Fatalf("结束通知的状态为 %s，应保持 %s", statuses["task_1"], StatusSucceeded)
+	}
+	if n := tm.GetRunningTaskCount(); n != 0 {
+		t.Errorf("活动任务数为 %d，应为 0", n)
+	}
+	if status := taskStatus(t, repository, "task_1"); status != StatusSucceeded {
+		t.Errorf("数据库中的状态为 %s，应保持 %s", status, StatusSucceeded)
+	}
+	for _, e := range transitionsOf(t, repository, "task_1") {
+		if e.FromStatus == StatusRunning {
+			t.Errorf("被拒绝的最终转换不应记录事件，得到 %+v", e)
+		}
+	}
+}
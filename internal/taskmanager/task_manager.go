@@ -1,13 +1,19 @@
 package taskmanager
 
 import (
-	"time"
+	"context"
+	"errors"
 	"file-flow-service/config"
+	"file-flow-service/database"
+	"file-flow-service/internal/service/interfaces"
+	"file-flow-service/internal/threadpool"
+	"file-flow-service/sandbox/execution"
 	"file-flow-service/utils/logger"
+	"fmt"
 	"sync"
-	"file-flow-service/internal/threadpool"
-	"file-flow-service/internal/service/interfaces"
-	"file-flow-service/database"
+	"time"
+
+	"go.uber.org/zap"
 )
 
 type TaskManager interface {
@@ -17,28 +23,55 @@ type TaskManager interface {
 	GetTaskByID(taskID string) (*interfaces.TaskInterface, error)
 	UpdateTask(taskID string, status string) error
 	GetAllTasks() ([]*interfaces.TaskInterface, error)
+	ListTasks(filter database.TaskFilter) (*database.TaskPage, error)
+	GetAttempts(taskID string) ([]database.TaskAttempt, error)
+	GetTaskEvents(taskID string) ([]database.TaskEvent, error)
+	Recover(executor execution.SandboxExecutor) (*RecoveryReport, error)
 	SubmitTask(task interfaces.TaskInterface) error
-	CancelTask(taskID string) error
+	CancelTask(taskID, actor, reason string) error
 	GetThreadPoolStats() (*threadpool.ThreadPoolStats, error)
+	GetQueuePosition(taskID string) (int, error)
+	OnTaskFinished(listener TaskListener)
 }
 
+// TaskListener 任务结束（不再重试）后的回调，参数为任务ID和最终状态
+type TaskListener func(taskID, status string)
+
 type taskManager struct {
 	config          *config.AppConfig
 	threadpool      *threadpool.ThreadPool
+	repository      *database.Repository
 	logger          logger.Logger
 	tasks           map[string]interfaces.TaskInterface
+	cancels         map[string]context.CancelFunc
 	mu              sync.RWMutex
 	runningTasks    int
 	totalTasks      int
 	activeTaskCount int
+	listeners       []TaskListener
+	listenersMu     sync.RWMutex
+	// cancelRequests 已请求终止的执行中任务的取消发起方和原因，任务结束时记录到状态转换事件
+	cancelRequests map[string]statusCause
 }
 
-func NewTaskManager(config *config.AppConfig, threadpool *threadpool.ThreadPool, logger logger.Logger) TaskManager {
+// statusCause 状态转换的发起方和原因
+type statusCause struct {
+	actor  string
+	reason string
+}
+
+// NewTaskManager 创建任务管理器
+// 参数: config 配置对象, threadpool 线程池, repository 任务仓库, logger 日志对象
+func NewTaskManager(config *config.AppConfig, threadpool *threadpool.ThreadPool, repository *database.Repository, logger logger.Logger) TaskManager {
 	return &taskManager{
-		config:   config,
+		config:     config,
 		threadpool: threadpool,
-		logger:   logger,
-		tasks:    make(map[string]interfaces.TaskInterface),
+		repository: repository,
+		logger:     logger,
+		tasks:      make(map[string]interfaces.TaskInterface),
+		cancels:    make(map[string]context.CancelFunc),
+
+		cancelRequests: make(map[string]statusCause),
 	}
 }
 
@@ -52,6 +85,9 @@ func (tm *taskManager) Stop() {
 }
 
 func (tm *taskManager) GetRunningTaskCount() int {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
 	return tm.activeTaskCount
 }
 
@@ -66,23 +102,43 @@ func (tm *taskManager) GetTaskByID(taskID string) (*interfaces.TaskInterface, er
 	return &task, nil
 }
 
+// UpdateTask 按状态机修改任务状态并记录状态转换事件，发起方为 api
+// 调度中的任务状态由任务管理器维护，只能转换为 cancelled（按 CancelTask 处理）
+// 返回: 错误信息（目标状态未定义时返回 ErrUnknownStatus，不允许转换时返回 ErrInvalidTransition）
 func (tm *taskManager) UpdateTask(taskID string, status string) error {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 
+	if task, exists := tm.tasks[taskID]; exists {
+		if _, active := tm.cancels[taskID]; active {
+			if err := checkTransition(taskID, task.GetStatus(), status); err != nil {
+				return err
+			}
+			if status != StatusCancelled {
+				return fmt.Errorf("%w: 任务 %s 正在调度中，只能取消", ErrInvalidTransition, taskID)
+			}
+			return tm.cancel(taskID, ActorAPI, "通过接口取消")
+		}
+	}
+
 	// 获取任务数据
-	task, err := database.GetTaskByID(taskID)
+	task, err := tm.repository.GetTaskByID(taskID)
 	if err != nil {
 		return err
 	}
-	if task == nil {
-		return nil
+
+	// 更新状态，结束状态同时记录结束时间
+	if err := tm.setStatus(task, status, ActorAPI, "通过接口修改状态"); err != nil {
+		return err
+	}
+	if IsFinal(status) && task.FinishedAt == 0 {
+		task.FinishedAt = time.Now().Unix()
+		if task.StartedAt > 0 {
+			task.Duration = task.FinishedAt - task.StartedAt
+		}
 	}
-	
-	// 更新状态
-	task.Status = status
 	// 保存到数据库
-	if err := database.UpdateTask(task); err != nil {
+	if err := tm.repository.UpdateTask(task); err != nil {
 		return err
 	}
 	return nil
@@ -93,11 +149,11 @@ func (tm *taskManager) GetAllTasks() ([]*interfaces.TaskInterface, error) {
 	defer tm.mu.Unlock()
 
 	// 获取所有任务
-	dbTasks, err := database.GetTasks()
+	dbTasks, err := tm.repository.GetTasks()
 	if err != nil {
 		return nil, err
 	}
-	
+
 	// 转换为TaskInterface
 	var tasks []*interfaces.TaskInterface
 	for _, dbTask := range dbTasks {
@@ -108,6 +164,23 @@ func (tm *taskManager) GetAllTasks() ([]*interfaces.TaskInterface, error) {
 	return tasks, nil
 }
 
+// ListTasks 按条件分页查询数据库中的任务记录
+func (tm *taskManager) ListTasks(filter database.TaskFilter) (*database.TaskPage, error) {
+	return tm.repository.ListTasks(filter)
+}
+
+// GetAttempts 按执行顺序查询任务每次执行的记录
+func (tm *taskManager) GetAttempts(taskID string) ([]database.TaskAttempt, error) {
+	return tm.repository.GetAttempts(taskID)
+}
+
+// GetTaskEvents 按发生顺序查询任务的状态转换事件
+func (tm *taskManager) GetTaskEvents(taskID string) ([]database.TaskEvent, error) {
+	return tm.repository.GetTaskEvents(taskID)
+}
+
+// SubmitTask 保存任务并提交到线程池，任务以 pending 状态创建，提交前转换为 queued
+// 队列已满时返回 threadpool.ErrQueueFull，任务不会被保存
 func (tm *taskManager) SubmitTask(task interfaces.TaskInterface) error {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
@@ -116,64 +189,381 @@ func (tm *taskManager) SubmitTask(task interfaces.TaskInterface) error {
 		return nil
 	}
 
-	tm.totalTasks++
-	tm.tasks[task.GetID()] = task
 	// 转换为数据库任务
-	dbTask := database.Task{
-		ID:          task.GetID(),
-		Name:        task.GetName(),
-		Status:      task.GetStatus(),
-		Creator:     task.GetCreator(),
-		CreatedAt:   task.GetCreatedAt(),
-		AssignedTo:  task.GetAssignedTo(),
-		Description: task.GetDescription(),
-		ResultPath:  task.GetResultPath(),
-		Progress:    task.GetProgress(),
-	}
-	
-	if err := database.CreateTask(&dbTask); err != nil {
+	task.SetStatus(StatusPending)
+	dbTask := toDBTask(task)
+	if err := tm.repository.CreateTask(dbTask); err != nil {
 		return err
 	}
-	tm.activeTaskCount++
+	reason := "任务已创建"
+	if creator := task.GetCreator(); creator != "" {
+		reason = "任务由 " + creator + " 创建"
+	}
+	tm.recordEvent(task.GetID(), "", StatusPending, ActorSystem, reason)
 
+	// 先转换为 queued 再提交：任务出队后立即开始执行时状态已是 queued
+	if err := tm.setStatus(task, StatusQueued, ActorSystem, "提交到线程池"); err != nil {
+		return err
+	}
+	tm.saveTask(task)
+
+	if err := tm.enqueue(task, jobOptions(task)); err != nil {
+		if delErr := tm.repository.DeleteTask(task.GetID()); delErr != nil {
+			tm.logger.Error("回滚任务记录失败", zap.String("task_id", task.GetID()), zap.Error(delErr))
+		}
+		return err
+	}
 	tm.logger.Info("任务已提交到执行器: " + task.GetID())
+	return nil
+}
+
+// enqueue 将已保存的任务提交到线程池并登记，调用方需持有 tm.mu
+func (tm *taskManager) enqueue(task interfaces.TaskInterface, opts threadpool.JobOptions) error {
+	// 取消函数在提交时登记，任务出队后、开始执行前被取消也能生效
+	taskCtx, cancel := context.WithCancel(context.Background())
+	if err := tm.threadpool.SubmitWithOptions(tm.runTask(taskCtx, task), opts); err != nil {
+		cancel()
+		tm.logger.Warn("任务提交到线程池失败", zap.String("task_id", task.GetID()), zap.Error(err))
+		return err
+	}
+	tm.register(task, cancel)
+	return nil
+}
+
+// jobOptions 任务在线程池中的调度参数
+func jobOptions(task interfaces.TaskInterface) threadpool.JobOptions {
+	return threadpool.JobOptions{
+		ID:       task.GetID(),
+		Tenant:   taskTenant(task),
+		Priority: threadpool.ParsePriority(task.GetPriority()),
+		Memory:   task.GetExpectedMemory(),
+	}
+}
+
+// register 登记已提交的任务及其取消函数，调用方需持有 tm.mu
+func (tm *taskManager) register(task interfaces.TaskInterface, cancel context.CancelFunc) {
+	tm.totalTasks++
+	tm.tasks[task.GetID()] = task
+	tm.cancels[task.GetID()] = cancel
+	tm.activeTaskCount++
+}
+
+// runTask 构造线程池任务
+// taskCtx 被 CancelTask 取消时终止执行，结束后根据执行结果设置最终状态并写回数据库；
+// 失败原因满足重试策略时任务进入 retrying 状态，等待后重新排队。
+// 线程池强制停止（服务关闭）时：尚未开始的任务保持 queued；执行中被中断的任务记为 interrupted，
+// 可重复执行的任务改为 queued，重启后由 Recover 重新排队
+func (tm *taskManager) runTask(taskCtx context.Context, task interfaces.TaskInterface) threadpool.Job {
+	return func(poolCtx context.Context) {
+		ctx, cancel := context.WithCancel(poolCtx)
+		defer cancel()
+		stop := context.AfterFunc(taskCtx, cancel)
+		defer stop()
+		// 正常结束时在写回最终状态的临界区内释放取消函数，其余情况（如未能开始执行）在此释放
+		released := false
+		defer func() {
+			if !released {
+				tm.mu.Lock()
+				tm.releaseCancel(task.GetID())
+				tm.mu.Unlock()
+			}
+		}()
+
+		// 重新接管和重试的任务保留第一次执行的开始时间
+		now := time.Now()
+		startTime := task.GetStartTime()
+		if startTime == 0 {
+			startTime = now.Unix()
+			task.SetStartTime(startTime)
+		}
+		// 重新接管的任务已是 running 状态
+		tm.mu.Lock()
+		if task.GetStatus() == StatusQueued && taskCtx.Err() == nil && errors.Is(poolCtx.Err(), context.Canceled) {
+			tm.activeTaskCount--
+			tm.mu.Unlock()
+			tm.logger.Info("线程池已停止，任务保持排队，重启后恢复", zap.String("task_id", task.GetID()))
+			return
+		}
+		if task.GetStatus() != StatusRunning {
+			if err := tm.setStatus(task, StatusRunning, ActorSystem, "开始执行"); err != nil {
+				tm.activeTaskCount--
+				tm.mu.Unlock()
+				return
+			}
+		}
+		tm.mu.Unlock()
+		attempt := tm.beginAttempt(task, now)
+		if sandboxTask, ok := task.(*SandboxTask); ok {
+			sandboxTask.onProcessStart = func() { tm.saveTask(task) }
+		}
+		tm.saveTask(task)
+
+		// 开始执行前已被取消的任务不再执行
+		err := ctx.Err()
+		if err == nil {
+			err = task.Execute(ctx)
+		}
+
+		status, reason := attemptOutcome(ctx, err, taskCtx.Err() != nil)
+		// 线程池强制停止导致的中断，区别于重新接管的任务无法确定执行结果
+		stopped := status == StatusInterrupted && errors.Is(ctx.Err(), context.Canceled) && taskCtx.Err() == nil
+		switch {
+		case status == StatusTimeout:
+			tm.logger.Warn("任务执行超时", zap.String("task_id", task.GetID()))
+		case status == StatusCancelled:
+			tm.logger.Info("任务已取消", zap.String("task_id", task.GetID()))
+		case stopped:
+			tm.logger.Warn("线程池已停止，任务执行被中断", zap.String("task_id", task.GetID()))
+		case status == StatusInterrupted:
+			tm.logger.Warn("重新接管的任务已结束，无法确定执行结果", zap.String("task_id", task.GetID()))
+		case reason == execution.FailureSeccompViolation:
+			tm.logger.Error("任务违反系统调用过滤策略，已被终止",
+				zap.String("task_id", task.GetID()),
+				zap.String("failure_reason", reason),
+				zap.Error(err))
+		case err != nil:
+			tm.logger.Error("任务执行失败", zap.String("task_id", task.GetID()), zap.String("failure_reason", reason), zap.Error(err))
+		}
+
+		if record, ok := task.(taskRecord); ok {
+			record.Record().ErrorMessage = ""
+			if err != nil {
+				record.Record().ErrorMessage = err.Error()
+			}
+			// 进程已结束，清除进程记录以免重启后误认为仍在运行
+			record.Record().PID = 0
+			record.Record().PIDStartTime = 0
+		}
+		finished := time.Now()
+		tm.finishAttempt(task, attempt, status, reason, finished)
+
+		// 写回最终状态、释放取消函数和登记重试在同一临界区内完成：
+		// CancelTask 要么在此之前请求终止本次执行，要么在此之后取消等待重试的任务，不会落在两者之间
+		tm.mu.Lock()
+		defer tm.mu.Unlock()
+		released = true
+		tm.releaseCancel(task.GetID())
 
-	tm.threadpool.Submit(func() {
-		task.SetStatus("running")
-		startTime := time.Now().Unix()
-		task.SetStartTime(startTime)
-		task.Execute()
-		task.SetStatus("completed")
-		finishTime := time.Now().Unix()
-		duration := finishTime - startTime
-		task.SetDuration(duration)
-		task.SetFinishedAt(finishTime)
+		cause, cancelRequested := tm.outcomeCause(task.GetID(), status, reason, err)
+		if stopped {
+			cause.reason = "服务关闭，任务执行被中断"
+		}
+		// 已请求取消的任务即使失败原因满足重试策略也不再重试
+		delay, retry := tm.retryDelay(task, reason)
+		retry = retry && !cancelRequested
+		requeue := stopped && !cancelRequested && idempotent(task)
+		switch {
+		case retry:
+			status = StatusRetrying
+			cause.actor = ActorRetry
+			cause.reason = fmt.Sprintf("%s，%s 后重试", cause.reason, delay.Round(time.Millisecond))
+		case requeue:
+			status = StatusQueued
+			cause.reason = "服务关闭，任务执行被中断，重启后重新排队"
+		}
+		if err := tm.setStatus(task, status, cause.actor, cause.reason); err != nil {
+			// 执行期间状态被其他操作改变，保留当前状态，不再重试或重新排队，只保存本次执行的结果
+			tm.logger.Error("无法写回任务最终状态", zap.String("task_id", task.GetID()),
+				zap.String("current", task.GetStatus()), zap.String("status", status), zap.Error(err))
+			tm.saveTask(task)
+			tm.activeTaskCount--
+			if IsFinal(task.GetStatus()) {
+				tm.notifyFinished(task.GetID(), task.GetStatus())
+			}
+			return
+		}
+		if !retry && !requeue {
+			finishTime := finished.Unix()
+			task.SetDuration(finishTime - startTime)
+			task.SetFinishedAt(finishTime)
+		}
+		tm.saveTask(task)
+
+		// 等待重试的任务仍计入活动任务
+		if retry {
+			tm.scheduleRetry(task, delay)
+			return
+		}
 		tm.activeTaskCount--
-	})
+		if requeue {
+			return
+		}
+		tm.notifyFinished(task.GetID(), status)
+	}
+}
 
-	return nil
+// idempotent 任务是否可安全重复执行
+func idempotent(task interfaces.TaskInterface) bool {
+	record, ok := task.(taskRecord)
+	return ok && record.Record().Idempotent
+}
+
+// releaseCancel 释放并移除任务本次执行的取消函数，调用方需持有 tm.mu
+func (tm *taskManager) releaseCancel(taskID string) {
+	if release, ok := tm.cancels[taskID]; ok {
+		release()
+		delete(tm.cancels, taskID)
+	}
+}
+
+// outcomeCause 本次执行结束时状态转换的发起方和原因，调用方需持有 tm.mu
+// 通过 CancelTask 终止的任务使用取消请求的发起方和原因，其余为 system，原因为失败原因和错误信息
+// 返回: 发起方和原因，本次执行期间是否收到过取消请求
+func (tm *taskManager) outcomeCause(taskID, status, reason string, err error) (statusCause, bool) {
+	request, cancelRequested := tm.cancelRequests[taskID]
+	delete(tm.cancelRequests, taskID)
+	if cancelRequested && status == StatusCancelled {
+		return request, true
+	}
+
+	cause := statusCause{actor: ActorSystem, reason: "执行成功"}
+	switch {
+	case err != nil && reason != "":
+		cause.reason = fmt.Sprintf("%s: %v", reason, err)
+	case err != nil:
+		cause.reason = err.Error()
+	case reason != "":
+		cause.reason = reason
+	}
+	return cause, cancelRequested
+}
+
+// OnTaskFinished 注册任务结束的回调
+// 任务执行完成、取消或中断且不再重试时调用，回调在独立的 goroutine 中执行，可以再提交任务
+func (tm *taskManager) OnTaskFinished(listener TaskListener) {
+	tm.listenersMu.Lock()
+	defer tm.listenersMu.Unlock()
+	tm.listeners = append(tm.listeners, listener)
+}
+
+// notifyFinished 通知任务已结束，调用方可以持有 tm.mu
+func (tm *taskManager) notifyFinished(taskID, status string) {
+	tm.listenersMu.RLock()
+	defer tm.listenersMu.RUnlock()
+	for _, listener := range tm.listeners {
+		go listener(taskID, status)
+	}
+}
+
+// taskTenant 任务所属的调度租户：优先按创建者，其次按指派人
+func taskTenant(task interfaces.TaskInterface) string {
+	if creator := task.GetCreator(); creator != "" {
+		return creator
+	}
+	return task.GetAssignedTo()
+}
+
+// saveTask 将任务当前状态写回数据库，失败只记录日志
+func (tm *taskManager) saveTask(task interfaces.TaskInterface) {
+	if err := tm.repository.UpdateTask(toDBTask(task)); err != nil {
+		tm.logger.Error("保存任务状态失败", zap.String("task_id", task.GetID()), zap.Error(err))
+	}
+}
+
+// taskRecord 持有完整任务记录的任务，嵌入 *database.Task 的任务都实现了该接口
+type taskRecord interface {
+	Record() *database.Task
 }
 
-func (tm *taskManager) CancelTask(taskID string) error {
+// toDBTask 将任务接口转换为数据库任务
+// 任务持有完整记录时一并保存运行时、命令、退出码等任务接口之外的字段
+func toDBTask(task interfaces.TaskInterface) *database.Task {
+	dbTask := &database.Task{
+		ID:             task.GetID(),
+		Name:           task.GetName(),
+		Status:         task.GetStatus(),
+		Creator:        task.GetCreator(),
+		CreatedAt:      task.GetCreatedAt(),
+		AssignedTo:     task.GetAssignedTo(),
+		Description:    task.GetDescription(),
+		ResultPath:     task.GetResultPath(),
+		Progress:       task.GetProgress(),
+		Duration:       task.GetDuration(),
+		FinishedAt:     task.GetFinishedAt(),
+		StartedAt:      task.GetStartTime(),
+		Priority:       task.GetPriority(),
+		ExpectedMemory: task.GetExpectedMemory(),
+	}
+	if record, ok := task.(taskRecord); ok {
+		r := record.Record()
+		dbTask.Runtime = r.Runtime
+		dbTask.RuntimeVersion = r.RuntimeVersion
+		dbTask.Command = r.Command
+		dbTask.Args = r.Args
+		dbTask.ExitCode = r.ExitCode
+		dbTask.ErrorMessage = r.ErrorMessage
+		dbTask.Idempotent = r.Idempotent
+		dbTask.MemoryLimit = r.MemoryLimit
+		dbTask.CPUCores = r.CPUCores
+		dbTask.MaxPids = r.MaxPids
+		dbTask.AllowNetwork = r.AllowNetwork
+		dbTask.PID = r.PID
+		dbTask.PIDStartTime = r.PIDStartTime
+		dbTask.Attempt = r.Attempt
+		dbTask.RetryPolicy = r.RetryPolicy
+	}
+	return dbTask
+}
+
+// CancelTask 取消任务
+// 排队中和等待重试的任务直接移出队列并标记为 cancelled；执行中的任务取消其上下文，
+// 由执行器终止进程组后在 runTask 中写回最终状态
+// 参数: taskID 任务ID, actor 发起方, reason 原因（记录到状态转换事件）
+func (tm *taskManager) CancelTask(taskID, actor, reason string) error {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
+	return tm.cancel(taskID, actor, reason)
+}
 
+// cancel 取消任务，调用方需持有 tm.mu
+func (tm *taskManager) cancel(taskID, actor, reason string) error {
 	task, exists := tm.tasks[taskID]
 	if !exists {
 		return nil
 	}
 
-	task.SetStatus("cancelled")
-	tm.UpdateTask(taskID, "cancelled")
-	tm.activeTaskCount--
+	cancel, pending := tm.cancels[taskID]
+	if !pending {
+		return fmt.Errorf("任务 %s 已结束，无法取消", taskID)
+	}
+
+	// 等待重试的任务没有在执行，也不在队列中
+	if task.GetStatus() == StatusRetrying || tm.threadpool.Cancel(taskID) {
+		cancel()
+		delete(tm.cancels, taskID)
+		tm.setStatus(task, StatusCancelled, actor, reason)
+		task.SetFinishedAt(time.Now().Unix())
+		tm.saveTask(task)
+		tm.activeTaskCount--
+		tm.logger.Info("排队中的任务已取消", zap.String("task_id", taskID))
+		tm.notifyFinished(taskID, StatusCancelled)
+		return nil
+	}
+
+	cancel()
+	tm.cancelRequests[taskID] = statusCause{actor: actor, reason: reason}
+	tm.logger.Info("已请求终止执行中的任务", zap.String("task_id", taskID))
 	return nil
 }
 
 func (tm *taskManager) GetThreadPoolStats() (*threadpool.ThreadPoolStats, error) {
 	poolStats := tm.threadpool.GetStats()
-	return &threadpool.ThreadPoolStats{
-		TotalTasks:     poolStats.TotalTasks,
-		ActiveTasks:    poolStats.ActiveTasks,
-		CompletedTasks: poolStats.CompletedTasks,
-	}, nil
-}
\ No newline at end of file
+	return &poolStats, nil
+}
+
+// GetQueuePosition 查询任务的排队位置
+// 返回：排队位置（从1开始），任务已开始执行或已结束时返回 0
+func (tm *taskManager) GetQueuePosition(taskID string) (int, error) {
+	tm.mu.RLock()
+	_, exists := tm.tasks[taskID]
+	tm.mu.RUnlock()
+	if !exists {
+		return 0, fmt.Errorf("任务不存在: %s", taskID)
+	}
+
+	pos, queued := tm.threadpool.Position(taskID)
+	if !queued {
+		return 0, nil
+	}
+	return pos, nil
+}
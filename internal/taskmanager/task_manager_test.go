@@ -0,0 +1,130 @@
+package taskmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"file-flow-service/config"
+	"file-flow-service/database"
+	"file-flow-service/internal/threadpool"
+	"file-flow-service/sandbox/environments"
+	"file-flow-service/sandbox/execution"
+	"file-flow-service/utils/logger"
+
+	"go.uber.org/zap"
+)
+
+// nopLogger 测试中丢弃全部日志
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...zap.Field)    {}
+func (nopLogger) Info(string, ...zap.Field)     {}
+func (nopLogger) Warn(string, ...zap.Field)     {}
+func (nopLogger) Error(string, ...zap.Field)    {}
+func (nopLogger) LogError(string, ...zap.Field) {}
+func (nopLogger) Fatal(string, ...zap.Field)    {}
+func (nopLogger) SetLevel(string) error         { return nil }
+
+// stubExecutor 不启动进程的沙盒执行器：任务开始时上报任务ID，release 关闭后以 exitCode 结束，
+// 等待期间 ctx 取消时按执行器的约定返回 ErrExecutionCancelled
+type stubExecutor struct {
+	started  chan string
+	release  chan struct{}
+	exitCode int
+}
+
+func newStubExecutor() *stubExecutor {
+	return &stubExecutor{started: make(chan string, 100), release: make(chan struct{})}
+}
+
+func (e *stubExecutor) Init(*config.AppConfig, logger.Logger, environments.EnvironmentManager) error {
+	return nil
+}
+
+func (e *stubExecutor) ExecuteTask(ctx context.Context, req execution.ExecutionRequest) (*execution.ExecutionResult, error) {
+	e.started <- req.TaskID
+	result := &execution.ExecutionResult{TaskID: req.TaskID, ExitCode: -1}
+	select {
+	case <-e.release:
+	case <-ctx.Done():
+		return result, execution.ErrExecutionCancelled
+	}
+	result.ExitCode = e.exitCode
+	if e.exitCode != 0 {
+		return result, execution.ErrNonZeroExit
+	}
+	return result, nil
+}
+
+func (e *stubExecutor) AttachTask(ctx context.Context, req execution.AttachRequest) (*execution.ExecutionResult, error) {
+	return nil, execution.ErrProcessNotFound
+}
+
+func (e *stubExecutor) CreateTaskDirectory(taskID string) (string, error) {
+	return "", nil
+}
+
+func (e *stubExecutor) CleanupTaskDirectory(taskID string) error {
+	return nil
+}
+
+// waitStarted 等待 n 个任务开始执行
+func (e *stubExecutor) waitStarted(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-e.started:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("等待第 %d 个任务开始执行超时", i+1)
+		}
+	}
+}
+
+// newTestManager 创建使用内存数据库和指定线程池配置的任务管理器
+// 返回的 finished 在任务结束（不再重试）时收到任务ID和最终状态
+func newTestManager(t *testing.T, pool config.Threadpool) (*taskManager, *database.Repository, <-chan [2]string) {
+	t.Helper()
+	conn, err := database.Open(config.Database{Connection: ":memory:"}, nopLogger{})
+	if err != nil {
+		t.Fatalf("打开内存数据库失败: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	repository := database.NewRepository(conn, nopLogger{})
+
+	cfg := &config.AppConfig{Threadpool: pool}
+	threadPool := threadpool.NewThreadPool(cfg, nopLogger{})
+	t.Cleanup(threadPool.StopNow)
+
+	tm := NewTaskManager(cfg, threadPool, repository, nopLogger{}).(*taskManager)
+	finished := make(chan [2]string, 100)
+	tm.OnTaskFinished(func(taskID, status string) {
+		finished <- [2]string{taskID, status}
+	})
+	return tm, repository, finished
+}
+
+// waitFinished 等待 n 个任务结束，返回任务ID到最终状态的映射
+func waitFinished(t *testing.T, finished <-chan [2]string, n int) map[string]string {
+	t.Helper()
+	statuses := make(map[string]string)
+	for i := 0; i < n; i++ {
+		select {
+		case f := <-finished:
+			statuses[f[0]] = f[1]
+		case <-time.After(5 * time.Second):
+			t.Fatalf("等待第 %d 个任务结束超时，已结束: %v", i+1, statuses)
+		}
+	}
+	return statuses
+}
+
+// taskStatus 读取数据库中任务的状态
+func taskStatus(t *testing.T, repository *database.Repository, taskID string) string {
+	t.Helper()
+	record, err := repository.GetTaskByID(taskID)
+	if err != nil {
+		t.Fatalf("查询任务 %s 失败: %v", taskID, err)
+	}
+	return record.Status
+}
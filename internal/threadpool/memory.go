@@ -0,0 +1,150 @@
+package threadpool
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+	"go.uber.org/zap"
+)
+
+// memorySampleInterval 运行中任务内存采样间隔
+const memorySampleInterval = 2 * time.Second
+
+// runningJobKey 运行中任务在 context 中的键
+type runningJobKey struct{}
+
+// runningJob 正在执行的任务的内存占用
+// 占用按声明值与实测值中较大者计算，实测值为任务上报进程（含子进程）的 RSS 之和
+type runningJob struct {
+	declared int64
+	measured int64
+	mu       sync.Mutex
+	pids     []int32
+}
+
+// reserved 任务当前占用的内存预算
+func (r *runningJob) reserved() int64 {
+	if r.measured > r.declared {
+		return r.measured
+	}
+	return r.declared
+}
+
+// ReportProcess 上报任务启动的进程，线程池据此采样实际内存占用
+// 参数：ctx 线程池传给任务的上下文, pid 进程ID
+// 不在线程池任务中调用时无效果
+func ReportProcess(ctx context.Context, pid int) {
+	job, ok := ctx.Value(runningJobKey{}).(*runningJob)
+	if !ok || pid <= 0 {
+		return
+	}
+	job.mu.Lock()
+	job.pids = append(job.pids, int32(pid))
+	job.mu.Unlock()
+}
+
+// admit 判断任务是否可以在当前内存预算下开始执行，调用方需持有 mu
+// 没有任务在执行时总是放行，避免超出预算的单个任务永远无法执行
+func (p *ThreadPool) admit(job *queuedJob) bool {
+	if p.memoryLimit <= 0 || len(p.running) == 0 {
+		return true
+	}
+	return p.memoryInUse+job.memory <= p.memoryLimit
+}
+
+// memoryBlocked 队首任务是否因内存预算不足而等待，调用方需持有 mu
+func (p *ThreadPool) memoryBlocked() bool {
+	head := p.queue.peek()
+	return head != nil && !p.admit(head)
+}
+
+// recalcMemory 重新计算运行中任务的内存占用，调用方需持有 mu
+// 占用下降时唤醒等待的工作协程
+func (p *ThreadPool) recalcMemory() {
+	var total int64
+	for job := range p.running {
+		total += job.reserved()
+	}
+	freed := total < p.memoryInUse
+	p.memoryInUse = total
+	if freed {
+		p.cond.Broadcast()
+	}
+}
+
+// memoryMonitorLoop 定期采样运行中任务进程的 RSS
+func (p *ThreadPool) memoryMonitorLoop() {
+	ticker := time.NewTicker(memorySampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stopCh:
+			return
+		case <-ticker.C:
+			p.sampleMemory()
+		}
+	}
+}
+
+// sampleMemory 采样一次内存占用，采样过程中不持有线程池锁
+func (p *ThreadPool) sampleMemory() {
+	p.mu.Lock()
+	jobs := make([]*runningJob, 0, len(p.running))
+	for job := range p.running {
+		jobs = append(jobs, job)
+	}
+	p.mu.Unlock()
+
+	measured := make(map[*runningJob]int64, len(jobs))
+	for _, job := range jobs {
+		job.mu.Lock()
+		pids := append([]int32(nil), job.pids...)
+		job.mu.Unlock()
+		if len(pids) == 0 {
+			continue
+		}
+		var rss int64
+		for _, pid := range pids {
+			rss += processTreeRSS(pid)
+		}
+		measured[job] = rss
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for job, rss := range measured {
+		if _, ok := p.running[job]; ok {
+			job.measured = rss
+		}
+	}
+	before := p.memoryInUse
+	p.recalcMemory()
+	if p.memoryInUse > p.memoryLimit && before <= p.memoryLimit {
+		p.logger.Warn("运行中任务内存占用超出线程池预算",
+			zap.Int64("memory_in_use", p.memoryInUse),
+			zap.Int64("memory_limit", p.memoryLimit))
+	}
+}
+
+// processTreeRSS 进程及其全部子进程的 RSS 之和，进程已退出时返回 0
+func processTreeRSS(pid int32) int64 {
+	proc, err := process.NewProcess(pid)
+	if err != nil {
+		return 0
+	}
+	var rss int64
+	if mem, err := proc.MemoryInfo(); err == nil {
+		rss += int64(mem.RSS)
+	}
+	children, err := proc.Children()
+	if err != nil {
+		return rss
+	}
+	for _, child := range children {
+		rss += processTreeRSS(child.Pid)
+	}
+	return rss
+}
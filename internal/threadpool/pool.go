@@ -1,48 +1,648 @@
+// Package threadpool 任务线程池
+// 由工作协程从有界队列中取出任务执行
+// 队列容量、工作协程数量和单任务超时时间均来自 threadpool 配置
+// 开启 auto_scale 时工作协程数量在 min_workers 与 max_workers 之间按负载自动调整
+// 队列按提交者做加权公平调度、提交者内按优先级调度，见 scheduler.go
+// 配置 memory_limit 时按运行中任务的内存占用做准入控制，见 memory.go
 package threadpool
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"file-flow-service/config"
 	"file-flow-service/utils/logger"
+
+	"go.uber.org/zap"
+)
+
+// ErrQueueFull 任务队列已满，新任务被拒绝
+var ErrQueueFull = errors.New("任务队列已满")
+
+// ErrPoolStopped 线程池已停止，不再接受新任务
+var ErrPoolStopped = errors.New("线程池已停止")
+
+const (
+	// scaleCheckInterval 自动扩缩容检查间隔
+	scaleCheckInterval = time.Second
+	// defaultScaleUpWait 未配置 scale_up_wait 时的默认扩容等待阈值
+	defaultScaleUpWait = 2 * time.Second
+	// defaultIdleTimeout 未配置 idle_timeout 时的默认空闲回收时间
+	defaultIdleTimeout = 30 * time.Second
 )
 
+// Job 线程池任务
+// ctx 在任务超时或线程池强制停止（StopNow）时被取消，任务应当及时响应
+type Job func(ctx context.Context)
+
+// ThreadPoolStats 线程池统计信息
 type ThreadPoolStats struct {
 	TotalTasks     int
 	ActiveTasks    int
 	CompletedTasks int
+	QueueLength    int
+	RejectedTasks  int
+	TimeoutTasks   int
+	MaxWorkers     int
+	MaxQueue       int
+	MinWorkers     int
+	CurrentWorkers int
+	IdleWorkers    int
+	ScaleUps       int
+	ScaleDowns     int
+	MemoryLimit    int64
+	MemoryInUse    int64
+	MemoryDeferred int
+	MemoryBlocked  bool
+}
+
+// queuedJob 队列中等待执行的任务
+type queuedJob struct {
+	fn         Job
+	id         string
+	tenant     string
+	priority   Priority
+	memory     int64
+	enqueuedAt time.Time
+	deferred   bool
+	// 调度信息，由 fairQueue 维护
+	seq   uint64
+	index int
 }
 
+// ThreadPool 有界工作协程池
 type ThreadPool struct {
-	logger logger.Logger
-	// Add stats tracking fields
+	logger      logger.Logger
+	mu          sync.Mutex
+	cond        *sync.Cond
+	wg          sync.WaitGroup
+	queue       *fairQueue
+	maxWorkers  int
+	maxQueue    int
+	taskTimeout time.Duration
+	workers     int
+	stopped     bool
+	stopCh      chan struct{}
+	// baseCtx 所有任务 ctx 的父 context，StopNow 时取消
+	baseCtx    context.Context
+	baseCancel context.CancelFunc
+	// 自动扩缩容
+	autoScale    bool
+	minWorkers   int
+	scaleUpWait  time.Duration
+	scaleUpQueue int
+	idleTimeout  time.Duration
+	idleWorkers  int
+	retiring     int
+	busyAt       time.Time
+	scaleUps     int
+	scaleDowns   int
+	// 内存准入
+	memoryLimit    int64
+	memoryInUse    int64
+	memoryDeferred int
+	running        map[*runningJob]struct{}
+	// 统计信息，均在 mu 保护下读写
 	totalTasks     int
 	activeTasks    int
 	completedTasks int
+	rejectedTasks  int
+	timeoutTasks   int
 }
 
-func NewThreadPool() *ThreadPool {
-	return &ThreadPool{
-		logger: logger.GetLogger(),
+// NewThreadPool 创建线程池并启动工作协程
+// 参数：config 配置对象, logger 日志记录器
+// 返回：线程池实例
+// max_queue 为 0 时表示队列不限长度；task_timeout 为空时任务不设超时
+func NewThreadPool(config *config.AppConfig, logger logger.Logger) *ThreadPool {
+	p := &ThreadPool{
+		logger:       logger,
+		queue:        newFairQueue(),
+		maxWorkers:   config.Threadpool.MaxWorkers,
+		maxQueue:     config.Threadpool.MaxQueue,
+		stopCh:       make(chan struct{}),
+		autoScale:    config.Threadpool.AutoScale,
+		minWorkers:   config.Threadpool.MinWorkers,
+		scaleUpWait:  parseDurationOr(config.Threadpool.ScaleUpWait, defaultScaleUpWait),
+		scaleUpQueue: config.Threadpool.ScaleUpQueue,
+		idleTimeout:  parseDurationOr(config.Threadpool.IdleTimeout, defaultIdleTimeout),
+		busyAt:       time.Now(),
+		running:      make(map[*runningJob]struct{}),
+	}
+	p.cond = sync.NewCond(&p.mu)
+	p.baseCtx, p.baseCancel = context.WithCancel(context.Background())
+
+	if p.maxWorkers <= 0 {
+		p.maxWorkers = 1
+	}
+	if !p.autoScale || p.minWorkers <= 0 || p.minWorkers > p.maxWorkers {
+		p.minWorkers = p.maxWorkers
+	}
+	if config.Threadpool.TaskTimeout != "" {
+		timeout, err := time.ParseDuration(config.Threadpool.TaskTimeout)
+		if err != nil {
+			logger.Warn("任务超时时间格式不合法，任务将不设超时", zap.String("task_timeout", config.Threadpool.TaskTimeout), zap.Error(err))
+		} else {
+			p.taskTimeout = timeout
+		}
+	}
+
+	if config.Threadpool.MemoryLimit != "" {
+		limit, err := parseMemoryLimit(config.Threadpool.MemoryLimit)
+		if err != nil {
+			logger.Warn("线程池内存限制格式不合法，不做内存准入控制", zap.String("memory_limit", config.Threadpool.MemoryLimit), zap.Error(err))
+		} else {
+			p.memoryLimit = limit
+		}
+	}
+
+	p.mu.Lock()
+	for i := 0; i < p.minWorkers; i++ {
+		p.startWorker()
+	}
+	p.mu.Unlock()
+
+	if p.autoScale {
+		go p.autoScaleLoop()
+	}
+	if p.memoryLimit > 0 {
+		go p.memoryMonitorLoop()
 	}
+
+	logger.Info("线程池启动",
+		zap.Int("min_workers", p.minWorkers),
+		zap.Int("max_workers", p.maxWorkers),
+		zap.Int("max_queue", p.maxQueue),
+		zap.Duration("task_timeout", p.taskTimeout),
+		zap.Bool("auto_scale", p.autoScale),
+		zap.Int64("memory_limit", p.memoryLimit))
+	return p
+}
+
+// Submit 以默认租户和普通优先级提交任务到队列
+// 参数：task 待执行任务
+// 返回：队列已满时返回 ErrQueueFull，线程池已停止时返回 ErrPoolStopped
+func (p *ThreadPool) Submit(task Job) error {
+	return p.SubmitWithOptions(task, JobOptions{Priority: PriorityNormal})
 }
 
-func (p *ThreadPool) Submit(task func()) {
+// SubmitWithOptions 按指定调度参数提交任务到队列
+// 参数：task 待执行任务, opts 调度参数
+// 返回：队列已满且任务不是 Admitted 时返回 ErrQueueFull，线程池已停止时返回 ErrPoolStopped
+func (p *ThreadPool) SubmitWithOptions(task Job, opts JobOptions) error {
+	if task == nil {
+		return fmt.Errorf("任务不能为空")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stopped {
+		return ErrPoolStopped
+	}
+	if opts.ID != "" && p.queue.contains(opts.ID) {
+		return fmt.Errorf("任务 %s 已在队列中", opts.ID)
+	}
+	if p.maxQueue > 0 && !opts.Admitted && p.queue.Len() >= p.maxQueue {
+		p.rejectedTasks++
+		return ErrQueueFull
+	}
+
+	p.queue.push(&queuedJob{
+		fn:         task,
+		id:         opts.ID,
+		tenant:     opts.Tenant,
+		priority:   opts.Priority,
+		memory:     opts.Memory,
+		enqueuedAt: time.Now(),
+	})
 	p.totalTasks++
-	p.activeTasks++
-	// Actual task execution logic
-	defer func() {
-		p.activeTasks--
-		p.completedTasks++
-	}()
-	task()
+	// 队列深度达到阈值时立即扩容，不必等待下一次检查
+	if p.autoScale && p.scaleUpQueue > 0 && p.queue.Len() >= p.scaleUpQueue {
+		p.scaleUp("queue_depth")
+	}
+	p.cond.Signal()
+	return nil
+}
+
+// Position 查询任务的排队位置
+// 参数：id 任务ID
+// 返回：排队位置（从1开始），任务不在队列中时返回 false
+func (p *ThreadPool) Position(id string) (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.queue.position(id)
+}
+
+// Cancel 将尚未开始执行的任务移出队列
+// 参数：id 任务ID
+// 返回：任务在队列中并已移除时返回 true，任务已开始执行或不存在时返回 false
+func (p *ThreadPool) Cancel(id string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.queue.remove(id) == nil {
+		return false
+	}
+	// 被移除的可能是因内存预算等待的队首任务，唤醒工作协程重新检查
+	p.cond.Broadcast()
+	return true
 }
 
+// Stop 停止线程池
+// 不再接受新任务，等待队列中剩余任务和正在执行的任务全部完成后返回
 func (p *ThreadPool) Stop() {
-	// Stop logic
+	p.shutdown()
+	p.wg.Wait()
+	p.baseCancel()
+	p.logger.Info("线程池已停止")
+}
+
+// StopNow 强制停止线程池
+// 不再接受新任务，取消正在执行的任务的 ctx，队列中剩余任务拿到已取消的 ctx 后应立即返回，全部返回后本方法返回
+func (p *ThreadPool) StopNow() {
+	p.shutdown()
+	p.baseCancel()
+	p.wg.Wait()
+	p.logger.Info("线程池已强制停止")
 }
 
+// shutdown 标记线程池已停止并唤醒全部工作协程，可重复调用
+func (p *ThreadPool) shutdown() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stopped {
+		return
+	}
+	p.stopped = true
+	close(p.stopCh)
+	p.cond.Broadcast()
+}
+
+// GetStats 获取线程池统计信息
 func (p *ThreadPool) GetStats() ThreadPoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	return ThreadPoolStats{
 		TotalTasks:     p.totalTasks,
 		ActiveTasks:    p.activeTasks,
 		CompletedTasks: p.completedTasks,
+		QueueLength:    p.queue.Len(),
+		RejectedTasks:  p.rejectedTasks,
+		TimeoutTasks:   p.timeoutTasks,
+		MaxWorkers:     p.maxWorkers,
+		MaxQueue:       p.maxQueue,
+		MinWorkers:     p.minWorkers,
+		CurrentWorkers: p.workers,
+		IdleWorkers:    p.idleWorkers,
+		ScaleUps:       p.scaleUps,
+		ScaleDowns:     p.scaleDowns,
+		MemoryLimit:    p.memoryLimit,
+		MemoryInUse:    p.memoryInUse,
+		MemoryDeferred: p.memoryDeferred,
+		MemoryBlocked:  p.memoryBlocked(),
+	}
+}
+
+// autoScaleLoop 定期检查负载并调整工作协程数量
+func (p *ThreadPool) autoScaleLoop() {
+	ticker := time.NewTicker(scaleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stopCh:
+			return
+		case now := <-ticker.C:
+			p.checkScale(now)
+		}
+	}
+}
+
+// checkScale 根据队列等待时间和空闲情况决定扩容或缩容
+// 扩容：队首任务等待超过 scale_up_wait 且没有空闲协程
+// 缩容：持续 idle_timeout 存在空闲协程且队列为空，每次回收一个
+func (p *ThreadPool) checkScale(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stopped {
+		return
+	}
+
+	if p.queue.Len() > 0 || p.idleWorkers == 0 {
+		p.busyAt = now
+	}
+
+	// 内存预算不足时增加协程也无法执行更多任务
+	if p.queue.Len() > 0 && p.idleWorkers == 0 && !p.memoryBlocked() && now.Sub(p.queue.oldest()) >= p.scaleUpWait {
+		p.scaleUp("queue_wait")
+		return
+	}
+
+	if p.idleWorkers > 0 && p.workers-p.retiring > p.minWorkers && now.Sub(p.busyAt) >= p.idleTimeout {
+		p.retiring++
+		p.scaleDowns++
+		p.busyAt = now
+		p.cond.Broadcast()
+		p.logger.Info("线程池缩容",
+			zap.Int("workers", p.workers-p.retiring),
+			zap.Int("idle_workers", p.idleWorkers),
+			zap.Duration("idle", p.idleTimeout))
+	}
+}
+
+// scaleUp 按队列长度扩容，不超过 max_workers，调用方需持有 mu
+func (p *ThreadPool) scaleUp(reason string) {
+	// 负载上升时撤销尚未生效的回收请求，只保留超出 max_workers 的部分
+	p.retiring = 0
+	if p.workers > p.maxWorkers {
+		p.retiring = p.workers - p.maxWorkers
+	}
+	available := p.maxWorkers - p.workers
+	add := p.queue.Len() - p.idleWorkers
+	if add > available {
+		add = available
+	}
+	if add <= 0 {
+		return
+	}
+	for i := 0; i < add; i++ {
+		p.startWorker()
+	}
+	p.scaleUps++
+	p.logger.Info("线程池扩容",
+		zap.String("reason", reason),
+		zap.Int("added", add),
+		zap.Int("workers", p.workers),
+		zap.Int("queue_length", p.queue.Len()))
+}
+
+// Resize 调整最大工作协程数
+// 扩大时立即启动新协程（开启 auto_scale 时按负载扩容）；缩小时空闲协程立即退出，
+// 忙碌协程在当前任务结束后退出，队列中的任务和正在执行的任务都不会丢失
+// 参数：maxWorkers 新的最大工作协程数
+// 返回：错误信息
+func (p *ThreadPool) Resize(maxWorkers int) error {
+	if maxWorkers <= 0 {
+		return fmt.Errorf("线程池最大工作线程数 %d 不合法", maxWorkers)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stopped {
+		return ErrPoolStopped
+	}
+
+	old := p.maxWorkers
+	p.maxWorkers = maxWorkers
+	if !p.autoScale || p.minWorkers > maxWorkers {
+		p.minWorkers = maxWorkers
+	}
+
+	// 目标协程数：固定大小时等于 max_workers，自动扩缩容时限制在 [min_workers, max_workers]
+	target := p.workers - p.retiring
+	if !p.autoScale || target > p.maxWorkers {
+		target = p.maxWorkers
+	}
+	if target < p.minWorkers {
+		target = p.minWorkers
+	}
+
+	current := p.workers - p.retiring
+	switch {
+	case target > current:
+		// 先撤销回收请求，不足部分再启动新协程
+		add := target - current
+		cancelled := add
+		if cancelled > p.retiring {
+			cancelled = p.retiring
+		}
+		p.retiring -= cancelled
+		for i := 0; i < add-cancelled; i++ {
+			p.startWorker()
+		}
+	case target < current:
+		p.retiring += current - target
+		p.cond.Broadcast()
+	}
+
+	p.logger.Info("线程池最大工作线程数调整",
+		zap.Int("old_max_workers", old),
+		zap.Int("max_workers", p.maxWorkers),
+		zap.Int("min_workers", p.minWorkers),
+		zap.Int("target_workers", target))
+	return nil
+}
+
+// SetMaxQueue 调整队列容量
+// 已在队列中的任务不受影响，队列长度超过新容量时新任务会被拒绝，直到队列回落
+// 参数：maxQueue 新的队列容量，0 表示不限长度
+// 返回：错误信息
+func (p *ThreadPool) SetMaxQueue(maxQueue int) error {
+	if maxQueue < 0 {
+		return fmt.Errorf("任务队列最大容量 %d 不合法", maxQueue)
 	}
-}
\ No newline at end of file
+
+	p.mu.Lock()
+	old := p.maxQueue
+	p.maxQueue = maxQueue
+	p.mu.Unlock()
+
+	p.logger.Info("线程池队列容量调整", zap.Int("old_max_queue", old), zap.Int("max_queue", maxQueue))
+	return nil
+}
+
+// SetTaskTimeout 调整单任务超时时间，只对之后开始执行的任务生效
+// 参数：timeout 超时时间，0 表示不设超时
+func (p *ThreadPool) SetTaskTimeout(timeout time.Duration) {
+	p.mu.Lock()
+	old := p.taskTimeout
+	p.taskTimeout = timeout
+	p.mu.Unlock()
+
+	p.logger.Info("线程池任务超时时间调整", zap.Duration("old_task_timeout", old), zap.Duration("task_timeout", timeout))
+}
+
+// RegisterConfigHandlers 注册线程池相关的配置热更新处理函数
+// 配置文件中 threadpool.max_workers、max_queue、task_timeout 变化时实时调整线程池
+// 线程池自身保存生效中的值，全局配置只在配置锁内同步写回，供 History 和其他模块读取
+func (p *ThreadPool) RegisterConfigHandlers() {
+	config.RegisterConfigHandler("threadpool.max_workers", func(value string) error {
+		maxWorkers, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		if err := p.Resize(maxWorkers); err != nil {
+			return err
+		}
+		config.GlobalConfig.Update(func(cfg *config.AppConfig) {
+			cfg.Threadpool.MaxWorkers = maxWorkers
+		})
+		return nil
+	})
+	config.RegisterConfigHandler("threadpool.max_queue", func(value string) error {
+		maxQueue, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		if err := p.SetMaxQueue(maxQueue); err != nil {
+			return err
+		}
+		config.GlobalConfig.Update(func(cfg *config.AppConfig) {
+			cfg.Threadpool.MaxQueue = maxQueue
+		})
+		return nil
+	})
+	config.RegisterConfigHandler("threadpool.task_timeout", func(value string) error {
+		var timeout time.Duration
+		if value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			timeout = d
+		}
+		p.SetTaskTimeout(timeout)
+		config.GlobalConfig.Update(func(cfg *config.AppConfig) {
+			cfg.Threadpool.TaskTimeout = value
+		})
+		return nil
+	})
+}
+
+// startWorker 启动一个工作协程，调用方需持有 mu
+func (p *ThreadPool) startWorker() {
+	p.workers++
+	p.wg.Add(1)
+	go p.worker()
+}
+
+// worker 工作协程主循环
+func (p *ThreadPool) worker() {
+	defer p.wg.Done()
+	for {
+		job, running, ok := p.next()
+		if !ok {
+			return
+		}
+		p.run(job, running)
+	}
+}
+
+// next 阻塞等待下一个可执行的任务
+// 队首任务超出内存预算时保持排队，直到运行中任务释放内存
+// 返回：任务及其内存占用记录，线程池停止且队列为空时返回 false
+func (p *ThreadPool) next() (*queuedJob, *runningJob, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.idleWorkers++
+	for p.retiring == 0 && !(p.stopped && p.queue.Len() == 0) {
+		if p.queue.Len() > 0 {
+			head := p.queue.peek()
+			if p.admit(head) {
+				break
+			}
+			if !head.deferred {
+				head.deferred = true
+				p.memoryDeferred++
+				p.logger.Info("内存预算不足，任务继续排队",
+					zap.String("job_id", head.id),
+					zap.Int64("memory", head.memory),
+					zap.Int64("memory_in_use", p.memoryInUse),
+					zap.Int64("memory_limit", p.memoryLimit))
+			}
+		}
+		p.cond.Wait()
+	}
+	p.idleWorkers--
+	// 有待回收名额时优先退出，队列中的任务由其余工作协程继续执行
+	if p.retiring > 0 {
+		p.retiring--
+		p.workers--
+		if p.queue.Len() > 0 {
+			// 把唤醒信号传给其他空闲协程，避免任务滞留
+			p.cond.Signal()
+		}
+		return nil, nil, false
+	}
+	if p.queue.Len() == 0 {
+		p.workers--
+		return nil, nil, false
+	}
+
+	job := p.queue.pop()
+	running := &runningJob{declared: job.memory}
+	p.running[running] = struct{}{}
+	p.memoryInUse += running.reserved()
+	p.activeTasks++
+	if job.deferred {
+		p.logger.Info("内存预算满足，任务开始执行",
+			zap.String("job_id", job.id),
+			zap.Int64("memory", job.memory),
+			zap.Int64("memory_in_use", p.memoryInUse))
+	}
+	return job, running, true
+}
+
+// run 执行单个任务，负责超时控制、内存占用释放和统计
+func (p *ThreadPool) run(job *queuedJob, running *runningJob) {
+	p.mu.Lock()
+	timeout := p.taskTimeout
+	p.mu.Unlock()
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(p.baseCtx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(p.baseCtx)
+	}
+	ctx = context.WithValue(ctx, runningJobKey{}, running)
+
+	defer func() {
+		if r := recover(); r != nil {
+			p.logger.Error("线程池任务异常退出", zap.Any("panic", r))
+		}
+		timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+		cancel()
+
+		p.mu.Lock()
+		delete(p.running, running)
+		p.recalcMemory()
+		p.activeTasks--
+		p.completedTasks++
+		if timedOut {
+			p.timeoutTasks++
+		}
+		p.mu.Unlock()
+	}()
+
+	job.fn(ctx)
+}
+
+// parseDurationOr 解析时长配置，为空或不合法时返回默认值
+func parseDurationOr(value string, def time.Duration) time.Duration {
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
+
+// parseMemoryLimit 解析线程池内存限制
+func parseMemoryLimit(value string) (int64, error) {
+	return config.ParseSize(value)
+}
@@ -0,0 +1,463 @@
+package threadpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"file-flow-service/config"
+
+	"go.uber.org/zap"
+)
+
+// testWait 测试中等待异步事件的最长时间
+const testWait = 5 * time.Second
+
+// nopLogger 测试中丢弃全部日志
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...zap.Field)    {}
+func (nopLogger) Info(string, ...zap.Field)     {}
+func (nopLogger) Warn(string, ...zap.Field)     {}
+func (nopLogger) Error(string, ...zap.Field)    {}
+func (nopLogger) LogError(string, ...zap.Field) {}
+func (nopLogger) Fatal(string, ...zap.Field)    {}
+func (nopLogger) SetLevel(string) error         { return nil }
+
+// newTestPool 按线程池配置创建线程池，测试结束时强制停止
+func newTestPool(t *testing.T, cfg config.Threadpool) *ThreadPool {
+	t.Helper()
+	p := NewThreadPool(&config.AppConfig{Threadpool: cfg}, nopLogger{})
+	t.Cleanup(p.StopNow)
+	return p
+}
+
+// gate 阻塞任务直到测试放行，started 在任务开始执行时收到通知
+type gate struct {
+	started chan string
+	release chan struct{}
+}
+
+func newGate() *gate {
+	return &gate{started: make(chan string, 100), release: make(chan struct{})}
+}
+
+// job 返回一个开始时上报 name、放行后才结束的任务
+func (g *gate) job(name string) Job {
+	return func(ctx context.Context) {
+		g.started <- name
+		<-g.release
+	}
+}
+
+// wait 等待 n 个任务开始执行，返回它们的名称
+func (g *gate) wait(t *testing.T, n int) []string {
+	t.Helper()
+	var names []string
+	for len(names) < n {
+		select {
+		case name := <-g.started:
+			names = append(names, name)
+		case <-time.After(testWait):
+			t.Fatalf("等待 %d 个任务开始执行超时，已开始 %v", n, names)
+		}
+	}
+	return names
+}
+
+// noneStarted 确认一段时间内没有新任务开始执行
+func (g *gate) noneStarted(t *testing.T) {
+	t.Helper()
+	select {
+	case name := <-g.started:
+		t.Fatalf("任务 %s 不应开始执行", name)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func submit(t *testing.T, p *ThreadPool, job Job, opts JobOptions) {
+	t.Helper()
+	if err := p.SubmitWithOptions(job, opts); err != nil {
+		t.Fatalf("提交任务 %q 失败: %v", opts.ID, err)
+	}
+}
+
+func TestSubmitQueueFull(t *testing.T) {
+	p := newTestPool(t, config.Threadpool{MaxWorkers: 1, MaxQueue: 2})
+	g := newGate()
+	defer close(g.release)
+
+	submit(t, p, g.job("running"), JobOptions{})
+	g.wait(t, 1)
+	submit(t, p, g.job("q1"), JobOptions{})
+	submit(t, p, g.job("q2"), JobOptions{})
+	if err := p.Submit(g.job("q3")); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("队列已满时应返回 ErrQueueFull，得到 %v", err)
+	}
+
+	stats := p.GetStats()
+	if stats.QueueLength != 2 || stats.RejectedTasks != 1 || stats.ActiveTasks != 1 || stats.TotalTasks != 3 {
+		t.Fatalf("统计信息不符: %+v", stats)
+	}
+}
+
+func TestSubmitAdmittedIgnoresQueueLimit(t *testing.T) {
+	p := newTestPool(t, config.Threadpool{MaxWorkers: 1, MaxQueue: 1})
+	g := newGate()
+	defer close(g.release)
+
+	submit(t, p, g.job("running"), JobOptions{})
+	g.wait(t, 1)
+	submit(t, p, g.job("q1"), JobOptions{})
+	// 服务重启后恢复的任务此前已被接收，队列已满也能入队
+	submit(t, p, g.job("recovered"), JobOptions{Admitted: true})
+	if err := p.Submit(g.job("q2")); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("队列已满时新任务应返回 ErrQueueFull，得到 %v", err)
+	}
+	if stats := p.GetStats(); stats.QueueLength != 2 {
+		t.Fatalf("队列长度为 %d，应为 2", stats.QueueLength)
+	}
+}
+
+func TestSubmitAfterStop(t *testing.T) {
+	p := newTestPool(t, config.Threadpool{MaxWorkers: 1})
+	p.Stop()
+	if err := p.Submit(func(context.Context) {}); !errors.Is(err, ErrPoolStopped) {
+		t.Fatalf("线程池停止后应返回 ErrPoolStopped，得到 %v", err)
+	}
+}
+
+func TestTaskTimeout(t *testing.T) {
+	p := newTestPool(t, config.Threadpool{MaxWorkers: 1, TaskTimeout: "50ms"})
+	done := make(chan error, 1)
+	start := time.Now()
+	submit(t, p, func(ctx context.Context) {
+		if _, ok := ctx.Deadline(); !ok {
+			done <- fmt.Errorf("任务 ctx 没有截止时间")
+			return
+		}
+		<-ctx.Done()
+		done <- ctx.Err()
+	}, JobOptions{})
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("任务 ctx 应因超时取消，得到 %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+			t.Fatalf("任务在 %s 后即被取消，早于 task_timeout", elapsed)
+		}
+	case <-time.After(testWait):
+		t.Fatal("任务超时后 ctx 没有被取消")
+	}
+	p.Stop()
+	if stats := p.GetStats(); stats.TimeoutTasks != 1 || stats.CompletedTasks != 1 {
+		t.Fatalf("统计信息不符: %+v", stats)
+	}
+}
+
+func TestSetTaskTimeout(t *testing.T) {
+	p := newTestPool(t, config.Threadpool{MaxWorkers: 1})
+	deadline := make(chan bool, 2)
+	probe := func(ctx context.Context) {
+		_, ok := ctx.Deadline()
+		deadline <- ok
+	}
+	submit(t, p, probe, JobOptions{})
+	if <-deadline {
+		t.Fatal("未配置 task_timeout 时任务不应有截止时间")
+	}
+	p.SetTaskTimeout(time.Minute)
+	submit(t, p, probe, JobOptions{})
+	if !<-deadline {
+		t.Fatal("调整 task_timeout 后开始的任务应有截止时间")
+	}
+}
+
+func TestResizeKeepsJobs(t *testing.T) {
+	p := newTestPool(t, config.Threadpool{MaxWorkers: 2})
+	g := newGate()
+
+	var mu sync.Mutex
+	var finished []string
+	job := func(name string) Job {
+		run := g.job(name)
+		return func(ctx context.Context) {
+			run(ctx)
+			mu.Lock()
+			finished = append(finished, name)
+			mu.Unlock()
+		}
+	}
+
+	submit(t, p, job("r1"), JobOptions{})
+	submit(t, p, job("r2"), JobOptions{})
+	g.wait(t, 2)
+	for i := 1; i <= 4; i++ {
+		submit(t, p, job(fmt.Sprintf("q%d", i)), JobOptions{})
+	}
+
+	// 缩小时正在执行和排队的任务都不丢失，之后同时执行的任务不超过新的上限
+	if err := p.Resize(1); err != nil {
+		t.Fatal(err)
+	}
+	if stats := p.GetStats(); stats.ActiveTasks != 2 || stats.QueueLength != 4 {
+		t.Fatalf("缩小后统计信息不符: %+v", stats)
+	}
+	g.release <- struct{}{}
+	g.release <- struct{}{}
+	for i := 0; i < 3; i++ {
+		g.wait(t, 1)
+		g.noneStarted(t)
+		g.release <- struct{}{}
+	}
+
+	// 扩大时立即启动新协程，排队任务与新任务同时执行
+	if err := p.Resize(3); err != nil {
+		t.Fatal(err)
+	}
+	submit(t, p, job("n1"), JobOptions{})
+	submit(t, p, job("n2"), JobOptions{})
+	g.wait(t, 3)
+	if stats := p.GetStats(); stats.CurrentWorkers != 3 || stats.ActiveTasks != 3 {
+		t.Fatalf("扩大后统计信息不符: %+v", stats)
+	}
+	close(g.release)
+	p.Stop()
+
+	if len(finished) != 8 {
+		t.Fatalf("应完成 8 个任务，完成了 %v", finished)
+	}
+	if err := p.Resize(0); err == nil {
+		t.Fatal("最大工作线程数为 0 时应返回错误")
+	}
+}
+
+func TestStopNowCancelsJobs(t *testing.T) {
+	p := newTestPool(t, config.Threadpool{MaxWorkers: 1})
+	started := make(chan struct{})
+	results := make(chan error, 2)
+	submit(t, p, func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		results <- ctx.Err()
+	}, JobOptions{})
+	submit(t, p, func(ctx context.Context) {
+		results <- ctx.Err()
+	}, JobOptions{})
+	<-started
+
+	stopped := make(chan struct{})
+	go func() {
+		p.StopNow()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(testWait):
+		t.Fatal("StopNow 没有返回")
+	}
+	// 正在执行的任务和尚未开始的任务拿到的 ctx 都已取消
+	for i := 0; i < 2; i++ {
+		if err := <-results; !errors.Is(err, context.Canceled) {
+			t.Fatalf("任务 ctx 应已取消，得到 %v", err)
+		}
+	}
+}
+
+func TestStopWaitsForJobs(t *testing.T) {
+	p := newTestPool(t, config.Threadpool{MaxWorkers: 1})
+	var ran []string
+	for _, name := range []string{"a", "b", "c"} {
+		name := name
+		submit(t, p, func(ctx context.Context) {
+			if ctx.Err() == nil {
+				ran = append(ran, name)
+			}
+		}, JobOptions{})
+	}
+	p.Stop()
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(ran, want) {
+		t.Fatalf("Stop 应等待排队任务执行完，执行了 %v", ran)
+	}
+}
+
+func TestPositionAndCancel(t *testing.T) {
+	p := newTestPool(t, config.Threadpool{MaxWorkers: 1})
+	g := newGate()
+	submit(t, p, g.job("running"), JobOptions{ID: "running"})
+	g.wait(t, 1)
+	for _, id := range []string{"a", "b", "c"} {
+		submit(t, p, g.job(id), JobOptions{ID: id, Priority: PriorityNormal})
+	}
+	submit(t, p, g.job("urgent"), JobOptions{ID: "urgent", Priority: PriorityHigh})
+
+	positions := map[string]int{"urgent": 1, "a": 2, "b": 3, "c": 4}
+	for id, want := range positions {
+		if got, ok := p.Position(id); !ok || got != want {
+			t.Errorf("任务 %s 的排队位置为 %d（%v），应为 %d", id, got, ok, want)
+		}
+	}
+	if _, ok := p.Position("running"); ok {
+		t.Error("正在执行的任务不应有排队位置")
+	}
+	if err := p.SubmitWithOptions(g.job("a"), JobOptions{ID: "a"}); err == nil {
+		t.Error("重复提交队列中的任务应返回错误")
+	}
+
+	if !p.Cancel("b") {
+		t.Fatal("移出排队中的任务应返回 true")
+	}
+	if p.Cancel("b") || p.Cancel("running") {
+		t.Fatal("移出已不在队列中的任务应返回 false")
+	}
+	if got, _ := p.Position("c"); got != 3 {
+		t.Fatalf("移出 b 后 c 的排队位置为 %d，应为 3", got)
+	}
+
+	close(g.release)
+	if got := g.wait(t, 3); !reflect.DeepEqual(got, []string{"urgent", "a", "c"}) {
+		t.Fatalf("执行顺序为 %v", got)
+	}
+	g.noneStarted(t)
+}
+
+// dispatchOrder 单个工作协程被阻塞时提交全部任务，放行后按执行顺序返回任务ID
+func dispatchOrder(t *testing.T, submitAll func(p *ThreadPool, job Job)) []string {
+	t.Helper()
+	p := newTestPool(t, config.Threadpool{MaxWorkers: 1})
+	g := newGate()
+	submit(t, p, g.job("block"), JobOptions{})
+	g.wait(t, 1)
+
+	var mu sync.Mutex
+	var order []string
+	submitAll(p, func(ctx context.Context) {
+		mu.Lock()
+		order = append(order, ctx.Value(orderKey{}).(string))
+		mu.Unlock()
+	})
+	close(g.release)
+	p.Stop()
+	return order
+}
+
+// orderKey 测试任务在 ctx 中携带自身ID的键
+type orderKey struct{}
+
+// named 包装任务，执行时在 ctx 中带上任务ID
+func named(id string, job Job) Job {
+	return func(ctx context.Context) {
+		job(context.WithValue(ctx, orderKey{}, id))
+	}
+}
+
+// countTenants 统计前 n 个执行的任务中各租户的数量，任务ID以租户名开头
+func countTenants(order []string, n int) map[string]int {
+	counts := make(map[string]int)
+	for _, id := range order[:n] {
+		counts[id[:1]]++
+	}
+	return counts
+}
+
+func TestFairShareBetweenTenants(t *testing.T) {
+	// 先提交的租户 a 不能占满工作协程，两个租户轮流执行
+	order := dispatchOrder(t, func(p *ThreadPool, job Job) {
+		for i := 0; i < 10; i++ {
+			id := fmt.Sprintf("a%d", i)
+			submit(t, p, named(id, job), JobOptions{ID: id, Tenant: "a"})
+		}
+		for i := 0; i < 3; i++ {
+			id := fmt.Sprintf("b%d", i)
+			submit(t, p, named(id, job), JobOptions{ID: id, Tenant: "b"})
+		}
+	})
+	want := []string{"a0", "b0", "a1", "b1", "a2", "b2", "a3", "a4", "a5", "a6", "a7", "a8", "a9"}
+	if !reflect.DeepEqual(order, want) {
+		t.Fatalf("执行顺序为 %v，应为 %v", order, want)
+	}
+}
+
+func TestFairShareWeightedByPriority(t *testing.T) {
+	// 高优先级的租户获得两倍于普通优先级租户的份额
+	order := dispatchOrder(t, func(p *ThreadPool, job Job) {
+		for i := 0; i < 12; i++ {
+			a, b := fmt.Sprintf("a%d", i), fmt.Sprintf("b%d", i)
+			submit(t, p, named(a, job), JobOptions{ID: a, Tenant: "a", Priority: PriorityNormal})
+			submit(t, p, named(b, job), JobOptions{ID: b, Tenant: "b", Priority: PriorityHigh})
+		}
+	})
+	if counts := countTenants(order, 12); counts["a"] != 4 || counts["b"] != 8 {
+		t.Fatalf("前 12 个任务中租户数量为 %v，应为 a=4 b=8", counts)
+	}
+}
+
+func TestFairShareIgnoresPrioritySpread(t *testing.T) {
+	// 租户 a 把任务分散到三个优先级，仍与只用高优先级的租户 b 平分份额，且自身按优先级执行
+	order := dispatchOrder(t, func(p *ThreadPool, job Job) {
+		priorities := []Priority{PriorityLow, PriorityNormal, PriorityHigh}
+		for i := 0; i < 30; i++ {
+			a, b := fmt.Sprintf("a%d", i), fmt.Sprintf("b%d", i)
+			submit(t, p, named(a, job), JobOptions{ID: a, Tenant: "a", Priority: priorities[i%3]})
+			submit(t, p, named(b, job), JobOptions{ID: b, Tenant: "b", Priority: PriorityHigh})
+		}
+	})
+	if counts := countTenants(order, 20); counts["a"] != 10 || counts["b"] != 10 {
+		t.Fatalf("前 20 个任务中租户数量为 %v，应为 a=10 b=10", counts)
+	}
+	var tenantA []string
+	for _, id := range order {
+		if id[0] == 'a' {
+			tenantA = append(tenantA, id)
+		}
+	}
+	if tenantA[0] != "a2" || tenantA[9] != "a29" || tenantA[10] != "a1" || tenantA[29] != "a27" {
+		t.Fatalf("租户 a 内应先执行高优先级任务，执行顺序为 %v", tenantA)
+	}
+}
+
+func TestMemoryBudgetDefersJobs(t *testing.T) {
+	p := newTestPool(t, config.Threadpool{MaxWorkers: 3, MemoryLimit: "1KB"})
+	g := newGate()
+	defer close(g.release)
+
+	submit(t, p, g.job("big"), JobOptions{ID: "big", Memory: 800})
+	g.wait(t, 1)
+	submit(t, p, g.job("second"), JobOptions{ID: "second", Memory: 800})
+	submit(t, p, g.job("third"), JobOptions{ID: "third", Memory: 100})
+
+	// 队首任务超出预算时保持排队，即使有空闲工作协程
+	g.noneStarted(t)
+	stats := p.GetStats()
+	if stats.MemoryLimit != 1024 || stats.MemoryInUse != 800 || stats.MemoryDeferred != 1 || !stats.MemoryBlocked || stats.QueueLength != 2 {
+		t.Fatalf("内存预算不足时统计信息不符: %+v", stats)
+	}
+
+	// 运行中任务结束释放内存后，排队任务按顺序开始执行
+	g.release <- struct{}{}
+	if got := g.wait(t, 2); !reflect.DeepEqual(got, []string{"second", "third"}) && !reflect.DeepEqual(got, []string{"third", "second"}) {
+		t.Fatalf("释放内存后开始执行的任务为 %v", got)
+	}
+	stats = p.GetStats()
+	if stats.MemoryInUse != 900 || stats.MemoryBlocked || stats.QueueLength != 0 {
+		t.Fatalf("释放内存后统计信息不符: %+v", stats)
+	}
+}
+
+func TestMemoryBudgetAdmitsOversizedJobAlone(t *testing.T) {
+	p := newTestPool(t, config.Threadpool{MaxWorkers: 1, MemoryLimit: "1KB"})
+	done := make(chan struct{})
+	// 单个任务超出预算时，没有其他任务在执行就放行，避免永远无法执行
+	submit(t, p, func(context.Context) { close(done) }, JobOptions{Memory: 4096})
+	select {
+	case <-done:
+	case <-time.After(testWait):
+		t.Fatal("超出预算的单个任务没有执行")
+	}
+}
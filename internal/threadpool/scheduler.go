@@ -0,0 +1,337 @@
+package threadpool
+
+import (
+	"container/heap"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Priority 任务优先级
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// defaultTenant 未指定提交者时使用的租户名
+const defaultTenant = "default"
+
+// ParsePriority 解析优先级字符串（high/normal/low），无法识别时返回 PriorityNormal
+func ParsePriority(value string) Priority {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "high":
+		return PriorityHigh
+	case "low":
+		return PriorityLow
+	default:
+		return PriorityNormal
+	}
+}
+
+// String 返回优先级名称
+func (p Priority) String() string {
+	switch p {
+	case PriorityHigh:
+		return "high"
+	case PriorityLow:
+		return "low"
+	default:
+		return "normal"
+	}
+}
+
+// weight 优先级对应的调度权重，权重越大获得的执行份额越多
+func (p Priority) weight() float64 {
+	switch p {
+	case PriorityHigh:
+		return 4
+	case PriorityLow:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// JobOptions 提交任务时的调度参数
+type JobOptions struct {
+	// ID 任务ID，用于查询排队位置，可为空
+	ID string
+	// Tenant 提交者（租户），同一租户的任务共享一个调度流，不论优先级
+	Tenant string
+	// Priority 任务优先级
+	Priority Priority
+	// Memory 任务声明的预计内存占用（字节），用于内存预算准入，0 表示不声明
+	Memory int64
+	// Admitted 任务此前已被接收（如服务重启后恢复的任务），不受 max_queue 限制
+	Admitted bool
+}
+
+// fairQueue 加权公平队列
+// 公平调度以提交者（租户）为单位：每个租户只有一个流，租户内的任务按优先级从高到低、
+// 同优先级先入队先执行。租户之间按虚拟完成时间调度，每次选择虚拟完成时间最小的租户执行其队首任务，
+// 租户的虚拟时间按队首任务优先级的权重推进，高优先级推进更慢因此获得更多份额。
+// 单个租户提交大量任务只会推后自己流的完成时间，把任务分散到多个优先级也不会获得额外的流
+type fairQueue struct {
+	flows   flowHeap
+	tenants map[string]*tenantFlow
+	byID    map[string]*queuedJob
+	size    int
+	vtime   float64
+	seq     uint64
+}
+
+// tenantFlow 单个租户的调度流
+type tenantFlow struct {
+	tenant string
+	jobs   jobHeap
+	// start 队首任务的虚拟开始时间，finish 上一个出队任务的虚拟完成时间
+	start  float64
+	finish float64
+	// index 在 flows 中的位置，流中没有任务时为 -1
+	index int
+}
+
+func newFairQueue() *fairQueue {
+	return &fairQueue{
+		tenants: make(map[string]*tenantFlow),
+		byID:    make(map[string]*queuedJob),
+	}
+}
+
+// Len 队列长度
+func (q *fairQueue) Len() int {
+	return q.size
+}
+
+// contains 判断指定ID的任务是否在队列中
+func (q *fairQueue) contains(id string) bool {
+	_, ok := q.byID[id]
+	return ok
+}
+
+// push 任务入队，租户原本没有排队任务时从当前虚拟时间开始计算
+func (q *fairQueue) push(job *queuedJob) {
+	if job.tenant == "" {
+		job.tenant = defaultTenant
+	}
+	q.seq++
+	job.seq = q.seq
+	if job.id != "" {
+		q.byID[job.id] = job
+	}
+	q.size++
+
+	flow, ok := q.tenants[job.tenant]
+	if !ok {
+		flow = &tenantFlow{tenant: job.tenant, index: -1}
+		q.tenants[job.tenant] = flow
+	}
+	heap.Push(&flow.jobs, job)
+	if flow.index < 0 {
+		flow.start = q.vtime
+		if flow.finish > flow.start {
+			flow.start = flow.finish
+		}
+		heap.Push(&q.flows, flow)
+	} else {
+		// 新任务的优先级可能高于原队首任务
+		heap.Fix(&q.flows, flow.index)
+	}
+}
+
+// peek 返回下一个将被调度的任务，不出队
+func (q *fairQueue) peek() *queuedJob {
+	if len(q.flows) == 0 {
+		return nil
+	}
+	return q.flows[0].jobs[0]
+}
+
+// pop 取出虚拟完成时间最小的租户的队首任务
+func (q *fairQueue) pop() *queuedJob {
+	if len(q.flows) == 0 {
+		return nil
+	}
+	flow := q.flows[0]
+	flow.finish = flow.tag()
+	if flow.finish > q.vtime {
+		q.vtime = flow.finish
+	}
+	job := heap.Pop(&flow.jobs).(*queuedJob)
+	if len(flow.jobs) > 0 {
+		flow.start = flow.finish
+		heap.Fix(&q.flows, flow.index)
+	} else {
+		heap.Remove(&q.flows, flow.index)
+	}
+	q.release(job, flow)
+	return job
+}
+
+// remove 从队列中移除指定ID的任务，被移除的任务不计入租户的份额
+func (q *fairQueue) remove(id string) *queuedJob {
+	job, ok := q.byID[id]
+	if !ok {
+		return nil
+	}
+	flow := q.tenants[job.tenant]
+	heap.Remove(&flow.jobs, job.index)
+	if len(flow.jobs) > 0 {
+		heap.Fix(&q.flows, flow.index)
+	} else {
+		heap.Remove(&q.flows, flow.index)
+	}
+	q.release(job, flow)
+	return job
+}
+
+// release 任务离开队列后清理索引，租户没有排队任务且完成时间不再领先时丢弃其调度流
+func (q *fairQueue) release(job *queuedJob, flow *tenantFlow) {
+	if job.id != "" {
+		delete(q.byID, job.id)
+	}
+	q.size--
+	if len(flow.jobs) == 0 && flow.finish <= q.vtime {
+		delete(q.tenants, flow.tenant)
+	}
+}
+
+// position 返回任务的排队位置（从1开始）
+// 按当前队列模拟出队顺序，不考虑之后新入队的任务
+func (q *fairQueue) position(id string) (int, bool) {
+	target, ok := q.byID[id]
+	if !ok {
+		return 0, false
+	}
+	flows := make([]*simFlow, 0, len(q.flows))
+	for _, flow := range q.flows {
+		jobs := append([]*queuedJob(nil), flow.jobs...)
+		sort.Slice(jobs, func(i, j int) bool { return jobs[i].before(jobs[j]) })
+		flows = append(flows, &simFlow{jobs: jobs, start: flow.start})
+	}
+	for pos := 1; ; pos++ {
+		next := 0
+		for i := 1; i < len(flows); i++ {
+			if flows[i].before(flows[next]) {
+				next = i
+			}
+		}
+		flow := flows[next]
+		if flow.jobs[0] == target {
+			return pos, true
+		}
+		flow.start = flow.tag()
+		flow.jobs = flow.jobs[1:]
+		if len(flow.jobs) == 0 {
+			flows = append(flows[:next], flows[next+1:]...)
+		}
+	}
+}
+
+// oldest 返回队列中等待时间最长的任务的入队时间
+func (q *fairQueue) oldest() time.Time {
+	var oldest time.Time
+	for _, flow := range q.flows {
+		for _, job := range flow.jobs {
+			if oldest.IsZero() || job.enqueuedAt.Before(oldest) {
+				oldest = job.enqueuedAt
+			}
+		}
+	}
+	return oldest
+}
+
+// tag 租户队首任务的虚拟完成时间
+func (f *tenantFlow) tag() float64 {
+	return f.start + 1/f.jobs[0].priority.weight()
+}
+
+// before 租户调度顺序比较：虚拟完成时间小的优先，相同时队首任务先入队的优先
+func (f *tenantFlow) before(other *tenantFlow) bool {
+	if tag, otherTag := f.tag(), other.tag(); tag != otherTag {
+		return tag < otherTag
+	}
+	return f.jobs[0].seq < other.jobs[0].seq
+}
+
+// simFlow 计算排队位置时使用的租户流副本，jobs 已按租户内顺序排好
+type simFlow struct {
+	jobs  []*queuedJob
+	start float64
+}
+
+func (f *simFlow) tag() float64 {
+	return f.start + 1/f.jobs[0].priority.weight()
+}
+
+func (f *simFlow) before(other *simFlow) bool {
+	if tag, otherTag := f.tag(), other.tag(); tag != otherTag {
+		return tag < otherTag
+	}
+	return f.jobs[0].seq < other.jobs[0].seq
+}
+
+// before 租户内调度顺序比较：优先级高的优先，相同时先入队的优先
+func (j *queuedJob) before(other *queuedJob) bool {
+	if j.priority != other.priority {
+		return j.priority > other.priority
+	}
+	return j.seq < other.seq
+}
+
+// jobHeap 租户内按调度顺序排列的最小堆
+type jobHeap []*queuedJob
+
+func (h jobHeap) Len() int           { return len(h) }
+func (h jobHeap) Less(i, j int) bool { return h[i].before(h[j]) }
+func (h jobHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *jobHeap) Push(x interface{}) {
+	job := x.(*queuedJob)
+	job.index = len(*h)
+	*h = append(*h, job)
+}
+
+func (h *jobHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	job := old[n-1]
+	old[n-1] = nil
+	job.index = -1
+	*h = old[:n-1]
+	return job
+}
+
+// flowHeap 有排队任务的租户，按虚拟完成时间排列的最小堆
+type flowHeap []*tenantFlow
+
+func (h flowHeap) Len() int           { return len(h) }
+func (h flowHeap) Less(i, j int) bool { return h[i].before(h[j]) }
+func (h flowHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *flowHeap) Push(x interface{}) {
+	flow := x.(*tenantFlow)
+	flow.index = len(*h)
+	*h = append(*h, flow)
+}
+
+func (h *flowHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	flow := old[n-1]
+	old[n-1] = nil
+	flow.index = -1
+	*h = old[:n-1]
+	return flow
+}
@@ -0,0 +1,88 @@
+package workflow
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// copyOutputs 将上游任务目录中声明的输出复制到下游任务目录的相同位置
+// 输出由任务进程生成，不跟随符号链接，以免把任务目录之外的文件复制给下游任务
+// 参数: srcDir 上游任务目录, dstDir 下游任务目录, outputs 输出路径（已规范化的相对路径）
+// 返回: 错误信息（输出不存在或是符号链接时返回错误）
+func copyOutputs(srcDir, dstDir string, outputs []string) error {
+	for _, output := range outputs {
+		for dir := filepath.Dir(output); dir != "."; dir = filepath.Dir(dir) {
+			if info, err := os.Lstat(filepath.Join(srcDir, dir)); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				return fmt.Errorf("输出 %s 所在的目录 %s 是符号链接，不复制", output, dir)
+			}
+		}
+		src := filepath.Join(srcDir, output)
+		info, err := os.Lstat(src)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("未生成输出 %s", output)
+		}
+		if err != nil {
+			return fmt.Errorf("读取输出 %s 失败: %v", output, err)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("输出 %s 是符号链接，不复制", output)
+		}
+		if !info.IsDir() {
+			if err := copyFile(src, filepath.Join(dstDir, output), info.Mode()); err != nil {
+				return err
+			}
+			continue
+		}
+		err = filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(srcDir, path)
+			if err != nil {
+				return err
+			}
+			target := filepath.Join(dstDir, rel)
+			switch {
+			case entry.IsDir():
+				return os.MkdirAll(target, 0755)
+			case entry.Type().IsRegular():
+				info, err := entry.Info()
+				if err != nil {
+					return err
+				}
+				return copyFile(path, target, info.Mode())
+			}
+			// 目录中的符号链接和特殊文件跳过
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("复制输出 %s 失败: %v", output, err)
+		}
+	}
+	return nil
+}
+
+// copyFile 复制单个文件，目标文件已存在时覆盖
+func copyFile(src, dst string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %v", err)
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("读取 %s 失败: %v", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return fmt.Errorf("创建 %s 失败: %v", dst, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("复制 %s 失败: %v", src, err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,207 @@
+package workflow
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidDefinition 工作流定义不合法
+var ErrInvalidDefinition = errors.New("工作流定义不合法")
+
+const (
+	// FailFast 任一节点失败时取消运行中的节点，不再提交其余节点（默认）
+	FailFast = "fail_fast"
+	// ContinueOnError 节点失败时只跳过依赖它的节点，其他分支继续执行
+	ContinueOnError = "continue"
+)
+
+// nodeIDPattern 节点ID会作为任务ID的一部分，只允许字母、数字、下划线、点和连字符
+var nodeIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Definition 工作流定义，YAML 或 JSON 格式
+type Definition struct {
+	// Name 工作流名称
+	Name string `yaml:"name" json:"name"`
+	// OnFailure 节点失败时的处理方式：fail_fast（默认）/continue
+	OnFailure string `yaml:"on_failure" json:"on_failure,omitempty"`
+	// Nodes 工作流节点，每个节点对应一个任务
+	Nodes []Node `yaml:"nodes" json:"nodes"`
+}
+
+// Node 工作流节点
+type Node struct {
+	// ID 节点ID，在工作流内唯一
+	ID string `yaml:"id" json:"id"`
+	// Cmd、Args、EnvType、EnvVersion 与 /api/execute 的参数一致，cmd 和 env_type 可省略，由执行器自动识别
+	Cmd        string   `yaml:"cmd" json:"cmd,omitempty"`
+	Args       []string `yaml:"args" json:"args,omitempty"`
+	EnvType    string   `yaml:"env_type" json:"env_type,omitempty"`
+	EnvVersion string   `yaml:"env_version" json:"env_version,omitempty"`
+	// Priority 任务优先级（high/normal/low）
+	Priority string `yaml:"priority" json:"priority,omitempty"`
+	// MaxAttempts 任务失败后最多执行次数，0 表示使用 task_retry 配置
+	MaxAttempts int `yaml:"max_attempts" json:"max_attempts,omitempty"`
+	// Idempotent 任务可安全重复执行，服务重启时中断的任务会重新排队
+	Idempotent bool `yaml:"idempotent" json:"idempotent,omitempty"`
+	// Files 提交前复制到任务目录的上传文件（/api/upload 返回的上传ID），通常用于没有上游的起始节点
+	Files []string `yaml:"files" json:"files,omitempty"`
+	// DependsOn 依赖的节点，全部成功后才提交本节点
+	DependsOn []string `yaml:"depends_on" json:"depends_on,omitempty"`
+	// Outputs 任务目录中的输出文件或目录（相对路径），下游节点提交前复制到下游任务目录的相同位置
+	Outputs []string `yaml:"outputs" json:"outputs,omitempty"`
+}
+
+// ParseDefinition 解析并校验 YAML 或 JSON 格式的工作流定义
+// 参数: data 工作流定义
+// 返回: 工作流定义，错误信息（格式或内容不合法时返回 ErrInvalidDefinition）
+func ParseDefinition(data []byte) (*Definition, error) {
+	// JSON 是 YAML 的子集，统一按 YAML 解析；不认识的字段视为拼写错误
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var def Definition
+	if err := decoder.Decode(&def); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDefinition, err)
+	}
+	if err := def.Validate(); err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// Validate 校验工作流定义：节点ID唯一，依赖的节点存在且没有环，输出路径在任务目录内，
+// 同一节点的多个上游不能声明相同的输出
+func (d *Definition) Validate() error {
+	if strings.TrimSpace(d.Name) == "" {
+		return fmt.Errorf("%w: 缺少工作流名称", ErrInvalidDefinition)
+	}
+	switch d.OnFailure {
+	case "", FailFast, ContinueOnError:
+	default:
+		return fmt.Errorf("%w: on_failure %q 应为 %s 或 %s", ErrInvalidDefinition, d.OnFailure, FailFast, ContinueOnError)
+	}
+	if len(d.Nodes) == 0 {
+		return fmt.Errorf("%w: 工作流没有节点", ErrInvalidDefinition)
+	}
+
+	nodes := make(map[string]*Node, len(d.Nodes))
+	for i := range d.Nodes {
+		node := &d.Nodes[i]
+		if !nodeIDPattern.MatchString(node.ID) {
+			return fmt.Errorf("%w: 节点ID %q 不合法，只能包含字母、数字、下划线、点和连字符", ErrInvalidDefinition, node.ID)
+		}
+		if _, exists := nodes[node.ID]; exists {
+			return fmt.Errorf("%w: 节点ID %s 重复", ErrInvalidDefinition, node.ID)
+		}
+		if node.MaxAttempts < 0 {
+			return fmt.Errorf("%w: 节点 %s 的 max_attempts %d 不合法", ErrInvalidDefinition, node.ID, node.MaxAttempts)
+		}
+		for j, output := range node.Outputs {
+			cleaned, err := cleanOutput(output)
+			if err != nil {
+				return fmt.Errorf("%w: 节点 %s 的输出 %v", ErrInvalidDefinition, node.ID, err)
+			}
+			node.Outputs[j] = cleaned
+		}
+		nodes[node.ID] = node
+	}
+
+	for _, node := range d.Nodes {
+		seen := make(map[string]bool)
+		outputs := make(map[string]string)
+		for _, dep := range node.DependsOn {
+			upstream, exists := nodes[dep]
+			if !exists {
+				return fmt.Errorf("%w: 节点 %s 依赖的节点 %s 不存在", ErrInvalidDefinition, node.ID, dep)
+			}
+			if dep == node.ID || seen[dep] {
+				return fmt.Errorf("%w: 节点 %s 的依赖 %s 重复或依赖自身", ErrInvalidDefinition, node.ID, dep)
+			}
+			seen[dep] = true
+			for _, output := range upstream.Outputs {
+				if other, exists := outputs[output]; exists {
+					return fmt.Errorf("%w: 节点 %s 的上游 %s 和 %s 都输出 %s", ErrInvalidDefinition, node.ID, other, dep, output)
+				}
+				outputs[output] = dep
+			}
+		}
+	}
+
+	if _, err := d.order(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// order 按依赖关系排序节点，上游节点在前，相互独立的节点保持定义中的顺序
+// 返回: 排序后的节点，错误信息（存在环时返回 ErrInvalidDefinition）
+func (d *Definition) order() ([]*Node, error) {
+	remaining := make(map[string]int, len(d.Nodes))
+	for _, node := range d.Nodes {
+		remaining[node.ID] = len(node.DependsOn)
+	}
+	ordered := make([]*Node, 0, len(d.Nodes))
+	done := make(map[string]bool, len(d.Nodes))
+	for len(ordered) < len(d.Nodes) {
+		progressed := false
+		for i := range d.Nodes {
+			node := &d.Nodes[i]
+			if done[node.ID] || remaining[node.ID] > 0 {
+				continue
+			}
+			done[node.ID] = true
+			ordered = append(ordered, node)
+			progressed = true
+			for _, other := range d.Nodes {
+				for _, dep := range other.DependsOn {
+					if dep == node.ID {
+						remaining[other.ID]--
+					}
+				}
+			}
+		}
+		if !progressed {
+			var cycle []string
+			for _, node := range d.Nodes {
+				if !done[node.ID] {
+					cycle = append(cycle, node.ID)
+				}
+			}
+			return nil, fmt.Errorf("%w: 节点之间存在循环依赖（%s）", ErrInvalidDefinition, strings.Join(cycle, ", "))
+		}
+	}
+	return ordered, nil
+}
+
+// onFailure 节点失败时的处理方式，未指定时为 fail_fast
+func (d *Definition) onFailure() string {
+	if d.OnFailure == "" {
+		return FailFast
+	}
+	return d.OnFailure
+}
+
+// node 按ID查找节点
+func (d *Definition) node(id string) *Node {
+	for i := range d.Nodes {
+		if d.Nodes[i].ID == id {
+			return &d.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// cleanOutput 规范化输出路径，拒绝绝对路径和任务目录之外的路径
+func cleanOutput(output string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(output))
+	if output == "" || cleaned == "." || filepath.IsAbs(cleaned) || cleaned == ".." ||
+		strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%q 不在任务目录内", output)
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,387 @@
+package workflow
+
+import (
+	"encoding/json"
+	"file-flow-service/config"
+	"file-flow-service/database"
+	"file-flow-service/file"
+	"file-flow-service/internal/taskmanager"
+	"file-flow-service/sandbox/execution"
+	"file-flow-service/utils/logger"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 工作流状态
+const (
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+// 节点状态：pending 等待上游节点，running 已提交任务（任务可能在排队、执行或等待重试），
+// 之后为 completed/failed/cancelled/skipped 之一
+const (
+	NodePending   = "pending"
+	NodeRunning   = "running"
+	NodeCompleted = "completed"
+	NodeFailed    = "failed"
+	NodeCancelled = "cancelled"
+	NodeSkipped   = "skipped"
+)
+
+// Snapshot 工作流及其节点的当前状态
+type Snapshot struct {
+	Workflow   database.Workflow
+	Definition Definition
+	Nodes      []database.WorkflowNode
+}
+
+// Engine 工作流引擎
+// 节点的依赖全部成功后，将引用的上传文件和上游的输出复制到节点的任务目录并通过 TaskManager.SubmitTask 提交；
+// 工作流和节点状态保存在数据库中，状态变化由任务结束的回调驱动
+type Engine struct {
+	config      *config.AppConfig
+	taskManager taskmanager.TaskManager
+	repository  *database.Repository
+	executor    execution.SandboxExecutor
+	uploads     *file.FileService
+	logger      logger.Logger
+	// mu 串行处理工作流的状态变化
+	mu sync.Mutex
+}
+
+// NewEngine 创建工作流引擎，并注册任务结束的回调
+// 参数: config 配置对象, taskManager 任务管理器, repository 任务仓库, executor 沙盒执行器,
+// uploads 上传文件的存储, logger 日志对象
+func NewEngine(config *config.AppConfig, taskManager taskmanager.TaskManager, repository *database.Repository, executor execution.SandboxExecutor, uploads *file.FileService, logger logger.Logger) *Engine {
+	e := &Engine{
+		config:      config,
+		taskManager: taskManager,
+		repository:  repository,
+		executor:    executor,
+		uploads:     uploads,
+		logger:      logger,
+	}
+	taskManager.OnTaskFinished(e.handleTaskFinished)
+	return e
+}
+
+// Submit 保存工作流并提交没有依赖的节点
+// 参数: def 工作流定义, creator 创建者（也作为节点任务的创建者）
+// 返回: 工作流ID，错误信息（定义不合法时返回 ErrInvalidDefinition）
+func (e *Engine) Submit(def *Definition, creator string) (string, error) {
+	if e.executor == nil {
+		return "", fmt.Errorf("沙盒执行器未初始化")
+	}
+	if err := def.Validate(); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(def)
+	if err != nil {
+		return "", fmt.Errorf("序列化工作流定义失败: %v", err)
+	}
+
+	workflow := &database.Workflow{
+		ID:         fmt.Sprintf("wf_%d", time.Now().UnixNano()),
+		Name:       def.Name,
+		Status:     StatusRunning,
+		OnFailure:  def.onFailure(),
+		Definition: string(data),
+		Creator:    creator,
+		CreatedAt:  time.Now(),
+	}
+	nodes := make([]database.WorkflowNode, 0, len(def.Nodes))
+	for _, node := range def.Nodes {
+		nodes = append(nodes, database.WorkflowNode{WorkflowID: workflow.ID, NodeID: node.ID, Status: NodePending})
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.repository.CreateWorkflow(workflow, nodes); err != nil {
+		return "", err
+	}
+	e.logger.Info("工作流已提交", zap.String("workflow_id", workflow.ID), zap.String("name", workflow.Name), zap.Int("nodes", len(nodes)))
+	if err := e.advance(workflow); err != nil {
+		return workflow.ID, err
+	}
+	return workflow.ID, nil
+}
+
+// Get 查询工作流及其节点的当前状态
+// 返回: 工作流状态，错误信息（不存在时返回 database.ErrWorkflowNotFound）
+func (e *Engine) Get(workflowID string) (*Snapshot, error) {
+	workflow, err := e.repository.GetWorkflow(workflowID)
+	if err != nil {
+		return nil, err
+	}
+	var def Definition
+	if err := json.Unmarshal([]byte(workflow.Definition), &def); err != nil {
+		return nil, fmt.Errorf("解析工作流定义失败: %v", err)
+	}
+	nodes, err := e.repository.GetWorkflowNodes(workflowID)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{Workflow: *workflow, Definition: def, Nodes: nodes}, nil
+}
+
+// Recover 服务启动时继续执行上次未结束的工作流，应在 TaskManager.Recover 之后调用
+// 服务停止期间已结束的节点任务在这里补记结果，之后按正常流程提交就绪的节点
+func (e *Engine) Recover() error {
+	workflows, err := e.repository.GetWorkflowsByStatus(StatusRunning)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i := range workflows {
+		workflow := &workflows[i]
+		nodes, err := e.repository.GetWorkflowNodes(workflow.ID)
+		if err != nil {
+			return err
+		}
+		for j := range nodes {
+			node := &nodes[j]
+			if node.Status != NodeRunning {
+				continue
+			}
+			task, err := e.repository.GetTaskByID(node.TaskID)
+			if err != nil {
+				e.finishNode(node, NodeFailed, "任务记录不存在")
+				continue
+			}
+			if taskmanager.IsFinal(task.Status) {
+				e.finishNode(node, nodeStatus(task.Status), task.ErrorMessage)
+			}
+		}
+		if err := e.advance(workflow); err != nil {
+			e.logger.Error("恢复工作流失败", zap.String("workflow_id", workflow.ID), zap.Error(err))
+		}
+	}
+	e.logger.Info("工作流恢复完成", zap.Int("workflows", len(workflows)))
+	return nil
+}
+
+// handleTaskFinished 节点任务结束后记录节点结果并推进工作流
+func (e *Engine) handleTaskFinished(taskID, status string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	node, err := e.repository.GetWorkflowNodeByTask(taskID)
+	if err != nil || node == nil || node.Status != NodeRunning {
+		return
+	}
+	var message string
+	if status != taskmanager.StatusSucceeded {
+		if task, err := e.repository.GetTaskByID(taskID); err == nil {
+			message = task.ErrorMessage
+		}
+	}
+	e.finishNode(node, nodeStatus(status), message)
+
+	workflow, err := e.repository.GetWorkflow(node.WorkflowID)
+	if err != nil || workflow.Status != StatusRunning {
+		return
+	}
+	if err := e.advance(workflow); err != nil {
+		e.logger.Error("推进工作流失败", zap.String("workflow_id", workflow.ID), zap.Error(err))
+	}
+}
+
+// advance 根据节点状态推进工作流，调用方需持有 e.mu
+// 依赖全部成功的节点被提交；上游失败的节点被跳过；fail_fast 模式下有节点失败时取消其余节点；
+// 全部节点结束后写回工作流的最终状态
+func (e *Engine) advance(workflow *database.Workflow) error {
+	var def Definition
+	if err := json.Unmarshal([]byte(workflow.Definition), &def); err != nil {
+		return fmt.Errorf("解析工作流定义失败: %v", err)
+	}
+	ordered, err := def.order()
+	if err != nil {
+		return err
+	}
+	records, err := e.repository.GetWorkflowNodes(workflow.ID)
+	if err != nil {
+		return err
+	}
+	nodes := make(map[string]*database.WorkflowNode, len(records))
+	for i := range records {
+		nodes[records[i].NodeID] = &records[i]
+	}
+
+	// 按依赖顺序处理，上游节点的变化在同一轮中传递给下游
+	for _, node := range ordered {
+		state := nodes[node.ID]
+		if state.Status != NodePending {
+			continue
+		}
+		if workflow.OnFailure == FailFast && failed(nodes) {
+			break
+		}
+		ready := true
+		for _, dep := range node.DependsOn {
+			switch nodes[dep].Status {
+			case NodeCompleted:
+			case NodePending, NodeRunning:
+				ready = false
+			default:
+				ready = false
+				if state.Status == NodePending {
+					e.finishNode(state, NodeSkipped, fmt.Sprintf("上游节点 %s 未成功", dep))
+				}
+			}
+		}
+		if ready {
+			e.submitNode(workflow, &def, node, nodes)
+		}
+	}
+
+	if workflow.OnFailure == FailFast && failed(nodes) {
+		e.abort(workflow, nodes)
+	}
+
+	var failedNodes []string
+	for _, node := range ordered {
+		switch nodes[node.ID].Status {
+		case NodePending, NodeRunning:
+			return nil
+		case NodeCompleted:
+		default:
+			failedNodes = append(failedNodes, node.ID)
+		}
+	}
+	workflow.Status = StatusCompleted
+	if len(failedNodes) > 0 {
+		workflow.Status = StatusFailed
+		if workflow.ErrorMessage == "" {
+			workflow.ErrorMessage = "未成功的节点: " + strings.Join(failedNodes, ", ")
+		}
+	}
+	workflow.FinishedAt = time.Now()
+	if err := e.repository.UpdateWorkflow(workflow); err != nil {
+		return err
+	}
+	e.logger.Info("工作流已结束", zap.String("workflow_id", workflow.ID), zap.String("status", workflow.Status))
+	return nil
+}
+
+// submitNode 将节点引用的上传文件和上游节点的输出复制到节点的任务目录并提交任务
+// 上游的输出在上传文件之后复制，同名时以上游输出为准；复制或提交失败时节点标记为 failed
+func (e *Engine) submitNode(workflow *database.Workflow, def *Definition, node *Node, nodes map[string]*database.WorkflowNode) {
+	state := nodes[node.ID]
+	taskID := workflow.ID + "_" + node.ID
+	taskDir, err := e.executor.CreateTaskDirectory(taskID)
+	if err != nil {
+		e.finishNode(state, NodeFailed, err.Error())
+		return
+	}
+	for _, uploadID := range node.Files {
+		if err := e.uploads.CopyTo(uploadID, taskDir); err != nil {
+			e.finishNode(state, NodeFailed, err.Error())
+			return
+		}
+	}
+	for _, dep := range node.DependsOn {
+		upstream := def.node(dep)
+		srcDir := filepath.Join(e.config.Sandbox.Execution.TasksPath, nodes[dep].TaskID)
+		if err := copyOutputs(srcDir, taskDir, upstream.Outputs); err != nil {
+			e.finishNode(state, NodeFailed, fmt.Sprintf("上游节点 %s %v", dep, err))
+			return
+		}
+	}
+
+	record := &database.Task{
+		ID:          taskID,
+		Name:        workflow.Name + "/" + node.ID,
+		Creator:     workflow.Creator,
+		Description: fmt.Sprintf("工作流 %s 的节点 %s", workflow.ID, node.ID),
+		Priority:    node.Priority,
+		Idempotent:  node.Idempotent,
+	}
+	if node.MaxAttempts > 0 {
+		record.RetryPolicy = &database.RetryPolicy{MaxAttempts: node.MaxAttempts}
+	}
+	task := taskmanager.NewSandboxTask(record, e.executor, node.Cmd, node.Args, node.EnvType, node.EnvVersion)
+
+	// 先记录任务ID再提交：提交后服务崩溃时，重启后可以按任务记录恢复节点状态
+	state.Status = NodeRunning
+	state.TaskID = taskID
+	state.StartedAt = time.Now()
+	if err := e.repository.UpdateWorkflowNode(state); err != nil {
+		state.Status = NodePending
+		return
+	}
+	if err := e.taskManager.SubmitTask(task); err != nil {
+		e.finishNode(state, NodeFailed, "提交任务失败: "+err.Error())
+		return
+	}
+	e.logger.Info("工作流节点已提交", zap.String("workflow_id", workflow.ID), zap.String("node_id", node.ID), zap.String("task_id", taskID))
+}
+
+// abort fail_fast 模式下有节点失败时，取消运行中的节点，未提交的节点标记为 cancelled
+// 运行中的节点在任务结束回调中记录结果
+func (e *Engine) abort(workflow *database.Workflow, nodes map[string]*database.WorkflowNode) {
+	if workflow.ErrorMessage == "" {
+		for _, node := range nodes {
+			if node.Status == NodeFailed || node.Status == NodeCancelled {
+				workflow.ErrorMessage = fmt.Sprintf("节点 %s 未成功，已终止工作流", node.NodeID)
+				break
+			}
+		}
+		e.repository.UpdateWorkflow(workflow)
+		e.logger.Warn("工作流节点失败，终止其余节点", zap.String("workflow_id", workflow.ID), zap.String("reason", workflow.ErrorMessage))
+	}
+	for _, node := range nodes {
+		switch node.Status {
+		case NodePending:
+			e.finishNode(node, NodeCancelled, workflow.ErrorMessage)
+		case NodeRunning:
+			if err := e.taskManager.CancelTask(node.TaskID, taskmanager.ActorWorkflow, workflow.ErrorMessage); err != nil {
+				e.logger.Warn("取消工作流节点任务失败", zap.String("task_id", node.TaskID), zap.Error(err))
+			}
+		}
+	}
+}
+
+// finishNode 记录节点的最终状态，失败只记录日志
+func (e *Engine) finishNode(node *database.WorkflowNode, status, message string) {
+	node.Status = status
+	node.ErrorMessage = message
+	node.FinishedAt = time.Now()
+	if err := e.repository.UpdateWorkflowNode(node); err != nil {
+		return
+	}
+	if status != NodeCompleted {
+		e.logger.Warn("工作流节点未成功", zap.String("workflow_id", node.WorkflowID), zap.String("node_id", node.NodeID),
+			zap.String("status", status), zap.String("reason", message))
+	}
+}
+
+// failed 是否有节点失败或被取消
+func failed(nodes map[string]*database.WorkflowNode) bool {
+	for _, node := range nodes {
+		if node.Status == NodeFailed || node.Status == NodeCancelled {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeStatus 任务的最终状态对应的节点状态
+func nodeStatus(taskStatus string) string {
+	switch taskStatus {
+	case taskmanager.StatusSucceeded:
+		return NodeCompleted
+	case taskmanager.StatusCancelled:
+		return NodeCancelled
+	default:
+		return NodeFailed
+	}
+}
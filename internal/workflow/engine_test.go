@@ -0,0 +1,319 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"file-flow-service/config"
+	"file-flow-service/database"
+	"file-flow-service/internal/service/interfaces"
+	"file-flow-service/internal/taskmanager"
+	"file-flow-service/sandbox/environments"
+	"file-flow-service/sandbox/execution"
+	"file-flow-service/utils/logger"
+
+	"go.uber.org/zap"
+)
+
+// nopLogger 测试中丢弃全部日志
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...zap.Field)    {}
+func (nopLogger) Info(string, ...zap.Field)     {}
+func (nopLogger) Warn(string, ...zap.Field)     {}
+func (nopLogger) Error(string, ...zap.Field)    {}
+func (nopLogger) LogError(string, ...zap.Field) {}
+func (nopLogger) Fatal(string, ...zap.Field)    {}
+func (nopLogger) SetLevel(string) error         { return nil }
+
+// stubTaskManager 只记录提交和取消的任务，任务结果由测试调用 finish 上报
+// 未实现的方法由嵌入的 nil 接口提供，被调用时 panic
+type stubTaskManager struct {
+	taskmanager.TaskManager
+	submitted []string
+	cancelled []string
+	listener  taskmanager.TaskListener
+}
+
+func (m *stubTaskManager) SubmitTask(task interfaces.TaskInterface) error {
+	m.submitted = append(m.submitted, task.GetID())
+	return nil
+}
+
+func (m *stubTaskManager) CancelTask(taskID, actor, reason string) error {
+	if actor != taskmanager.ActorWorkflow {
+		return errors.New("取消任务的发起方应为工作流")
+	}
+	m.cancelled = append(m.cancelled, taskID)
+	return nil
+}
+
+func (m *stubTaskManager) OnTaskFinished(listener taskmanager.TaskListener) {
+	m.listener = listener
+}
+
+// finish 上报任务结束
+func (m *stubTaskManager) finish(taskID, status string) {
+	m.listener(taskID, status)
+}
+
+// stubExecutor 只在 tasksPath 下创建任务目录的沙盒执行器
+type stubExecutor struct {
+	tasksPath string
+}
+
+func (e *stubExecutor) Init(*config.AppConfig, logger.Logger, environments.EnvironmentManager) error {
+	return nil
+}
+
+func (e *stubExecutor) ExecuteTask(context.Context, execution.ExecutionRequest) (*execution.ExecutionResult, error) {
+	return nil, errors.New("测试中不执行任务")
+}
+
+func (e *stubExecutor) AttachTask(context.Context, execution.AttachRequest) (*execution.ExecutionResult, error) {
+	return nil, execution.ErrProcessNotFound
+}
+
+func (e *stubExecutor) CreateTaskDirectory(taskID string) (string, error) {
+	dir := filepath.Join(e.tasksPath, taskID)
+	return dir, os.MkdirAll(dir, 0755)
+}
+
+func (e *stubExecutor) CleanupTaskDirectory(taskID string) error {
+	return os.RemoveAll(filepath.Join(e.tasksPath, taskID))
+}
+
+// newTestEngine 创建使用内存数据库、桩任务管理器和临时任务目录的工作流引擎
+func newTestEngine(t *testing.T) (*Engine, *stubTaskManager, string) {
+	t.Helper()
+	conn, err := database.Open(config.Database{Connection: ":memory:"}, nopLogger{})
+	if err != nil {
+		t.Fatalf("打开内存数据库失败: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	repository := database.NewRepository(conn, nopLogger{})
+
+	tasksPath := t.TempDir()
+	cfg := &config.AppConfig{}
+	cfg.Sandbox.Execution.TasksPath = tasksPath
+	tm := &stubTaskManager{}
+	engine := NewEngine(cfg, tm, repository, &stubExecutor{tasksPath: tasksPath}, nil, nopLogger{})
+	return engine, tm, tasksPath
+}
+
+// submit 解析并提交工作流定义
+func submit(t *testing.T, engine *Engine, definition string) string {
+	t.Helper()
+	def, err := ParseDefinition([]byte(definition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	workflowID, err := engine.Submit(def, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return workflowID
+}
+
+// snapshot 查询工作流状态和节点ID到节点状态的映射
+func snapshot(t *testing.T, engine *Engine, workflowID string) (*database.Workflow, map[string]database.WorkflowNode) {
+	t.Helper()
+	s, err := engine.Get(workflowID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := make(map[string]database.WorkflowNode, len(s.Nodes))
+	for _, node := range s.Nodes {
+		nodes[node.NodeID] = node
+	}
+	return &s.Workflow, nodes
+}
+
+// assertNodes 检查节点状态
+func assertNodes(t *testing.T, nodes map[string]database.WorkflowNode, want map[string]string) {
+	t.Helper()
+	for id, status := range want {
+		if nodes[id].Status != status {
+			t.Errorf("节点 %s 的状态为 %s，应为 %s（%s）", id, nodes[id].Status, status, nodes[id].ErrorMessage)
+		}
+	}
+}
+
+// writeFile 在任务目录中写入文件
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseDefinitionRejectsInvalidGraph(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition string
+	}{
+		{"循环依赖", `
+name: wf
+nodes:
+  - {id: a, depends_on: [c]}
+  - {id: b, depends_on: [a]}
+  - {id: c, depends_on: [b]}
+`},
+		{"依赖自身", `
+name: wf
+nodes:
+  - {id: a, depends_on: [a]}
+`},
+		{"依赖的节点不存在", `
+name: wf
+nodes:
+  - {id: a}
+  - {id: b, depends_on: [missing]}
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseDefinition([]byte(tt.definition)); !errors.Is(err, ErrInvalidDefinition) {
+				t.Fatalf("应返回 %v，得到 %v", ErrInvalidDefinition, err)
+			}
+		})
+	}
+}
+
+func TestFailFastCancelsRemainingNodes(t *testing.T) {
+	engine, tm, _ := newTestEngine(t)
+	workflowID := submit(t, engine, `
+name: wf
+nodes:
+  - {id: a}
+  - {id: b}
+  - {id: c, depends_on: [a]}
+`)
+	if len(tm.submitted) != 2 {
+		t.Fatalf("应提交没有依赖的 2 个节点，得到 %v", tm.submitted)
+	}
+
+	tm.finish(workflowID+"_a", taskmanager.StatusFailed)
+	if len(tm.cancelled) != 1 || tm.cancelled[0] != workflowID+"_b" {
+		t.Fatalf("应取消运行中的节点 b，得到 %v", tm.cancelled)
+	}
+	workflow, nodes := snapshot(t, engine, workflowID)
+	assertNodes(t, nodes, map[string]string{"a": NodeFailed, "b": NodeRunning, "c": NodeCancelled})
+	if workflow.Status != StatusRunning {
+		t.Fatalf("节点 b 结束前工作流状态为 %s，应为 %s", workflow.Status, StatusRunning)
+	}
+
+	tm.finish(workflowID+"_b", taskmanager.StatusCancelled)
+	workflow, nodes = snapshot(t, engine, workflowID)
+	assertNodes(t, nodes, map[string]string{"b": NodeCancelled})
+	if workflow.Status != StatusFailed || workflow.ErrorMessage == "" {
+		t.Fatalf("工作流状态为 %s（%s），应为 %s", workflow.Status, workflow.ErrorMessage, StatusFailed)
+	}
+	if len(tm.submitted) != 2 {
+		t.Errorf("节点失败后不应再提交节点，得到 %v", tm.submitted)
+	}
+}
+
+func TestContinueSkipsDownstreamNodes(t *testing.T) {
+	engine, tm, _ := newTestEngine(t)
+	workflowID := submit(t, engine, `
+name: wf
+on_failure: continue
+nodes:
+  - {id: a}
+  - {id: b}
+  - {id: c, depends_on: [a]}
+  - {id: d, depends_on: [c]}
+  - {id: e, depends_on: [b]}
+`)
+
+	tm.finish(workflowID+"_a", taskmanager.StatusFailed)
+	if len(tm.cancelled) != 0 {
+		t.Fatalf("continue 模式下不应取消其他分支，得到 %v", tm.cancelled)
+	}
+	_, nodes := snapshot(t, engine, workflowID)
+	assertNodes(t, nodes, map[string]string{"a": NodeFailed, "b": NodeRunning, "c": NodeSkipped, "d": NodeSkipped, "e": NodePending})
+
+	tm.finish(workflowID+"_b", taskmanager.StatusSucceeded)
+	tm.finish(workflowID+"_e", taskmanager.StatusSucceeded)
+	workflow, nodes := snapshot(t, engine, workflowID)
+	assertNodes(t, nodes, map[string]string{"b": NodeCompleted, "e": NodeCompleted})
+	if workflow.Status != StatusFailed {
+		t.Fatalf("有节点未成功时工作流状态为 %s，应为 %s", workflow.Status, StatusFailed)
+	}
+	want := []string{workflowID + "_a", workflowID + "_b", workflowID + "_e"}
+	if strings.Join(tm.submitted, ",") != strings.Join(want, ",") {
+		t.Errorf("提交的任务为 %v，应为 %v", tm.submitted, want)
+	}
+}
+
+func TestOutputsCopiedToDownstream(t *testing.T) {
+	engine, tm, tasksPath := newTestEngine(t)
+	workflowID := submit(t, engine, `
+name: wf
+nodes:
+  - {id: a, outputs: [result.txt, out]}
+  - {id: b, depends_on: [a]}
+`)
+	upstream := filepath.Join(tasksPath, workflowID+"_a")
+	writeFile(t, filepath.Join(upstream, "result.txt"), "42")
+	writeFile(t, filepath.Join(upstream, "out", "nested", "data.csv"), "x,y")
+	writeFile(t, filepath.Join(upstream, "scratch.log"), "未声明的文件")
+
+	tm.finish(workflowID+"_a", taskmanager.StatusSucceeded)
+	_, nodes := snapshot(t, engine, workflowID)
+	assertNodes(t, nodes, map[string]string{"a": NodeCompleted, "b": NodeRunning})
+
+	downstream := filepath.Join(tasksPath, workflowID+"_b")
+	for path, want := range map[string]string{"result.txt": "42", "out/nested/data.csv": "x,y"} {
+		data, err := os.ReadFile(filepath.Join(downstream, path))
+		if err != nil {
+			t.Fatalf("下游任务目录中缺少输出 %s: %v", path, err)
+		}
+		if string(data) != want {
+			t.Errorf("输出 %s 的内容为 %q，应为 %q", path, data, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(downstream, "scratch.log")); !os.IsNotExist(err) {
+		t.Errorf("未声明的文件不应复制到下游任务目录")
+	}
+}
+
+func TestSymlinkOutputRejected(t *testing.T) {
+	engine, tm, tasksPath := newTestEngine(t)
+	workflowID := submit(t, engine, `
+name: wf
+nodes:
+  - {id: a, outputs: [result.txt]}
+  - {id: b, depends_on: [a]}
+`)
+	secret := filepath.Join(t.TempDir(), "secret")
+	writeFile(t, secret, "任务目录之外的文件")
+	upstream := filepath.Join(tasksPath, workflowID+"_a")
+	if err := os.Symlink(secret, filepath.Join(upstream, "result.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	tm.finish(workflowID+"_a", taskmanager.StatusSucceeded)
+	workflow, nodes := snapshot(t, engine, workflowID)
+	assertNodes(t, nodes, map[string]string{"a": NodeCompleted, "b": NodeFailed})
+	if !strings.Contains(nodes["b"].ErrorMessage, "符号链接") {
+		t.Errorf("节点 b 的错误信息为 %q，应说明输出是符号链接", nodes["b"].ErrorMessage)
+	}
+	if workflow.Status != StatusFailed {
+		t.Errorf("工作流状态为 %s，应为 %s", workflow.Status, StatusFailed)
+	}
+	if len(tm.submitted) != 1 {
+		t.Errorf("复制输出失败的节点不应提交，得到 %v", tm.submitted)
+	}
+	if _, err := os.Lstat(filepath.Join(tasksPath, workflowID+"_b", "result.txt")); !os.IsNotExist(err) {
+		t.Errorf("符号链接的输出不应复制到下游任务目录")
+	}
+}
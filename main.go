@@ -5,6 +5,7 @@ package main
 
 import (
 	"file-flow-service/config"
+	"file-flow-service/database"
 	"file-flow-service/initialization"
 	"file-flow-service/internal/restart"
 	"file-flow-service/internal/service"
@@ -13,9 +14,15 @@ import (
 	"file-flow-service/utils/logger"
 	"file-flow-service/web"
 	"log"
+	"time"
+
+	"go.uber.org/zap"
 )
 
 func main() {
+	// 作为沙盒初始化子进程启动时直接执行任务命令，不会返回
+	execution.RunSandboxInit()
+
 	// 检查是否需要初始化（首次运行时执行）
 	if initialization.IsInitialRun() {
 		if err := initialization.InitApp(); err != nil {
@@ -39,6 +46,14 @@ func main() {
 	}
 	appLogger := logger.GetLogger()
 
+	// 打开数据库并执行表结构迁移，整个进程共用一个连接
+	dbConn, err := database.Open(appConfig.Database, appLogger)
+	if err != nil {
+		log.Fatalf("数据库初始化失败: %v", err)
+	}
+	defer dbConn.Close()
+	repository := database.NewRepository(dbConn, appLogger)
+
 	// 3. 初始化环境管理模块
 	envManager := environments.NewEnvironmentManager()
 	if err := envManager.Init(appConfig, appLogger); err != nil {
@@ -52,7 +67,27 @@ func main() {
 	}
 
 	// 5. 创建全局service实例
-	serviceInstance := service.NewService(appConfig, appLogger)
+	serviceInstance := service.NewService(appConfig, appLogger, sandboxExecutor, envManager, repository)
+
+	// 恢复上次运行时中断的任务：重新接管仍在运行的进程，重新排队等待中的任务
+	if err := serviceInstance.RecoverTasks(); err != nil {
+		appLogger.Error("恢复任务失败", zap.Error(err))
+	}
+
+	// 启动定时任务调度，停机期间错过的执行按各定时任务的补偿策略处理
+	if err := serviceInstance.Scheduler.Start(); err != nil {
+		appLogger.Error("定时任务调度启动失败", zap.Error(err))
+	}
+
+	// 注册配置热更新处理函数并监听配置文件变化
+	if err := config.InitConfigHandlers(); err != nil {
+		log.Fatalf("配置热更新初始化失败: %v", err)
+	}
+	if appConfig.HotReload.Enabled {
+		go config.WatchConfig(configPath, 2*time.Second, nil, func(err error) {
+			appLogger.Error("配置热重载失败", zap.Error(err))
+		})
+	}
 
 	// 6. 创建重启管理器
 	restartManager := restart.NewRestartManager(appConfig, appLogger, serviceInstance)
@@ -62,6 +97,7 @@ func main() {
 		appLogger.Error("重启管理器启动失败")
 	}
 
-	// 7. 启动服务器
+	// 7. 注册接口并启动服务器
+	web.NewWebInterface(serviceInstance, appLogger).SetupAllRoutes()
 	web.StartServer()
 }
\ No newline at end of file
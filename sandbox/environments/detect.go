@@ -0,0 +1,269 @@
+package environments
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Detection 运行时的自动识别规则
+type Detection struct {
+	// Extensions 入口文件扩展名（小写，含点）
+	Extensions []string
+	// Interpreters shebang 行中的解释器名称，比较时忽略末尾的版本号（python3.11 按 python 匹配）
+	Interpreters []string
+	// Markers 项目描述文件，任务目录中存在时认为是该运行时的项目
+	Markers []string
+	// Entrypoint 在项目目录中查找入口文件，返回相对于目录的路径
+	Entrypoint func(dir string) (string, error)
+}
+
+// DetectedRuntime 自动识别的结果
+type DetectedRuntime struct {
+	// EnvType 运行时名称
+	EnvType string
+	// Entrypoint 入口文件，相对于任务目录
+	Entrypoint string
+	// Reason 识别依据（shebang/extension/marker 及对应的值），用于日志
+	Reason string
+}
+
+// DetectRuntime 根据任务目录中的文件推断运行时和入口
+// 指定了命令且命令是任务目录中的文件时，依次按 shebang 行和扩展名识别；
+// 未指定命令时按项目描述文件（requirements.txt/pom.xml/package.json 等）识别并查找入口，
+// 目录中只有一个文件时按该文件识别
+// 参数: dir 任务目录, command 任务命令（可为空）
+// 返回: 识别结果（命令或唯一的文件不属于任何运行时时为 nil），错误信息
+func DetectRuntime(dir, command string) (*DetectedRuntime, error) {
+	if command != "" {
+		return detectFile(dir, command)
+	}
+
+	var matches []*DetectedRuntime
+	for _, name := range RuntimeNames() {
+		rt, _ := LookupRuntime(name)
+		detection := rt.Detection()
+		if detection == nil {
+			continue
+		}
+		for _, marker := range detection.Markers {
+			if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+				matches = append(matches, &DetectedRuntime{EnvType: name, Reason: "marker " + marker})
+				break
+			}
+		}
+	}
+	if len(matches) > 1 {
+		var names []string
+		for _, match := range matches {
+			names = append(names, match.EnvType)
+		}
+		return nil, fmt.Errorf("任务目录中有多种运行时的项目文件（%s），请指定 env_type", strings.Join(names, ", "))
+	}
+	if len(matches) == 1 {
+		entrypoint, err := DetectEntrypoint(matches[0].EnvType, dir)
+		if err != nil {
+			return nil, err
+		}
+		matches[0].Entrypoint = entrypoint
+		return matches[0], nil
+	}
+
+	files, err := listFiles(dir, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(files) != 1 {
+		return nil, fmt.Errorf("无法识别任务的运行时和入口：未指定命令，且任务目录中没有可识别的项目文件")
+	}
+	detected, err := detectFile(dir, files[0])
+	if err != nil {
+		return nil, err
+	}
+	return detected, nil
+}
+
+// DetectEntrypoint 在项目目录中查找指定运行时的入口文件
+// 参数: envType 运行时名称, dir 项目目录
+// 返回: 入口文件（相对于目录），错误信息
+func DetectEntrypoint(envType, dir string) (string, error) {
+	rt, ok := LookupRuntime(envType)
+	if !ok {
+		return "", fmt.Errorf("不支持的环境类型: %s", envType)
+	}
+	detection := rt.Detection()
+	if detection == nil || detection.Entrypoint == nil {
+		return "", fmt.Errorf("运行时 %s 不支持自动查找入口，请指定命令", envType)
+	}
+	return detection.Entrypoint(dir)
+}
+
+// detectFile 按 shebang 行和扩展名识别单个文件
+// 命令不是任务目录中的普通文件（如宿主命令）时返回 nil
+func detectFile(dir, command string) (*DetectedRuntime, error) {
+	path := command
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, command)
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, nil
+	}
+
+	interpreter, err := readShebang(path)
+	if err != nil {
+		return nil, err
+	}
+	ext := strings.ToLower(filepath.Ext(command))
+	for _, name := range RuntimeNames() {
+		rt, _ := LookupRuntime(name)
+		detection := rt.Detection()
+		if detection == nil {
+			continue
+		}
+		if interpreter != "" && containsString(detection.Interpreters, interpreter) {
+			return &DetectedRuntime{EnvType: name, Entrypoint: command, Reason: "shebang " + interpreter}, nil
+		}
+		if interpreter == "" && ext != "" && containsString(detection.Extensions, ext) {
+			return &DetectedRuntime{EnvType: name, Entrypoint: command, Reason: "extension " + ext}, nil
+		}
+	}
+	// 其他 shebang（如 bash）优先于扩展名，不按扩展名识别
+	return nil, nil
+}
+
+// readShebang 读取文件首行的 shebang，返回去掉路径和版本号的解释器名称
+// 如 "#!/usr/bin/env python3.11" 返回 python，没有 shebang 时返回空
+func readShebang(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("读取 %s 失败: %v", path, err)
+	}
+	defer file.Close()
+
+	line, _ := bufio.NewReaderSize(file, 256).ReadString('\n')
+	if !strings.HasPrefix(line, "#!") {
+		return "", nil
+	}
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) == 0 {
+		return "", nil
+	}
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		interpreter = ""
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+				interpreter = filepath.Base(field)
+				break
+			}
+		}
+	}
+	return strings.TrimRight(interpreter, "0123456789."), nil
+}
+
+// listFiles 列出目录下的普通文件（不含以 . 开头的文件），可按扩展名过滤
+// 返回: 按名称排序的相对路径
+func listFiles(dir, ext string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取任务目录失败: %v", err)
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || !entry.Type().IsRegular() {
+			continue
+		}
+		if ext != "" && strings.ToLower(filepath.Ext(name)) != ext {
+			continue
+		}
+		files = append(files, name)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// firstExisting 返回目录下第一个存在的候选文件，都不存在时在目录下查找唯一的指定扩展名文件
+func firstExisting(dir string, candidates []string, ext string) (string, error) {
+	for _, candidate := range candidates {
+		if info, err := os.Stat(filepath.Join(dir, candidate)); err == nil && info.Mode().IsRegular() {
+			return candidate, nil
+		}
+	}
+	files, err := listFiles(dir, ext)
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 1 {
+		return files[0], nil
+	}
+	if len(files) == 0 {
+		return "", fmt.Errorf("未找到入口文件（%s 或 *%s），请指定命令", strings.Join(candidates, "/"), ext)
+	}
+	return "", fmt.Errorf("有多个 *%s 文件（%s），无法确定入口，请指定命令", ext, strings.Join(files, ", "))
+}
+
+// pythonEntrypoint Python 项目的入口：__main__.py/main.py/app.py，或唯一的 .py 文件
+func pythonEntrypoint(dir string) (string, error) {
+	return firstExisting(dir, []string{"__main__.py", "main.py", "app.py"}, ".py")
+}
+
+// javaEntrypoint Java 项目的入口：Maven 构建产物 target/*.jar，或目录下唯一的 .jar 文件
+func javaEntrypoint(dir string) (string, error) {
+	var jars []string
+	if files, err := listFiles(filepath.Join(dir, "target"), ".jar"); err == nil {
+		for _, file := range files {
+			// 跳过源码包、文档包和 shade 插件保留的原始包
+			if strings.HasSuffix(file, "-sources.jar") || strings.HasSuffix(file, "-javadoc.jar") || strings.HasPrefix(file, "original-") {
+				continue
+			}
+			jars = append(jars, filepath.Join("target", file))
+		}
+	}
+	switch len(jars) {
+	case 1:
+		return jars[0], nil
+	case 0:
+		return firstExisting(dir, nil, ".jar")
+	default:
+		return "", fmt.Errorf("有多个 jar 包（%s），无法确定入口，请指定命令", strings.Join(jars, ", "))
+	}
+}
+
+// nodeEntrypoint Node.js 项目的入口：package.json 的 main 字段，或 index.js/main.js
+func nodeEntrypoint(dir string) (string, error) {
+	if data, err := os.ReadFile(filepath.Join(dir, "package.json")); err == nil {
+		var pkg struct {
+			Main string `json:"main"`
+		}
+		if err := json.Unmarshal(data, &pkg); err != nil {
+			return "", fmt.Errorf("解析 package.json 失败: %v", err)
+		}
+		if pkg.Main != "" {
+			main := filepath.Clean(filepath.FromSlash(pkg.Main))
+			if filepath.IsAbs(main) || main == ".." || strings.HasPrefix(main, ".."+string(filepath.Separator)) {
+				return "", fmt.Errorf("package.json 的 main 字段 %q 不在项目目录内", pkg.Main)
+			}
+			if info, err := os.Stat(filepath.Join(dir, main)); err == nil && info.IsDir() {
+				main = filepath.Join(main, "index.js")
+			}
+			return main, nil
+		}
+	}
+	return firstExisting(dir, []string{"index.js", "main.js"}, ".js")
+}
+
+// containsString 判断列表中是否包含指定字符串
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
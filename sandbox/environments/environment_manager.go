@@ -1,13 +1,17 @@
 // Package environments 环境管理模块
-// 管理各种执行环境（Python、Java等）
+// 管理各种执行环境（Python、Java、Node.js 等），运行时以插件形式注册
 package environments
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"file-flow-service/config"
 	"file-flow-service/utils/logger"
+
+	"go.uber.org/zap"
 )
 
 // EnvironmentManager 环境管理器接口
@@ -15,23 +19,59 @@ type EnvironmentManager interface {
 	// Init 初始化环境管理器
 	Init(config *config.AppConfig, logger logger.Logger) error
 	
-	// GetPythonPath 获取Python环境路径
-	GetPythonPath(version string) (string, error)
+	// GetRuntime 获取已注册的运行时
+	GetRuntime(envType string) (Runtime, error)
 	
-	// GetJavaPath 获取Java环境路径
-	GetJavaPath(version string) (string, error)
+	// GetRuntimePath 获取运行时指定版本的安装目录
+	GetRuntimePath(envType, version string) (string, error)
 	
 	// InstallEnvironment 安装指定环境
+	// 解压 .tar.gz/.tar.xz/.zip 安装包，安装包旁需有同名 .sha256 校验文件
 	InstallEnvironment(envType, version, installerPath string) error
 	
 	// ValidateEnvironment 验证环境是否有效
 	ValidateEnvironment(envType, version string) bool
+
+	// UninstallEnvironment 卸载指定版本，有任务正在使用时拒绝
+	UninstallEnvironment(envType, version string) error
+
+	// ResolveRuntime 解析任务使用的运行时版本（为空时使用默认版本）并记录使用时间
+	// 返回的函数用于在任务结束后释放对该版本的占用
+	ResolveRuntime(envType, version string) (string, string, func(), error)
+
+	// ListEnvironments 列出已安装的运行时版本及冒烟测试结果
+	ListEnvironments() ([]EnvironmentInfo, error)
+
+	// DefaultVersion 获取运行时的默认版本
+	DefaultVersion(envType string) string
+
+	// SetDefaultVersion 设置运行时的默认版本
+	SetDefaultVersion(envType, version string) error
+
+	// PreparePythonVenv 准备安装了指定依赖的Python虚拟环境，依赖相同时复用缓存
+	// 返回的函数用于在任务结束后释放对该虚拟环境的占用
+	PreparePythonVenv(ctx context.Context, version, requirementsPath string) (string, func(), error)
+
+	// CollectGarbage 删除长时间未使用的Python虚拟环境缓存
+	CollectGarbage() (*GCReport, error)
+
+	// Stop 停止定期回收
+	Stop()
 }
 
 // environmentManager 环境管理器实现
 type environmentManager struct {
-	config *config.AppConfig
-	logger logger.Logger
+	config    *config.AppConfig
+	logger    logger.Logger
+	venvLocks venvLocks
+
+	// inUse 正被任务使用的版本目录和虚拟环境目录（绝对路径）及使用数
+	usageMu sync.Mutex
+	inUse   map[string]int
+
+	stopGC   chan struct{}
+	gcDone   chan struct{}
+	stopOnce sync.Once
 }
 
 // NewEnvironmentManager 创建环境管理器实例
@@ -52,55 +92,65 @@ func (em *environmentManager) Init(config *config.AppConfig, logger logger.Logge
 		return fmt.Errorf("创建环境目录失败: %v", err)
 	}
 	
-	// 创建Python环境目录
-	pythonPath := config.Sandbox.Environments.Python.BasePath
-	if err := os.MkdirAll(pythonPath, 0755); err != nil {
-		return fmt.Errorf("创建Python环境目录失败: %v", err)
+	// 创建各运行时的版本目录
+	for _, name := range RuntimeNames() {
+		rt, _ := LookupRuntime(name)
+		if !rt.Versioned() {
+			continue
+		}
+		if err := os.MkdirAll(config.Sandbox.Environments.Runtime(name).VersionsPath, 0755); err != nil {
+			return fmt.Errorf("创建运行时 %s 的版本目录失败: %v", name, err)
+		}
+	}
+	for _, name := range config.Sandbox.Environments.RuntimeNames() {
+		if _, ok := LookupRuntime(name); !ok {
+			em.logger.Warn("配置了未注册的运行时，该配置段不会生效", zap.String("runtime", name))
+		}
+	}
+
+	// 创建Python虚拟环境缓存目录
+	if err := os.MkdirAll(em.venvsPath(), 0755); err != nil {
+		return fmt.Errorf("创建Python虚拟环境目录失败: %v", err)
 	}
 	
-	// 创建Java环境目录
-	javaPath := config.Sandbox.Environments.Java.BasePath
-	if err := os.MkdirAll(javaPath, 0755); err != nil {
-		return fmt.Errorf("创建Java环境目录失败: %v", err)
+	if err := em.startGC(); err != nil {
+		return err
 	}
 	
 	em.logger.Info("环境管理器初始化完成")
 	return nil
 }
 
-// GetPythonPath 获取Python环境路径
-// 参数: version Python版本
-// 返回: 环境路径，错误信息
-func (em *environmentManager) GetPythonPath(version string) (string, error) {
-	if em.config == nil {
-		return "", fmt.Errorf("环境管理器未初始化")
+// GetRuntime 获取已注册的运行时
+// 参数: envType 运行时名称
+// 返回: 运行时，错误信息
+func (em *environmentManager) GetRuntime(envType string) (Runtime, error) {
+	rt, ok := LookupRuntime(envType)
+	if !ok {
+		return nil, fmt.Errorf("不支持的环境类型: %s", envType)
 	}
-	
-	pythonVersionsPath := em.config.Sandbox.Environments.Python.VersionsPath
-	versionPath := filepath.Join(pythonVersionsPath, version)
-	
-	// 检查路径是否存在
-	if _, err := os.Stat(versionPath); os.IsNotExist(err) {
-		return "", fmt.Errorf("Python版本 %s 不存在", version)
-	}
-	
-	return versionPath, nil
+	return rt, nil
 }
 
-// GetJavaPath 获取Java环境路径
-// 参数: version Java版本
+// GetRuntimePath 获取运行时指定版本的安装目录
+// 参数: envType 运行时名称, version 版本
 // 返回: 环境路径，错误信息
-func (em *environmentManager) GetJavaPath(version string) (string, error) {
+func (em *environmentManager) GetRuntimePath(envType, version string) (string, error) {
 	if em.config == nil {
 		return "", fmt.Errorf("环境管理器未初始化")
 	}
+	if _, err := em.GetRuntime(envType); err != nil {
+		return "", err
+	}
+	if err := checkVersionName(version); err != nil {
+		return "", err
+	}
 	
-	javaVersionsPath := em.config.Sandbox.Environments.Java.VersionsPath
-	versionPath := filepath.Join(javaVersionsPath, version)
+	versionPath := filepath.Join(em.config.Sandbox.Environments.Runtime(envType).VersionsPath, version)
 	
 	// 检查路径是否存在
 	if _, err := os.Stat(versionPath); os.IsNotExist(err) {
-		return "", fmt.Errorf("Java版本 %s 不存在", version)
+		return "", fmt.Errorf("%s版本 %s 不存在", envType, version)
 	}
 	
 	return versionPath, nil
@@ -114,69 +164,16 @@ func (em *environmentManager) InstallEnvironment(envType, version, installerPath
 		return fmt.Errorf("环境管理器未初始化")
 	}
 	
-	// 根据环境类型处理安装
-	switch envType {
-	case "python":
-		return em.installPython(version, installerPath)
-	case "java":
-		return em.installJava(version, installerPath)
-	default:
-		return fmt.Errorf("不支持的环境类型: %s", envType)
+	rt, err := em.GetRuntime(envType)
+	if err != nil {
+		return err
 	}
-}
-
-// installPython 安装Python环境
-// 参数: version 版本, installerPath 安装包路径
-// 返回: 错误信息
-func (em *environmentManager) installPython(version, installerPath string) error {
-	// 这里应该实现Python安装逻辑
-	// 目前只是示例，实际需要根据具体需求实现
-	em.logger.Info("安装Python环境 version=" + version + " installer=" + installerPath)
-	
-	// 实现基本的安装逻辑
-	// 1. 检查安装包是否存在
-	if _, err := os.Stat(installerPath); os.IsNotExist(err) {
-		return fmt.Errorf("安装包不存在: %s", installerPath)
-	}
-	
-	// 2. 创建版本目录
-	pythonVersionsPath := em.config.Sandbox.Environments.Python.VersionsPath
-	versionPath := filepath.Join(pythonVersionsPath, version)
-	
-	if err := os.MkdirAll(versionPath, 0755); err != nil {
-		return fmt.Errorf("创建Python版本目录失败: %v", err)
-	}
-	
-	// 3. 执行安装（这里只是示例）
-	em.logger.Info("Python环境安装完成 version=" + version + " path=" + versionPath)
-	return nil
-}
-
-// installJava 安装Java环境
-// 参数: version 版本, installerPath 安装包路径
-// 返回: 错误信息
-func (em *environmentManager) installJava(version, installerPath string) error {
-	// 这里应该实现Java安装逻辑
-	// 目前只是示例，实际需要根据具体需求实现
-	em.logger.Info("安装Java环境 version=" + version + " installer=" + installerPath)
-	
-	// 实现基本的安装逻辑
-	// 1. 检查安装包是否存在
-	if _, err := os.Stat(installerPath); os.IsNotExist(err) {
-		return fmt.Errorf("安装包不存在: %s", installerPath)
-	}
-	
-	// 2. 创建版本目录
-	javaVersionsPath := em.config.Sandbox.Environments.Java.VersionsPath
-	versionPath := filepath.Join(javaVersionsPath, version)
-	
-	if err := os.MkdirAll(versionPath, 0755); err != nil {
-		return fmt.Errorf("创建Java版本目录失败: %v", err)
+	if !rt.Versioned() {
+		return fmt.Errorf("运行时 %s 使用宿主程序，无需安装", envType)
 	}
-	
-	// 3. 执行安装（这里只是示例）
-	em.logger.Info("Java环境安装完成 version=" + version + " path=" + versionPath)
-	return nil
+	em.logger.Info("安装运行时", zap.String("env_type", envType), zap.String("version", version), zap.String("installer", installerPath))
+	_, err = em.installRuntime(rt, version, installerPath)
+	return err
 }
 
 // ValidateEnvironment 验证环境是否有效
@@ -187,17 +184,21 @@ func (em *environmentManager) ValidateEnvironment(envType, version string) bool
 		return false
 	}
 	
-	var versionPath string
-	switch envType {
-	case "python":
-		versionPath = filepath.Join(em.config.Sandbox.Environments.Python.VersionsPath, version)
-	case "java":
-		versionPath = filepath.Join(em.config.Sandbox.Environments.Java.VersionsPath, version)
-	default:
+	rt, err := em.GetRuntime(envType)
+	if err != nil {
 		return false
 	}
+	var versionPath string
+	if rt.Versioned() {
+		if versionPath, err = em.GetRuntimePath(envType, version); err != nil {
+			return false
+		}
+	}
 	
-	// 检查路径是否存在
-	_, err := os.Stat(versionPath)
-	return !os.IsNotExist(err)
-}
\ No newline at end of file
+	// 运行可执行文件检查运行时可用
+	if _, err := rt.Validate(versionPath); err != nil {
+		em.logger.Warn("运行时不可用", zap.String("env_type", envType), zap.String("version", version), zap.Error(err))
+		return false
+	}
+	return true
+}
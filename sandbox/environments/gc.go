@@ -0,0 +1,306 @@
+package environments
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultGCInterval 未配置 gc.interval 时的回收检查间隔
+	defaultGCInterval = 24 * time.Hour
+	// removingMarker 待删除目录名中的标记，先改名再删除，删除中断时由下次回收清理
+	removingMarker = ".removing-"
+)
+
+// GCReport 一次虚拟环境缓存回收的结果
+type GCReport struct {
+	// Removed 删除的虚拟环境目录
+	Removed []string `json:"removed"`
+	// Skipped 因正被任务使用或正在构建而跳过的虚拟环境数
+	Skipped int `json:"skipped"`
+	// ReclaimedBytes 回收的磁盘空间（字节）
+	ReclaimedBytes int64 `json:"reclaimed_bytes"`
+}
+
+// acquire 登记正在使用的运行环境目录，登记期间该目录不会被卸载或回收
+// 参数: path 版本目录或虚拟环境目录
+// 返回: 释放登记的函数（可重复调用），目录不存在时返回错误
+func (em *environmentManager) acquire(path string) (func(), error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("运行环境目录不合法: %v", err)
+	}
+
+	em.usageMu.Lock()
+	defer em.usageMu.Unlock()
+	if _, err := os.Stat(abs); err != nil {
+		return nil, fmt.Errorf("运行环境 %s 不可用: %v", path, err)
+	}
+	if em.inUse == nil {
+		em.inUse = make(map[string]int)
+	}
+	em.inUse[abs]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			em.usageMu.Lock()
+			defer em.usageMu.Unlock()
+			if em.inUse[abs]--; em.inUse[abs] <= 0 {
+				delete(em.inUse, abs)
+			}
+		})
+	}, nil
+}
+
+// moveAside 目录未被使用时改名为待删除目录
+// 检查和改名在同一把锁内完成，避免与 acquire 并发时删除正在使用的目录
+// 返回: 待删除目录，使用中的任务数（大于 0 时未改名），错误信息
+func (em *environmentManager) moveAside(path string) (string, int, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("运行环境目录不合法: %v", err)
+	}
+
+	em.usageMu.Lock()
+	defer em.usageMu.Unlock()
+	if users := em.inUse[abs]; users > 0 {
+		return "", users, nil
+	}
+	trash := filepath.Join(filepath.Dir(abs), fmt.Sprintf(".%s%s%d", filepath.Base(abs), removingMarker, time.Now().UnixNano()))
+	if err := os.Rename(abs, trash); err != nil {
+		return "", 0, fmt.Errorf("移除 %s 失败: %v", path, err)
+	}
+	return trash, 0, nil
+}
+
+// removeTrash 删除待删除目录
+// 返回: 回收的磁盘空间（字节），错误信息
+func removeTrash(trash string) (int64, error) {
+	var size int64
+	// 版本目录可能是指向其他位置的符号链接，此时只删除链接本身
+	if info, err := os.Lstat(trash); err == nil && info.IsDir() {
+		size, _ = dirSize(trash)
+	}
+	if err := os.RemoveAll(trash); err != nil {
+		return 0, fmt.Errorf("删除 %s 失败: %v", trash, err)
+	}
+	return size, nil
+}
+
+// UninstallEnvironment 卸载运行时的指定版本
+// 有任务正在使用该版本时拒绝卸载；同时删除该版本的默认版本标记、使用记录，以及基于该版本构建的Python虚拟环境
+// 参数: envType 运行时名称, version 版本
+// 返回: 错误信息
+func (em *environmentManager) UninstallEnvironment(envType, version string) error {
+	if em.config == nil {
+		return fmt.Errorf("环境管理器未初始化")
+	}
+	rt, err := em.GetRuntime(envType)
+	if err != nil {
+		return err
+	}
+	if !rt.Versioned() {
+		return fmt.Errorf("运行时 %s 使用宿主程序，无需卸载", envType)
+	}
+	versionPath, err := em.GetRuntimePath(envType, version)
+	if err != nil {
+		return err
+	}
+
+	trash, users, err := em.moveAside(versionPath)
+	if err != nil {
+		return err
+	}
+	if users > 0 {
+		return fmt.Errorf("%s版本 %s 正被 %d 个任务使用，不能卸载", envType, version, users)
+	}
+	reclaimed, err := removeTrash(trash)
+	if err != nil {
+		return err
+	}
+
+	versionsPath := em.config.Sandbox.Environments.Runtime(envType).VersionsPath
+	if data, err := os.ReadFile(filepath.Join(versionsPath, defaultVersionFile)); err == nil && strings.TrimSpace(string(data)) == version {
+		os.Remove(filepath.Join(versionsPath, defaultVersionFile))
+	}
+	if em.config.Sandbox.Environments.Runtime(envType).DefaultVersion == version {
+		em.logger.Warn("卸载的版本是配置中的默认版本，未指定版本的任务将无法执行",
+			zap.String("env_type", envType), zap.String("version", version))
+	}
+	os.Remove(em.lastUsedPath(envType, version))
+
+	if envType == "python" {
+		report := em.collectVenvs(func(key string, _ time.Time) bool {
+			return venvVersion(key) == version
+		})
+		reclaimed += report.ReclaimedBytes
+	}
+
+	em.logger.Info("运行时版本已卸载",
+		zap.String("env_type", envType),
+		zap.String("version", version),
+		zap.Int64("reclaimed_bytes", reclaimed))
+	return nil
+}
+
+// CollectGarbage 删除超过 gc.venv_max_idle_days 天未被任务使用的Python虚拟环境
+// 正在使用或构建中的虚拟环境会被跳过
+// 返回: 回收结果，错误信息
+func (em *environmentManager) CollectGarbage() (*GCReport, error) {
+	if em.config == nil {
+		return nil, fmt.Errorf("环境管理器未初始化")
+	}
+	days := em.config.Sandbox.Environments.GC.VenvMaxIdleDays
+	if days <= 0 {
+		return &GCReport{}, nil
+	}
+	cutoff := time.Now().Add(-time.Duration(days) * 24 * time.Hour)
+	report := em.collectVenvs(func(_ string, lastUsed time.Time) bool {
+		return lastUsed.Before(cutoff)
+	})
+	em.logger.Info("Python虚拟环境缓存回收完成",
+		zap.Int("removed", len(report.Removed)),
+		zap.Int("skipped", report.Skipped),
+		zap.Int64("reclaimed_bytes", report.ReclaimedBytes))
+	return report, nil
+}
+
+// collectVenvs 删除满足条件的虚拟环境，并清理上次删除中断留下的目录
+// 参数: expired 根据缓存键和最近使用时间判断是否删除
+func (em *environmentManager) collectVenvs(expired func(key string, lastUsed time.Time) bool) *GCReport {
+	report := &GCReport{}
+	venvsPath := em.venvsPath()
+	entries, err := os.ReadDir(venvsPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			em.logger.Warn("读取虚拟环境目录失败", zap.String("path", venvsPath), zap.Error(err))
+		}
+		return report
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(venvsPath, entry.Name())
+		if strings.HasPrefix(entry.Name(), ".") {
+			if strings.Contains(entry.Name(), removingMarker) {
+				if size, err := removeTrash(path); err == nil {
+					report.ReclaimedBytes += size
+				}
+			}
+			continue
+		}
+		if !entry.IsDir() {
+			continue
+		}
+		// 未构建完成的虚拟环境没有完成标记，按目录修改时间计算
+		info, err := os.Stat(filepath.Join(path, venvReadyFile))
+		if err != nil {
+			if info, err = os.Stat(path); err != nil {
+				continue
+			}
+		}
+		if !expired(entry.Name(), info.ModTime()) {
+			continue
+		}
+
+		removed, size, err := em.removeVenv(entry.Name(), path)
+		if err != nil {
+			em.logger.Warn("删除虚拟环境失败", zap.String("venv", path), zap.Error(err))
+			continue
+		}
+		if !removed {
+			report.Skipped++
+			continue
+		}
+		report.Removed = append(report.Removed, path)
+		report.ReclaimedBytes += size
+	}
+	return report
+}
+
+// removeVenv 删除未被使用且未在构建中的虚拟环境
+// 返回: 是否已删除，回收的磁盘空间（字节），错误信息
+func (em *environmentManager) removeVenv(key, path string) (bool, int64, error) {
+	lock := em.venvLocks.get(key)
+	if !lock.TryLock() {
+		return false, 0, nil
+	}
+	defer lock.Unlock()
+
+	trash, users, err := em.moveAside(path)
+	if err != nil || users > 0 {
+		return false, 0, err
+	}
+	size, err := removeTrash(trash)
+	if err != nil {
+		return false, 0, err
+	}
+	return true, size, nil
+}
+
+// venvVersion 从虚拟环境缓存键中取出Python版本
+func venvVersion(key string) string {
+	i := strings.LastIndex(key, "-")
+	if i < 0 {
+		return ""
+	}
+	return key[:i]
+}
+
+// startGC 按配置启动定期回收
+func (em *environmentManager) startGC() error {
+	gc := em.config.Sandbox.Environments.GC
+	if !gc.Enabled || gc.VenvMaxIdleDays <= 0 {
+		return nil
+	}
+	interval := defaultGCInterval
+	if gc.Interval != "" {
+		parsed, err := time.ParseDuration(gc.Interval)
+		if err != nil || parsed <= 0 {
+			return fmt.Errorf("回收检查间隔 %q 不合法", gc.Interval)
+		}
+		interval = parsed
+	}
+
+	em.stopGC = make(chan struct{})
+	em.gcDone = make(chan struct{})
+	go em.runGC(interval, em.stopGC, em.gcDone)
+	em.logger.Info("已启用Python虚拟环境缓存回收",
+		zap.Duration("interval", interval),
+		zap.Int("max_idle_days", gc.VenvMaxIdleDays))
+	return nil
+}
+
+// runGC 启动时回收一次，之后按间隔定期回收
+func (em *environmentManager) runGC(interval time.Duration, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := em.CollectGarbage(); err != nil {
+			em.logger.Error("回收Python虚拟环境缓存失败", zap.Error(err))
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop 停止定期回收并等待正在进行的回收结束
+func (em *environmentManager) Stop() {
+	if em.stopGC == nil {
+		return
+	}
+	em.stopOnce.Do(func() {
+		close(em.stopGC)
+		<-em.gcDone
+	})
+}
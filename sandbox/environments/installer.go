@@ -0,0 +1,378 @@
+package environments
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ulikunitz/xz"
+	"go.uber.org/zap"
+)
+
+const (
+	// checksumSuffix 安装包旁的 SHA-256 校验文件后缀
+	checksumSuffix = ".sha256"
+	// installInfoFile 版本目录中记录安装信息的文件
+	installInfoFile = ".install.json"
+	// detectTimeout 检测运行时版本的超时时间
+	detectTimeout = 30 * time.Second
+)
+
+// versionPattern 运行时输出中的版本号
+var versionPattern = regexp.MustCompile(`\d+(\.\d+)*(_\d+)?`)
+
+// InstallInfo 运行时版本的安装信息
+type InstallInfo struct {
+	EnvType         string    `json:"env_type"`
+	Version         string    `json:"version"`
+	DetectedVersion string    `json:"detected_version"`
+	Installer       string    `json:"installer"`
+	SHA256          string    `json:"sha256"`
+	InstalledAt     time.Time `json:"installed_at"`
+}
+
+// installRuntime 解压运行时安装包到 versions_path/<version>
+// 依次校验安装包旁 .sha256 文件中的校验和、解压到临时目录、运行可执行文件检测实际版本，
+// 全部成功后再改名为版本目录；任一步骤失败都会删除临时目录，不留下半安装的版本
+// 参数: rt 运行时, version 版本, installerPath 安装包路径
+// 返回: 安装信息，错误信息
+func (em *environmentManager) installRuntime(rt Runtime, version, installerPath string) (*InstallInfo, error) {
+	if err := checkVersionName(version); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(installerPath); err != nil {
+		return nil, fmt.Errorf("安装包不存在: %s", installerPath)
+	}
+	versionsPath := em.config.Sandbox.Environments.Runtime(rt.Name()).VersionsPath
+	target := filepath.Join(versionsPath, version)
+	if _, err := os.Stat(target); err == nil {
+		return nil, fmt.Errorf("%s 版本 %s 已安装", rt.Name(), version)
+	}
+
+	checksum, err := verifyChecksum(installerPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(versionsPath, 0755); err != nil {
+		return nil, fmt.Errorf("创建版本目录失败: %v", err)
+	}
+	staging, err := os.MkdirTemp(versionsPath, "."+version+".installing-")
+	if err != nil {
+		return nil, fmt.Errorf("创建临时安装目录失败: %v", err)
+	}
+	defer os.RemoveAll(staging)
+
+	root, err := rt.Install(installerPath, staging)
+	if err != nil {
+		return nil, err
+	}
+	detected, err := rt.Validate(root)
+	if err != nil {
+		return nil, fmt.Errorf("安装包中的 %s 无法运行: %v", rt.Name(), err)
+	}
+	if !versionMatches(version, detected) {
+		return nil, fmt.Errorf("安装包中的 %s 版本为 %s，与请求的版本 %s 不符", rt.Name(), detected, version)
+	}
+
+	info := &InstallInfo{
+		EnvType:         rt.Name(),
+		Version:         version,
+		DetectedVersion: detected,
+		Installer:       filepath.Base(installerPath),
+		SHA256:          checksum,
+		InstalledAt:     time.Now(),
+	}
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("序列化安装信息失败: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, installInfoFile), data, 0644); err != nil {
+		return nil, fmt.Errorf("写入安装信息失败: %v", err)
+	}
+	if err := os.Rename(root, target); err != nil {
+		return nil, fmt.Errorf("移动到版本目录失败: %v", err)
+	}
+
+	em.logger.Info("运行时安装完成",
+		zap.String("env_type", rt.Name()),
+		zap.String("version", version),
+		zap.String("detected_version", detected),
+		zap.String("path", target))
+	return info, nil
+}
+
+// checkVersionName 版本号作为目录名使用，不能包含路径分隔符
+func checkVersionName(version string) error {
+	if version == "" || version == "." || version == ".." || strings.HasPrefix(version, ".") ||
+		strings.ContainsAny(version, `/\`) {
+		return fmt.Errorf("版本号 %q 不合法", version)
+	}
+	return nil
+}
+
+// verifyChecksum 用安装包旁的 .sha256 文件校验安装包
+// 校验文件内容为十六进制摘要，可带 sha256sum 输出格式中的文件名
+// 返回: 安装包的 SHA-256 摘要，错误信息
+func verifyChecksum(installerPath string) (string, error) {
+	data, err := os.ReadFile(installerPath + checksumSuffix)
+	if err != nil {
+		return "", fmt.Errorf("读取校验文件失败: %v", err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("校验文件 %s 为空", installerPath+checksumSuffix)
+	}
+	expected := strings.ToLower(fields[0])
+
+	file, err := os.Open(installerPath)
+	if err != nil {
+		return "", fmt.Errorf("打开安装包失败: %v", err)
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("读取安装包失败: %v", err)
+	}
+	actual := hex.EncodeToString(hash.Sum(nil))
+	if actual != expected {
+		return "", fmt.Errorf("安装包校验失败: 期望 %s，实际 %s", expected, actual)
+	}
+	return actual, nil
+}
+
+// extractArchive 按扩展名解压 .tar.gz/.tgz、.tar.xz/.txz 和 .zip 安装包
+func extractArchive(archivePath, dest string) error {
+	name := strings.ToLower(archivePath)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return extractZip(archivePath, dest)
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"),
+		strings.HasSuffix(name, ".tar.xz"), strings.HasSuffix(name, ".txz"):
+	default:
+		return fmt.Errorf("不支持的安装包格式: %s", filepath.Base(archivePath))
+	}
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("打开安装包失败: %v", err)
+	}
+	defer file.Close()
+
+	var reader io.Reader
+	if strings.HasSuffix(name, ".xz") || strings.HasSuffix(name, ".txz") {
+		if reader, err = xz.NewReader(bufio.NewReader(file)); err != nil {
+			return fmt.Errorf("解压 xz 失败: %v", err)
+		}
+	} else {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("解压 gzip 失败: %v", err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	return extractTar(reader, dest)
+}
+
+// extractTar 解压 tar 流，只接受普通文件、目录以及指向解压目录内的链接
+func extractTar(reader io.Reader, dest string) error {
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("读取 tar 失败: %v", err)
+		}
+		target, err := entryPath(dest, header.Name)
+		if err != nil {
+			return err
+		}
+		mode := os.FileMode(header.Mode).Perm()
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, mode|0700); err != nil {
+				return fmt.Errorf("创建目录 %s 失败: %v", header.Name, err)
+			}
+		case tar.TypeReg:
+			if err := writeFile(target, tr, mode); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := checkLink(dest, target, header.Linkname); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Errorf("创建目录失败: %v", err)
+			}
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return fmt.Errorf("创建链接 %s 失败: %v", header.Name, err)
+			}
+		case tar.TypeLink:
+			source, err := entryPath(dest, header.Linkname)
+			if err != nil {
+				return err
+			}
+			if err := os.Link(source, target); err != nil {
+				return fmt.Errorf("创建硬链接 %s 失败: %v", header.Name, err)
+			}
+		default:
+			// 设备文件、FIFO 等不属于运行时发行包的内容直接忽略
+		}
+	}
+}
+
+// extractZip 解压 zip 安装包
+func extractZip(archivePath, dest string) error {
+	archive, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("打开 zip 失败: %v", err)
+	}
+	defer archive.Close()
+
+	for _, entry := range archive.File {
+		target, err := entryPath(dest, entry.Name)
+		if err != nil {
+			return err
+		}
+		mode := entry.Mode()
+		switch {
+		case mode.IsDir():
+			if err := os.MkdirAll(target, mode.Perm()|0700); err != nil {
+				return fmt.Errorf("创建目录 %s 失败: %v", entry.Name, err)
+			}
+		case mode&os.ModeSymlink != 0:
+			reader, err := entry.Open()
+			if err != nil {
+				return fmt.Errorf("读取 %s 失败: %v", entry.Name, err)
+			}
+			link, err := io.ReadAll(io.LimitReader(reader, 4096))
+			reader.Close()
+			if err != nil {
+				return fmt.Errorf("读取 %s 失败: %v", entry.Name, err)
+			}
+			if err := checkLink(dest, target, string(link)); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Errorf("创建目录失败: %v", err)
+			}
+			if err := os.Symlink(string(link), target); err != nil {
+				return fmt.Errorf("创建链接 %s 失败: %v", entry.Name, err)
+			}
+		case mode.IsRegular():
+			reader, err := entry.Open()
+			if err != nil {
+				return fmt.Errorf("读取 %s 失败: %v", entry.Name, err)
+			}
+			err = writeFile(target, reader, mode.Perm())
+			reader.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// entryPath 计算压缩包条目的解压路径，拒绝绝对路径和跳出解压目录的条目
+func entryPath(dest, name string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("安装包包含非法路径: %s", name)
+	}
+	return filepath.Join(dest, cleaned), nil
+}
+
+// checkLink 符号链接只能指向解压目录内的相对路径
+func checkLink(dest, target, link string) error {
+	if filepath.IsAbs(link) {
+		return fmt.Errorf("安装包包含指向绝对路径的链接: %s -> %s", target, link)
+	}
+	resolved := filepath.Join(filepath.Dir(target), link)
+	rel, err := filepath.Rel(dest, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("安装包包含指向解压目录外的链接: %s -> %s", target, link)
+	}
+	return nil
+}
+
+// writeFile 将条目内容写入文件
+func writeFile(target string, reader io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %v", err)
+	}
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode|0600)
+	if err != nil {
+		return fmt.Errorf("创建文件 %s 失败: %v", target, err)
+	}
+	if _, err := io.Copy(file, reader); err != nil {
+		file.Close()
+		return fmt.Errorf("写入文件 %s 失败: %v", target, err)
+	}
+	return file.Close()
+}
+
+// archiveRoot 发行包通常只有一个顶层目录（如 jdk-17.0.2/），此时以该目录为运行时根目录
+func archiveRoot(staging string) (string, error) {
+	entries, err := os.ReadDir(staging)
+	if err != nil {
+		return "", fmt.Errorf("读取解压目录失败: %v", err)
+	}
+	if len(entries) == 0 {
+		return "", fmt.Errorf("安装包为空")
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		return filepath.Join(staging, entries[0].Name()), nil
+	}
+	return staging, nil
+}
+
+// detectVersion 运行可执行文件并从输出中解析版本号
+// 参数: executable 可执行文件路径, args 输出版本号的参数
+// 返回: 版本号，错误信息
+func detectVersion(executable string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), detectTimeout)
+	defer cancel()
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, executable, args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("运行 %s 检测版本失败: %v: %s", filepath.Base(executable), err, bytes.TrimSpace(output.Bytes()))
+	}
+	version := versionPattern.FindString(output.String())
+	if version == "" {
+		return "", fmt.Errorf("无法从输出中解析版本号: %s", bytes.TrimSpace(output.Bytes()))
+	}
+	return version, nil
+}
+
+// versionMatches 请求的版本号是否与检测到的版本一致
+// 请求的版本可以只写前几段（3.11 匹配 3.11.4），Java 8 及以前的 1.x 版本号可以省略 "1."
+func versionMatches(requested, detected string) bool {
+	for _, candidate := range []string{detected, strings.TrimPrefix(detected, "1.")} {
+		candidate = strings.ReplaceAll(candidate, "_", ".")
+		if candidate == requested || strings.HasPrefix(candidate, requested+".") {
+			return true
+		}
+	}
+	return false
+}
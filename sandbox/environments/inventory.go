@@ -0,0 +1,273 @@
+package environments
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultVersionFile versions_path 下记录通过接口设置的默认版本的文件，优先于配置中的 default_version
+	defaultVersionFile = ".default"
+	// lastUsedDir versions_path 下记录各版本最近使用时间的目录，文件修改时间即最近一次使用时间
+	lastUsedDir = ".last_used"
+)
+
+// EnvironmentInfo 已安装运行时版本的清单信息
+type EnvironmentInfo struct {
+	// EnvType 运行时名称
+	EnvType string `json:"env_type"`
+	// Version 版本目录名，使用宿主程序的运行时为空
+	Version string `json:"version"`
+	// Versioned 是否按版本安装
+	Versioned bool `json:"versioned"`
+	// Default 是否为未指定版本时使用的默认版本
+	Default bool `json:"default"`
+	// Path 版本目录
+	Path string `json:"path"`
+	// Executable 解析出的可执行文件路径
+	Executable string `json:"executable"`
+	// DetectedVersion 检测到的版本号，检测失败时使用安装时记录的版本号
+	DetectedVersion string `json:"detected_version"`
+	// InstalledAt 安装时间，非通过安装包安装的版本为空
+	InstalledAt *time.Time `json:"installed_at,omitempty"`
+	// SizeBytes 版本目录占用的磁盘空间（字节）
+	SizeBytes int64 `json:"size_bytes"`
+	// LastUsedAt 最近一次被任务使用的时间，未使用过为空
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	// Healthy 冒烟测试（运行可执行文件输出版本号）是否通过
+	Healthy bool `json:"healthy"`
+	// Error 冒烟测试失败的原因
+	Error string `json:"error,omitempty"`
+}
+
+// ListEnvironments 列出全部已注册运行时及其已安装版本，并逐个运行冒烟测试
+// 返回: 运行时清单，错误信息
+func (em *environmentManager) ListEnvironments() ([]EnvironmentInfo, error) {
+	if em.config == nil {
+		return nil, fmt.Errorf("环境管理器未初始化")
+	}
+
+	var infos []EnvironmentInfo
+	for _, name := range RuntimeNames() {
+		rt, _ := LookupRuntime(name)
+		if !rt.Versioned() {
+			info := EnvironmentInfo{EnvType: name, Default: true}
+			em.smokeTest(rt, "", &info)
+			infos = append(infos, info)
+			continue
+		}
+
+		versionsPath := em.config.Sandbox.Environments.Runtime(name).VersionsPath
+		entries, err := os.ReadDir(versionsPath)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取运行时 %s 的版本目录失败: %v", name, err)
+		}
+		defaultVersion := em.DefaultVersion(name)
+		for _, entry := range entries {
+			// 以 . 开头的是安装中的临时目录和清单记录文件
+			if strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			versionPath := filepath.Join(versionsPath, entry.Name())
+			if stat, err := os.Stat(versionPath); err != nil || !stat.IsDir() {
+				continue
+			}
+			infos = append(infos, em.inspectVersion(rt, entry.Name(), versionPath, defaultVersion))
+		}
+	}
+	return infos, nil
+}
+
+// inspectVersion 收集单个版本的清单信息
+func (em *environmentManager) inspectVersion(rt Runtime, version, versionPath, defaultVersion string) EnvironmentInfo {
+	info := EnvironmentInfo{
+		EnvType:   rt.Name(),
+		Version:   version,
+		Versioned: true,
+		Default:   version == defaultVersion,
+		Path:      versionPath,
+	}
+	if installed, err := readInstallInfo(versionPath); err == nil {
+		info.DetectedVersion = installed.DetectedVersion
+		installedAt := installed.InstalledAt
+		info.InstalledAt = &installedAt
+	}
+	if size, err := dirSize(versionPath); err != nil {
+		em.logger.Warn("统计运行时目录大小失败", zap.String("path", versionPath), zap.Error(err))
+	} else {
+		info.SizeBytes = size
+	}
+	if stat, err := os.Stat(em.lastUsedPath(rt.Name(), version)); err == nil {
+		lastUsed := stat.ModTime()
+		info.LastUsedAt = &lastUsed
+	}
+	em.smokeTest(rt, versionPath, &info)
+	return info
+}
+
+// smokeTest 解析可执行文件并运行以检测版本号，结果写入 info
+func (em *environmentManager) smokeTest(rt Runtime, envPath string, info *EnvironmentInfo) {
+	executable, err := rt.Executable(envPath)
+	if err != nil {
+		info.Error = err.Error()
+		return
+	}
+	info.Executable = executable
+	detected, err := rt.Validate(envPath)
+	if err != nil {
+		info.Error = err.Error()
+		return
+	}
+	info.Healthy = true
+	if detected != "" {
+		info.DetectedVersion = detected
+	}
+}
+
+// ResolveRuntime 解析任务使用的运行时版本，登记占用并记录使用时间
+// 参数: envType 运行时名称, version 版本（为空时使用默认版本）
+// 返回: 实际使用的版本，版本目录（使用宿主程序的运行时均为空），释放占用的函数，错误信息
+func (em *environmentManager) ResolveRuntime(envType, version string) (string, string, func(), error) {
+	if em.config == nil {
+		return "", "", nil, fmt.Errorf("环境管理器未初始化")
+	}
+	rt, err := em.GetRuntime(envType)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if !rt.Versioned() {
+		return "", "", func() {}, nil
+	}
+	if version == "" {
+		if version = em.DefaultVersion(envType); version == "" {
+			return "", "", nil, fmt.Errorf("%s 未指定版本，且没有设置默认版本", envType)
+		}
+	}
+	versionPath, err := em.GetRuntimePath(envType, version)
+	if err != nil {
+		return "", "", nil, err
+	}
+	release, err := em.acquire(versionPath)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("%s版本 %s 不可用: %v", envType, version, err)
+	}
+	em.touchLastUsed(envType, version)
+	return version, versionPath, release, nil
+}
+
+// DefaultVersion 获取运行时的默认版本
+// 通过 SetDefaultVersion 设置的版本优先，否则使用配置中的 default_version
+// 参数: envType 运行时名称
+// 返回: 默认版本，未设置时为空
+func (em *environmentManager) DefaultVersion(envType string) string {
+	env := em.config.Sandbox.Environments.Runtime(envType)
+	if data, err := os.ReadFile(filepath.Join(env.VersionsPath, defaultVersionFile)); err == nil {
+		if version := strings.TrimSpace(string(data)); version != "" {
+			return version
+		}
+	}
+	return env.DefaultVersion
+}
+
+// SetDefaultVersion 设置运行时的默认版本，版本需已安装并通过冒烟测试
+// 参数: envType 运行时名称, version 版本
+// 返回: 错误信息
+func (em *environmentManager) SetDefaultVersion(envType, version string) error {
+	if em.config == nil {
+		return fmt.Errorf("环境管理器未初始化")
+	}
+	rt, err := em.GetRuntime(envType)
+	if err != nil {
+		return err
+	}
+	if !rt.Versioned() {
+		return fmt.Errorf("运行时 %s 使用宿主程序，不区分版本", envType)
+	}
+	versionPath, err := em.GetRuntimePath(envType, version)
+	if err != nil {
+		return err
+	}
+	if _, err := rt.Validate(versionPath); err != nil {
+		return fmt.Errorf("%s版本 %s 不可用，不能设为默认版本: %v", envType, version, err)
+	}
+
+	// 先写临时文件再改名，避免并发读取到不完整的内容
+	path := filepath.Join(em.config.Sandbox.Environments.Runtime(envType).VersionsPath, defaultVersionFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(version+"\n"), 0644); err != nil {
+		return fmt.Errorf("写入默认版本失败: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("写入默认版本失败: %v", err)
+	}
+	em.logger.Info("设置运行时默认版本", zap.String("env_type", envType), zap.String("version", version))
+	return nil
+}
+
+// lastUsedPath 记录版本最近使用时间的文件
+func (em *environmentManager) lastUsedPath(envType, version string) string {
+	return filepath.Join(em.config.Sandbox.Environments.Runtime(envType).VersionsPath, lastUsedDir, version)
+}
+
+// touchLastUsed 更新版本的最近使用时间，失败只记录日志
+func (em *environmentManager) touchLastUsed(envType, version string) {
+	path := em.lastUsedPath(envType, version)
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err == nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+		if file, err := os.Create(path); err == nil {
+			file.Close()
+			return
+		}
+	}
+	em.logger.Warn("记录运行时使用时间失败", zap.String("env_type", envType), zap.String("version", version))
+}
+
+// readInstallInfo 读取版本目录中的安装信息
+func readInstallInfo(versionPath string) (*InstallInfo, error) {
+	data, err := os.ReadFile(filepath.Join(versionPath, installInfoFile))
+	if err != nil {
+		return nil, err
+	}
+	var info InstallInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("解析安装信息失败: %v", err)
+	}
+	return &info, nil
+}
+
+// dirSize 统计目录下普通文件的总大小，不跟随目录内的符号链接
+func dirSize(root string) (int64, error) {
+	root, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type().IsRegular() {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,218 @@
+package environments
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// wheelhouseDir Python.InstallersPath 下存放离线安装包（wheel）的目录
+	wheelhouseDir = "wheelhouse"
+	// venvReadyFile 虚拟环境构建完成的标记文件，修改时间记录最近一次使用时间
+	venvReadyFile = ".ready"
+)
+
+// venvLocks 按缓存键串行化同一虚拟环境的构建
+type venvLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func (l *venvLocks) get(key string) *sync.Mutex {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.locks == nil {
+		l.locks = make(map[string]*sync.Mutex)
+	}
+	lock, ok := l.locks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		l.locks[key] = lock
+	}
+	return lock
+}
+
+// PreparePythonVenv 准备安装了 requirements.txt 中依赖的虚拟环境
+// 虚拟环境以 Python 版本和规范化后的依赖列表计算缓存键，依赖相同的任务共用同一个环境。
+// 依赖只从 Python.InstallersPath/wheelhouse 中的 wheel 离线安装，不访问网络，也不构建源码包
+// 参数: ctx 上下文, version Python版本, requirementsPath requirements.txt 路径
+// 返回: 虚拟环境目录，释放占用的函数（占用期间不会被回收），错误信息
+func (em *environmentManager) PreparePythonVenv(ctx context.Context, version, requirementsPath string) (string, func(), error) {
+	if em.config == nil {
+		return "", nil, fmt.Errorf("环境管理器未初始化")
+	}
+	pythonPath, err := em.GetRuntimePath("python", version)
+	if err != nil {
+		return "", nil, err
+	}
+	requirements, err := readRequirements(requirementsPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	key := venvKey(version, requirements)
+	venvPath, err := filepath.Abs(filepath.Join(em.venvsPath(), key))
+	if err != nil {
+		return "", nil, fmt.Errorf("虚拟环境目录不合法: %v", err)
+	}
+
+	lock := em.venvLocks.get(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	ready := filepath.Join(venvPath, venvReadyFile)
+	if _, err := os.Stat(ready); err == nil {
+		now := time.Now()
+		os.Chtimes(ready, now, now)
+		release, err := em.acquire(venvPath)
+		if err != nil {
+			return "", nil, err
+		}
+		em.logger.Info("复用Python虚拟环境", zap.String("version", version), zap.String("venv", venvPath))
+		return venvPath, release, nil
+	}
+
+	// 没有完成标记的目录是上次构建中断留下的，需要重建
+	if err := os.RemoveAll(venvPath); err != nil {
+		return "", nil, fmt.Errorf("清理未完成的虚拟环境失败: %v", err)
+	}
+	if err := em.buildVenv(ctx, pythonPath, venvPath, requirements); err != nil {
+		os.RemoveAll(venvPath)
+		return "", nil, err
+	}
+	if err := os.WriteFile(ready, []byte(strings.Join(requirements, "\n")+"\n"), 0644); err != nil {
+		os.RemoveAll(venvPath)
+		return "", nil, fmt.Errorf("写入虚拟环境标记失败: %v", err)
+	}
+	em.logger.Info("Python虚拟环境构建完成",
+		zap.String("version", version),
+		zap.String("venv", venvPath),
+		zap.Int("requirements", len(requirements)))
+	release, err := em.acquire(venvPath)
+	if err != nil {
+		return "", nil, err
+	}
+	return venvPath, release, nil
+}
+
+// venvsPath 虚拟环境缓存目录，未配置时使用 Python 环境根目录下的 venvs
+func (em *environmentManager) venvsPath() string {
+	if path := em.config.Sandbox.Environments.Python.VenvsPath; path != "" {
+		return path
+	}
+	return filepath.Join(em.config.Sandbox.Environments.Runtime("python").BasePath, "venvs")
+}
+
+// buildVenv 创建虚拟环境并从本地 wheelhouse 安装依赖
+// 虚拟环境中的脚本记录了绝对路径，因此直接在最终目录中构建，失败时由调用方删除
+func (em *environmentManager) buildVenv(ctx context.Context, pythonPath, venvPath string, requirements []string) error {
+	interpreter, err := PythonExecutable(pythonPath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(venvPath), 0755); err != nil {
+		return fmt.Errorf("创建虚拟环境目录失败: %v", err)
+	}
+	if err := runInstallCommand(ctx, interpreter, "-m", "venv", venvPath); err != nil {
+		return fmt.Errorf("创建Python虚拟环境失败: %v", err)
+	}
+	if len(requirements) == 0 {
+		return nil
+	}
+
+	wheelhouse, err := filepath.Abs(filepath.Join(em.config.Sandbox.Environments.Runtime("python").InstallersPath, wheelhouseDir))
+	if err != nil {
+		return fmt.Errorf("wheelhouse目录不合法: %v", err)
+	}
+	if _, err := os.Stat(wheelhouse); err != nil {
+		return fmt.Errorf("wheelhouse目录不可用: %v", err)
+	}
+	// 规范化后的依赖写入虚拟环境目录，避免直接使用任务上传的文件
+	normalized := filepath.Join(venvPath, "requirements.txt")
+	if err := os.WriteFile(normalized, []byte(strings.Join(requirements, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("写入依赖列表失败: %v", err)
+	}
+	venvPython, err := PythonExecutable(venvPath)
+	if err != nil {
+		return err
+	}
+	em.logger.Info("安装Python依赖", zap.String("venv", venvPath), zap.String("wheelhouse", wheelhouse))
+	if err := runInstallCommand(ctx, venvPython, "-m", "pip", "install",
+		"--no-index", "--find-links", wheelhouse, "--only-binary", ":all:",
+		"--disable-pip-version-check", "--no-input", "-r", normalized); err != nil {
+		return fmt.Errorf("安装Python依赖失败: %v", err)
+	}
+	return nil
+}
+
+// readRequirements 读取并规范化 requirements.txt
+// 去掉注释和空行后排序；拒绝 pip 选项和直接引用（URL、本地路径），依赖只能来自 wheelhouse
+// 参数: path 文件路径
+// 返回: 依赖列表，错误信息
+func readRequirements(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取依赖列表失败: %v", err)
+	}
+	defer file.Close()
+
+	var requirements []string
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "-") || strings.Contains(text, "://") || strings.Contains(text, "@") ||
+			strings.ContainsAny(text, `/\`) {
+			return nil, fmt.Errorf("依赖列表第 %d 行 %q 不受支持，只允许填写包名和版本约束", line, text)
+		}
+		requirements = append(requirements, text)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取依赖列表失败: %v", err)
+	}
+	sort.Strings(requirements)
+	return requirements, nil
+}
+
+// venvKey 虚拟环境的缓存键
+func venvKey(version string, requirements []string) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "python=%s\n", version)
+	for _, requirement := range requirements {
+		fmt.Fprintln(hash, requirement)
+	}
+	return version + "-" + hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// runInstallCommand 执行安装命令，失败时返回命令输出
+func runInstallCommand(ctx context.Context, name string, args ...string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	// 禁止 pip 读取用户或全局配置中的镜像源设置
+	cmd.Env = append(os.Environ(), "PIP_CONFIG_FILE="+os.DevNull, "PIP_NO_INDEX=1")
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%v: %s", err, bytes.TrimSpace(output.Bytes()))
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package environments
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Runtime 运行时插件
+// 每种运行时以名称注册，名称即任务的 envType，也是配置 sandbox.environments.runtimes 下的配置段名
+type Runtime interface {
+	// Name 运行时名称
+	Name() string
+
+	// Versioned 是否按版本安装在 versions_path/<version> 下，为 false 时直接使用宿主上的程序
+	Versioned() bool
+
+	// Executable 查找运行时的可执行文件
+	// 参数: envPath 版本目录（不分版本的运行时为空）
+	// 返回: 可执行文件的绝对路径（直接执行任务命令时为空），错误信息
+	Executable(envPath string) (string, error)
+
+	// CommandLine 构建执行任务的程序和参数
+	// 参数: executable Executable 返回的可执行文件, cmd 任务命令或脚本, args 参数
+	// 返回: 程序路径，参数列表
+	CommandLine(executable, cmd string, args []string) (string, []string)
+
+	// Environ 运行时自身需要的环境变量（KEY=VALUE），任务进程不继承服务进程的环境变量
+	// 参数: envPath 版本目录（不分版本的运行时为空）
+	Environ(envPath string) []string
+
+	// Validate 运行可执行文件确认运行时可用
+	// 参数: envPath 版本目录
+	// 返回: 检测到的版本号，错误信息
+	Validate(envPath string) (string, error)
+
+	// Install 将安装包解压到临时目录
+	// 参数: installerPath 安装包路径, staging 临时目录
+	// 返回: 运行时根目录（位于 staging 内），错误信息
+	Install(installerPath, staging string) (string, error)
+
+	// Detection 自动识别规则，不参与自动识别时返回 nil
+	Detection() *Detection
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Runtime)
+)
+
+// RegisterRuntime 注册运行时，同名运行时会被替换
+func RegisterRuntime(rt Runtime) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[rt.Name()] = rt
+}
+
+// LookupRuntime 按名称查找运行时
+func LookupRuntime(name string) (Runtime, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	rt, ok := registry[name]
+	return rt, ok
+}
+
+// RuntimeNames 返回已注册的运行时名称
+func RuntimeNames() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// InterpreterRuntime 以解释器或虚拟机执行任务的运行时，各版本安装在 versions_path/<version> 下
+type InterpreterRuntime struct {
+	// RuntimeName 运行时名称
+	RuntimeName string
+	// Candidates 版本目录下可执行文件的候选相对路径，按顺序查找
+	Candidates []string
+	// VersionArgs 输出版本号的参数
+	VersionArgs []string
+	// Options 返回插入在任务命令之前的参数，为空时不插入
+	Options func(cmd string) []string
+	// Env 返回运行时自身需要的环境变量，为空时不设置
+	Env func(envPath string) []string
+	// Detect 自动识别规则
+	Detect *Detection
+}
+
+func (r *InterpreterRuntime) Name() string {
+	return r.RuntimeName
+}
+
+func (r *InterpreterRuntime) Versioned() bool {
+	return true
+}
+
+func (r *InterpreterRuntime) Executable(envPath string) (string, error) {
+	return findExecutable(envPath, r.Candidates...)
+}
+
+func (r *InterpreterRuntime) CommandLine(executable, cmd string, args []string) (string, []string) {
+	var argv []string
+	if r.Options != nil {
+		argv = append(argv, r.Options(cmd)...)
+	}
+	return executable, append(append(argv, cmd), args...)
+}
+
+func (r *InterpreterRuntime) Environ(envPath string) []string {
+	if r.Env == nil {
+		return nil
+	}
+	return r.Env(envPath)
+}
+
+func (r *InterpreterRuntime) Validate(envPath string) (string, error) {
+	executable, err := r.Executable(envPath)
+	if err != nil {
+		return "", err
+	}
+	return detectVersion(executable, r.VersionArgs...)
+}
+
+func (r *InterpreterRuntime) Detection() *Detection {
+	return r.Detect
+}
+
+func (r *InterpreterRuntime) Install(installerPath, staging string) (string, error) {
+	if err := extractArchive(installerPath, staging); err != nil {
+		return "", err
+	}
+	return archiveRoot(staging)
+}
+
+// SystemRuntime 使用宿主程序执行任务的运行时，不分版本，也无需安装
+type SystemRuntime struct {
+	// RuntimeName 运行时名称
+	RuntimeName string
+	// Interpreter 宿主上的解释器路径，为空时直接执行任务命令（如编译好的二进制）
+	Interpreter string
+	// Detect 自动识别规则
+	Detect *Detection
+}
+
+func (r *SystemRuntime) Name() string {
+	return r.RuntimeName
+}
+
+func (r *SystemRuntime) Versioned() bool {
+	return false
+}
+
+func (r *SystemRuntime) Executable(envPath string) (string, error) {
+	if r.Interpreter == "" {
+		return "", nil
+	}
+	if _, err := os.Stat(r.Interpreter); err != nil {
+		return "", fmt.Errorf("解释器 %s 不可用: %v", r.Interpreter, err)
+	}
+	return r.Interpreter, nil
+}
+
+func (r *SystemRuntime) CommandLine(executable, cmd string, args []string) (string, []string) {
+	if executable == "" {
+		return cmd, args
+	}
+	return executable, append([]string{cmd}, args...)
+}
+
+func (r *SystemRuntime) Environ(envPath string) []string {
+	return nil
+}
+
+func (r *SystemRuntime) Validate(envPath string) (string, error) {
+	_, err := r.Executable(envPath)
+	return "", err
+}
+
+func (r *SystemRuntime) Detection() *Detection {
+	return r.Detect
+}
+
+func (r *SystemRuntime) Install(installerPath, staging string) (string, error) {
+	return "", fmt.Errorf("运行时 %s 使用宿主程序，无需安装", r.RuntimeName)
+}
+
+func init() {
+	RegisterRuntime(&InterpreterRuntime{
+		RuntimeName: "python",
+		Candidates:  []string{"bin/python3", "bin/python", "Scripts/python.exe", "python.exe", "python3", "python"},
+		VersionArgs: []string{"--version"},
+		// 不加载运行用户目录下的 site-packages
+		Env: func(envPath string) []string {
+			return []string{"PYTHONNOUSERSITE=1"}
+		},
+		Detect: &Detection{
+			Extensions:   []string{".py"},
+			Interpreters: []string{"python"},
+			Markers:      []string{"requirements.txt", "pyproject.toml", "__main__.py"},
+			Entrypoint:   pythonEntrypoint,
+		},
+	})
+	RegisterRuntime(&InterpreterRuntime{
+		RuntimeName: "java",
+		Candidates:  []string{"bin/java", "bin/java.exe"},
+		VersionArgs: []string{"-version"},
+		Options: func(cmd string) []string {
+			if strings.HasSuffix(strings.ToLower(cmd), ".jar") {
+				return []string{"-jar"}
+			}
+			return nil
+		},
+		Env: func(envPath string) []string {
+			return []string{"JAVA_HOME=" + envPath}
+		},
+		Detect: &Detection{
+			Extensions: []string{".jar"},
+			Markers:    []string{"pom.xml"},
+			Entrypoint: javaEntrypoint,
+		},
+	})
+	RegisterRuntime(&InterpreterRuntime{
+		RuntimeName: "node",
+		Candidates:  []string{"bin/node", "node.exe"},
+		VersionArgs: []string{"--version"},
+		Detect: &Detection{
+			Extensions:   []string{".js", ".mjs", ".cjs"},
+			Interpreters: []string{"node", "nodejs"},
+			Markers:      []string{"package.json"},
+			Entrypoint:   nodeEntrypoint,
+		},
+	})
+	RegisterRuntime(&SystemRuntime{
+		RuntimeName: "shell",
+		Interpreter: "/bin/sh",
+		Detect: &Detection{
+			Extensions:   []string{".sh"},
+			Interpreters: []string{"sh", "dash", "ash"},
+		},
+	})
+	RegisterRuntime(&SystemRuntime{RuntimeName: "go"})
+}
+
+// PythonExecutable 在 Python 安装目录或虚拟环境中查找解释器
+// 参数: envPath 安装目录或虚拟环境目录
+// 返回: 解释器的绝对路径，错误信息
+func PythonExecutable(envPath string) (string, error) {
+	rt, _ := LookupRuntime("python")
+	return rt.Executable(envPath)
+}
+
+// findExecutable 在环境目录下按顺序查找第一个存在的可执行文件
+func findExecutable(envPath string, candidates ...string) (string, error) {
+	for _, candidate := range candidates {
+		path := filepath.Join(envPath, filepath.FromSlash(candidate))
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if runtime.GOOS != "windows" && info.Mode()&0111 == 0 {
+			continue
+		}
+		return filepath.Abs(path)
+	}
+	return "", fmt.Errorf("在 %s 中未找到可执行文件，候选路径 %v 均不存在", envPath, candidates)
+}
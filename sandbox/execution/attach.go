@@ -0,0 +1,120 @@
+package execution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// attachPollInterval 检查重新接管的任务进程是否结束的间隔
+// 进程不是当前服务的子进程，无法等待其退出，只能轮询
+const attachPollInterval = time.Second
+
+// AttachRequest 重新接管任务进程的请求
+type AttachRequest struct {
+	// TaskID 任务ID
+	TaskID string
+	// PID 任务进程ID
+	PID int
+	// StartTime 记录的进程启动时间，用于确认 PID 没有被复用
+	StartTime int64
+	// StartedAt 任务开始执行的时间，执行超时从该时间起算
+	StartedAt time.Time
+	// Attempt 任务的第几次执行，与启动进程时的 ExecutionRequest.Attempt 一致
+	Attempt int
+}
+
+// AttachTask 重新接管服务重启前启动、仍在运行的任务进程
+// 轮询等待进程结束，超时或 ctx 被取消时与 ExecuteTask 一样终止整个进程组。
+// 进程由之前的服务进程启动，退出码无法获取：cgroup 记录到内存超限时返回 ErrOutOfMemory，
+// 否则返回 ErrExitStatusUnknown
+// 参数: ctx 上下文, req 接管请求
+// 返回: 执行结果，错误信息（进程已不存在时返回 ErrProcessNotFound）
+func (se *sandboxExecutor) AttachTask(ctx context.Context, req AttachRequest) (*ExecutionResult, error) {
+	if se.config == nil {
+		return nil, fmt.Errorf("沙盒执行器未初始化")
+	}
+	if !ProcessAlive(req.PID, req.StartTime) {
+		return nil, ErrProcessNotFound
+	}
+	if timeout := se.executionTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, req.StartedAt.Add(timeout))
+		defer cancel()
+	}
+
+	logDir := se.logDir(req.TaskID, req.Attempt)
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建任务日志目录失败: %v", err)
+	}
+	result := &ExecutionResult{
+		TaskID:     req.TaskID,
+		PID:        req.PID,
+		ExitCode:   -1,
+		StartedAt:  req.StartedAt,
+		StdoutPath: filepath.Join(logDir, "stdout.log"),
+		StderrPath: filepath.Join(logDir, "stderr.log"),
+		LimitMode:  LimitModeNone,
+		Isolation:  se.isolation.mode,
+	}
+	var cgroup *taskCgroup
+	if se.cgroups != nil {
+		if opened, err := se.cgroups.open(req.TaskID); err == nil {
+			cgroup = opened
+			result.LimitMode = LimitModeCgroup
+		}
+	}
+	se.logger.Info("重新接管任务进程", zap.String("task_id", req.TaskID), zap.Int("pid", req.PID))
+
+	exited := make(chan struct{})
+	terminated := make(chan struct{})
+	go se.terminateOnDone(ctx, req.TaskID, req.PID, exited, terminated)
+
+	ticker := time.NewTicker(attachPollInterval)
+	for ProcessAlive(req.PID, req.StartTime) {
+		<-ticker.C
+	}
+	ticker.Stop()
+	close(exited)
+	<-terminated
+	result.FinishedAt = time.Now()
+	result.Duration = result.FinishedAt.Sub(result.StartedAt)
+	if cgroup != nil {
+		result.Usage = cgroup.usage()
+		result.OOMKilled = result.Usage.OOMKills > 0
+		if err := cgroup.destroy(); err != nil {
+			se.logger.Warn("清理任务cgroup失败", zap.String("task_id", req.TaskID), zap.Error(err))
+		}
+	}
+	switch ctxErr := ctx.Err(); {
+	case errors.Is(ctxErr, context.DeadlineExceeded):
+		result.Terminated = "timeout"
+	case ctxErr != nil:
+		result.Terminated = "cancelled"
+	}
+	if result.Terminated == "" && result.OOMKilled {
+		result.FailureReason = FailureOutOfMemory
+	}
+	se.writeResult(logDir, result)
+
+	se.logger.Info("重新接管的任务进程已结束",
+		zap.String("task_id", req.TaskID),
+		zap.String("terminated", result.Terminated),
+		zap.Bool("oom_killed", result.OOMKilled),
+		zap.Duration("duration", result.Duration))
+
+	switch {
+	case result.Terminated == "timeout":
+		return result, ErrExecutionTimeout
+	case result.Terminated == "cancelled":
+		return result, ErrExecutionCancelled
+	case result.OOMKilled:
+		return result, ErrOutOfMemory
+	}
+	return result, ErrExitStatusUnknown
+}
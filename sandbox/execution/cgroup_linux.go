@@ -0,0 +1,213 @@
+//go:build linux
+
+package execution
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// cgroupMountPoint cgroup v2 统一层级挂载点
+	cgroupMountPoint = "/sys/fs/cgroup"
+	// cpuPeriod cpu.max 的调度周期（微秒）
+	cpuPeriod = 100000
+)
+
+// cgroupManager 管理任务 cgroup 的父目录
+type cgroupManager struct {
+	root string
+}
+
+// newCgroupManager 准备任务 cgroup 父目录并为子目录启用 memory/cpu/pids 控制器
+// 参数: root 父目录路径
+// 返回: 管理器，系统未使用 cgroup v2 或目录不可写时返回错误
+func newCgroupManager(root string) (*cgroupManager, error) {
+	if root == "" {
+		return nil, fmt.Errorf("未配置cgroup父目录")
+	}
+	if _, err := os.Stat(filepath.Join(cgroupMountPoint, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("系统未挂载cgroup v2: %v", err)
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("创建cgroup父目录失败: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "cgroup.controllers"))
+	if err != nil {
+		return nil, fmt.Errorf("读取cgroup控制器列表失败: %v", err)
+	}
+	available := strings.Fields(string(data))
+	for _, controller := range []string{"memory", "cpu", "pids"} {
+		if !sliceContains(available, controller) {
+			return nil, fmt.Errorf("cgroup控制器 %s 不可用", controller)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "cgroup.subtree_control"), []byte("+memory +cpu +pids"), 0644); err != nil {
+		return nil, fmt.Errorf("启用cgroup控制器失败: %v", err)
+	}
+	return &cgroupManager{root: root}, nil
+}
+
+// taskCgroup 单个任务的 cgroup
+type taskCgroup struct {
+	path string
+	dir  *os.File
+}
+
+// create 为任务创建 cgroup 并写入资源限制
+// 参数: taskID 任务ID, limits 资源限制
+// 返回: 任务cgroup，错误信息
+func (m *cgroupManager) create(taskID string, limits ResourceLimits) (*taskCgroup, error) {
+	path := filepath.Join(m.root, taskID)
+	if err := os.Mkdir(path, 0755); err != nil && !os.IsExist(err) {
+		return nil, fmt.Errorf("创建任务cgroup失败: %v", err)
+	}
+
+	values := []struct{ file, value string }{
+		{"memory.max", "max"},
+		{"cpu.max", fmt.Sprintf("max %d", cpuPeriod)},
+		{"pids.max", "max"},
+	}
+	if limits.Memory > 0 {
+		values[0].value = strconv.FormatInt(limits.Memory, 10)
+	}
+	if limits.CPUCores > 0 {
+		values[1].value = fmt.Sprintf("%d %d", int64(limits.CPUCores*cpuPeriod), cpuPeriod)
+	}
+	if limits.MaxPids > 0 {
+		values[2].value = strconv.FormatInt(limits.MaxPids, 10)
+	}
+	for _, v := range values {
+		if err := os.WriteFile(filepath.Join(path, v.file), []byte(v.value), 0644); err != nil {
+			os.Remove(path)
+			return nil, fmt.Errorf("写入 %s 失败: %v", v.file, err)
+		}
+	}
+	if limits.Memory > 0 {
+		// 禁止使用交换分区绕过内存限制，内核未启用swap控制时忽略
+		os.WriteFile(filepath.Join(path, "memory.swap.max"), []byte("0"), 0644)
+	}
+
+	dir, err := os.Open(path)
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("打开任务cgroup失败: %v", err)
+	}
+	return &taskCgroup{path: path, dir: dir}, nil
+}
+
+// open 打开已存在的任务 cgroup，用于重新接管服务重启前启动的任务
+// 返回: 任务cgroup，不存在时返回错误
+func (m *cgroupManager) open(taskID string) (*taskCgroup, error) {
+	path := filepath.Join(m.root, taskID)
+	dir, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开任务cgroup失败: %v", err)
+	}
+	return &taskCgroup{path: path, dir: dir}, nil
+}
+
+// removeIdle 删除没有进程的任务 cgroup，清理服务异常退出时遗留的目录
+// 仍有进程的 cgroup 保留，由重新接管的任务结束后删除
+// 返回: 删除的 cgroup 数
+func (m *cgroupManager) removeIdle() int {
+	entries, err := os.ReadDir(m.root)
+	if err != nil {
+		return 0
+	}
+	removed := 0
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(m.root, entry.Name())
+		procs, err := os.ReadFile(filepath.Join(path, "cgroup.procs"))
+		if err != nil || strings.TrimSpace(string(procs)) != "" {
+			continue
+		}
+		if err := os.Remove(path); err == nil {
+			removed++
+		}
+	}
+	return removed
+}
+
+// attach 让任务进程在创建时直接进入该 cgroup，避免启动后再迁移产生的时间窗口
+func (c *taskCgroup) attach(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(c.dir.Fd())
+}
+
+// usage 读取任务的资源使用情况，读取失败的项保持为 0
+func (c *taskCgroup) usage() ResourceUsage {
+	var usage ResourceUsage
+	if data, err := os.ReadFile(filepath.Join(c.path, "memory.peak")); err == nil {
+		usage.MemoryPeak, _ = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	}
+	events := readKeyValues(filepath.Join(c.path, "memory.events"))
+	usage.OOMKills = events["oom_kill"]
+	stat := readKeyValues(filepath.Join(c.path, "cpu.stat"))
+	usage.CPUUsage = time.Duration(stat["usage_usec"]) * time.Microsecond
+	usage.ThrottledPeriods = stat["nr_throttled"]
+	usage.ThrottledTime = time.Duration(stat["throttled_usec"]) * time.Microsecond
+	return usage
+}
+
+// destroy 终止 cgroup 中残留的进程并删除 cgroup
+func (c *taskCgroup) destroy() error {
+	// cgroup.kill 需要 5.14 及以上内核，不支持时依赖进程组已被终止
+	os.WriteFile(filepath.Join(c.path, "cgroup.kill"), []byte("1"), 0644)
+	c.dir.Close()
+
+	var err error
+	for i := 0; i < 20; i++ {
+		if err = os.Remove(c.path); err == nil || os.IsNotExist(err) {
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return fmt.Errorf("删除任务cgroup失败: %v", err)
+}
+
+// readKeyValues 解析 cgroup 中 "key value" 格式的统计文件
+func readKeyValues(path string) map[string]int64 {
+	values := make(map[string]int64)
+	file, err := os.Open(path)
+	if err != nil {
+		return values
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if value, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			values[fields[0]] = value
+		}
+	}
+	return values
+}
+
+// sliceContains 判断切片中是否包含指定字符串
+func sliceContains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+//go:build !linux
+
+package execution
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// cgroupManager 非 Linux 平台不支持 cgroup
+type cgroupManager struct{}
+
+// newCgroupManager 非 Linux 平台总是返回错误，资源限制回退为其他方式
+func newCgroupManager(root string) (*cgroupManager, error) {
+	return nil, fmt.Errorf("当前平台不支持cgroup")
+}
+
+// taskCgroup 非 Linux 平台的占位实现
+type taskCgroup struct{}
+
+func (m *cgroupManager) create(taskID string, limits ResourceLimits) (*taskCgroup, error) {
+	return nil, fmt.Errorf("当前平台不支持cgroup")
+}
+
+func (m *cgroupManager) open(taskID string) (*taskCgroup, error) {
+	return nil, fmt.Errorf("当前平台不支持cgroup")
+}
+
+func (m *cgroupManager) removeIdle() int {
+	return 0
+}
+
+func (c *taskCgroup) attach(cmd *exec.Cmd) {}
+
+func (c *taskCgroup) usage() ResourceUsage {
+	return ResourceUsage{}
+}
+
+func (c *taskCgroup) destroy() error {
+	return nil
+}
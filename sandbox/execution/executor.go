@@ -3,21 +3,132 @@
 package execution
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 	"file-flow-service/config"
 	"file-flow-service/utils/logger"
 	"file-flow-service/sandbox/environments"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrNonZeroExit 任务进程以非零退出码结束
+	ErrNonZeroExit = errors.New("任务进程非零退出")
+	// ErrExecutionTimeout 任务执行超时，进程组已被终止
+	ErrExecutionTimeout = errors.New("任务执行超时")
+	// ErrExecutionCancelled 任务被取消，进程组已被终止
+	ErrExecutionCancelled = errors.New("任务已取消")
+	// ErrOutOfMemory 任务进程因超出内存限制被内核终止
+	ErrOutOfMemory = errors.New("任务内存超出限制")
+	// ErrSeccompViolation 任务进程调用了过滤策略禁止的系统调用，被内核终止
+	ErrSeccompViolation = errors.New("任务违反系统调用过滤策略")
+	// ErrProcessNotFound 要重新接管的任务进程已不存在
+	ErrProcessNotFound = errors.New("任务进程已不存在")
+	// ErrExitStatusUnknown 重新接管的任务进程已结束，但不是当前服务启动的进程，无法获取退出码
+	ErrExitStatusUnknown = errors.New("任务进程已结束，退出码未知")
+	// ErrRuntimeNotDetected 请求未指定运行时，且无法根据任务目录中的文件识别
+	ErrRuntimeNotDetected = errors.New("无法识别任务的运行时")
 )
 
+// pythonRequirementsFile 任务目录中声明 Python 依赖的文件
+const pythonRequirementsFile = "requirements.txt"
+
+// defaultKillGracePeriod 未配置 kill_grace_period 时 SIGTERM 与 SIGKILL 之间的等待时间
+const defaultKillGracePeriod = 10 * time.Second
+
+// defaultTaskPath 任务进程 PATH 中的系统目录，运行时的可执行文件目录排在它们之前
+const defaultTaskPath = "/usr/local/bin:/usr/bin:/bin"
+
+// defaultTaskLang 服务进程未设置 LANG 时任务使用的语言环境
+const defaultTaskLang = "C.UTF-8"
+
+// ExecutionRequest 任务执行请求
+type ExecutionRequest struct {
+	// TaskID 任务ID
+	TaskID string
+	// TaskDir 任务目录，作为进程工作目录
+	TaskDir string
+	// Command 命令或脚本
+	Command string
+	// Args 命令参数
+	Args []string
+	// EnvType 环境类型（python/java 等已注册的运行时），为空时根据任务目录中的文件自动识别，识别不出时拒绝执行
+	EnvType string
+	// EnvVersion 环境版本
+	EnvVersion string
+	// Limits 任务指定的资源限制，零值字段使用 sandbox.resource_limits 配置
+	Limits ResourceLimits
+	// AllowNetwork namespace 隔离模式下允许任务访问网络，默认隔离网络，启用时记录审计日志
+	AllowNetwork bool
+	// Attempt 任务的第几次执行，大于 0 时每次执行的日志写入单独的目录
+	Attempt int
+}
+
+// ExecutionResult 任务执行结果
+type ExecutionResult struct {
+	TaskID     string        `json:"task_id"`
+	// EnvType 任务使用的运行时
+	EnvType string `json:"env_type,omitempty"`
+	// DetectedBy 自动识别运行时或入口的依据，请求中指定了运行时和命令时为空
+	DetectedBy string `json:"detected_by,omitempty"`
+	Command    string        `json:"command"`
+	Args       []string      `json:"args"`
+	PID        int           `json:"pid"`
+	ExitCode   int           `json:"exit_code"`
+	Signal     string        `json:"signal,omitempty"`
+	// Terminated 进程组被终止的原因（timeout/cancelled），正常结束时为空
+	Terminated string        `json:"terminated,omitempty"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	Duration   time.Duration `json:"duration"`
+	StdoutPath string        `json:"stdout_path"`
+	StderrPath string        `json:"stderr_path"`
+	// LimitMode 资源限制的实施方式（cgroup/rlimit/none）
+	LimitMode  string         `json:"limit_mode"`
+	Limits     ResourceLimits `json:"limits"`
+	// Usage 资源使用情况，仅 cgroup 模式下有效
+	Usage      ResourceUsage  `json:"usage"`
+	// OOMKilled 任务进程是否因超出内存限制被内核终止
+	OOMKilled  bool           `json:"oom_killed"`
+	// Isolation 隔离模式（none/chroot/namespace）
+	Isolation string `json:"isolation"`
+	// NetworkIsolated 任务是否运行在独立的网络命名空间中
+	NetworkIsolated bool `json:"network_isolated"`
+	// SeccompProfile 生效的系统调用过滤策略，为空表示未过滤
+	SeccompProfile string `json:"seccomp_profile,omitempty"`
+	// FailureReason 任务失败原因（non_zero_exit/out_of_memory/seccomp_violation），成功或被终止时为空
+	FailureReason string `json:"failure_reason,omitempty"`
+}
+
+// processObserverKey 进程启动回调在 context 中的键
+type processObserverKey struct{}
+
+// WithProcessObserver 返回携带进程启动回调的 context
+// 任务进程启动后以其 PID 调用 observer，调用方可据此采样资源占用
+func WithProcessObserver(ctx context.Context, observer func(pid int)) context.Context {
+	return context.WithValue(ctx, processObserverKey{}, observer)
+}
+
 // SandboxExecutor 沙盒执行器接口
 type SandboxExecutor interface {
 	// Init 初始化沙盒执行器
 	Init(config *config.AppConfig, logger logger.Logger, envManager environments.EnvironmentManager) error
 	
-	// ExecuteTask 执行任务
-	ExecuteTask(taskID, taskDir, cmd string, args []string, envType, envVersion string) error
+	// ExecuteTask 执行任务，ctx 取消时终止任务进程
+	ExecuteTask(ctx context.Context, req ExecutionRequest) (*ExecutionResult, error)
+
+	// AttachTask 重新接管服务重启前启动、仍在运行的任务进程，等待其结束，ctx 取消时终止任务进程
+	AttachTask(ctx context.Context, req AttachRequest) (*ExecutionResult, error)
 	
 	// CreateTaskDirectory 创建任务执行目录
 	CreateTaskDirectory(taskID string) (string, error)
@@ -31,12 +142,16 @@ type sandboxExecutor struct {
 	config        *config.AppConfig
 	logger        logger.Logger
 	envManager    environments.EnvironmentManager
+	cgroups       *cgroupManager
+	isolation     *isolation
+	mu            sync.Mutex
 	taskDirectories map[string]string
 }
 
 // NewSandboxExecutor 创建沙盒执行器实例
 func NewSandboxExecutor() SandboxExecutor {
 	return &sandboxExecutor{
+		isolation:       &isolation{},
 		taskDirectories: make(map[string]string),
 	}
 }
@@ -73,28 +188,487 @@ func (se *sandboxExecutor) Init(config *config.AppConfig, logger logger.Logger,
 		return fmt.Errorf("创建锁目录失败: %v", err)
 	}
 	
+	// 解析进程隔离配置，要求隔离但无法实施时拒绝启动
+	iso, err := resolveIsolation(config.Sandbox.Isolation)
+	if err != nil {
+		return fmt.Errorf("沙箱隔离无法生效: %v", err)
+	}
+	if err := iso.probe(tempPath); err != nil {
+		return fmt.Errorf("沙箱隔离无法生效: %v", err)
+	}
+	se.isolation = iso
+	if iso.enabled() {
+		se.logger.Info("沙箱隔离已启用",
+			zap.String("mode", iso.mode),
+			zap.Strings("readonly_paths", iso.readonlyPaths),
+			zap.Bool("credential", iso.credential != nil))
+	}
+	
+	// 检查配置的系统调用过滤策略能否在当前内核上安装
+	profiles := configuredSeccompProfiles(config)
+	for _, profile := range profiles {
+		if err := probeSeccomp(profile); err != nil {
+			return fmt.Errorf("系统调用过滤无法生效: %v", err)
+		}
+	}
+	if len(profiles) > 0 {
+		se.logger.Info("系统调用过滤已启用", zap.Strings("profiles", profiles))
+	}
+
+	// 准备任务cgroup，不可用时资源限制回退为setrlimit
+	cgroups, err := newCgroupManager(config.Sandbox.ResourceLimits.CgroupRoot)
+	if err != nil {
+		se.logger.Warn("cgroup v2 不可用，资源限制回退为setrlimit", zap.Error(err))
+	} else {
+		se.cgroups = cgroups
+	}
+	
+	if se.cgroups != nil {
+		if removed := se.cgroups.removeIdle(); removed > 0 {
+			se.logger.Info("已清理上次运行遗留的任务cgroup", zap.Int("count", removed))
+		}
+	}
+	
 	se.logger.Info("沙盒执行器初始化完成")
 	return nil
 }
 
 // ExecuteTask 执行任务
-// 参数: taskID 任务ID, taskDir 任务目录, cmd 命令, args 参数, envType 环境类型, envVersion 环境版本
-// 返回: 错误信息
-func (se *sandboxExecutor) ExecuteTask(taskID, taskDir, cmd string, args []string, envType, envVersion string) error {
+// 按 EnvType/EnvVersion 解析解释器，以任务目录为工作目录在独立进程组中启动进程，
+// 标准输出和标准错误写入 log/execution/<taskID>/ 下的独立文件。
+// 进程放入独立的 cgroup 限制内存、CPU 和进程数，cgroup 不可用时通过 setrlimit 限制。
+// 超过 sandbox.execution_timeout 或 ctx 被取消时终止整个进程组，已产生的输出保留
+// 参数: ctx 上下文, req 执行请求
+// 返回: 执行结果，错误信息（非零退出、内存超限、违反系统调用过滤策略、超时、取消时分别返回 ErrNonZeroExit、
+// ErrOutOfMemory、ErrSeccompViolation、ErrExecutionTimeout、ErrExecutionCancelled，结果仍然有效）
+func (se *sandboxExecutor) ExecuteTask(ctx context.Context, req ExecutionRequest) (*ExecutionResult, error) {
 	if se.config == nil {
-		return fmt.Errorf("沙盒执行器未初始化")
+		return nil, fmt.Errorf("沙盒执行器未初始化")
 	}
-	
-	se.logger.Info("开始执行任务")
-	
-	// 这里应该实现实际的任务执行逻辑
-	// 包括环境选择、沙盒隔离等
-	
-	// 示例实现：记录任务执行
-	se.logger.Info("任务执行完成")
+	taskID := req.TaskID
+	timeout := se.executionTimeout()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	req, detectedBy, err := se.detectRuntime(req)
+	if err != nil {
+		return nil, err
+	}
+	name, argv, runtimeDirs, runtimeEnv, release, err := se.resolveCommand(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	limits, err := resolveLimits(se.config, req.Limits)
+	if err != nil {
+		return nil, err
+	}
+
+	logDir := se.logDir(taskID, req.Attempt)
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建任务日志目录失败: %v", err)
+	}
+	result := &ExecutionResult{
+		TaskID:     taskID,
+		EnvType:    req.EnvType,
+		DetectedBy: detectedBy,
+		Command:    name,
+		Args:       argv,
+		ExitCode:   -1,
+		StdoutPath: filepath.Join(logDir, "stdout.log"),
+		StderrPath: filepath.Join(logDir, "stderr.log"),
+		Limits:     limits,
+	}
+
+	stdout, err := os.Create(result.StdoutPath)
+	if err != nil {
+		return nil, fmt.Errorf("创建标准输出文件失败: %v", err)
+	}
+	defer stdout.Close()
+	stderr, err := os.Create(result.StderrPath)
+	if err != nil {
+		return nil, fmt.Errorf("创建标准错误文件失败: %v", err)
+	}
+	defer stderr.Close()
+
+	process := exec.Command(name, argv...)
+	process.Dir = req.TaskDir
+	process.Env = se.taskEnv(req.TaskDir, runtimeEnv)
+	process.Stdout = stdout
+	process.Stderr = stderr
+	setProcessGroup(process)
+	var spec sandboxInitSpec
+	var cgroup *taskCgroup
+	result.LimitMode, cgroup = se.applyLimits(process, &spec, taskID, limits, timeout)
+	spec.Seccomp = resolveSeccompProfile(se.config, req.EnvType)
+	if err := se.prepareSandbox(process, &spec, req, runtimeDirs); err != nil {
+		if cgroup != nil {
+			cgroup.destroy()
+		}
+		return nil, err
+	}
+	if spec.Root != "" {
+		defer removeMountpoints(spec.Root, spec.mountpoints)
+	}
+	if result.LimitMode == LimitModeRlimit && !spec.wrapped {
+		result.LimitMode = LimitModeNone
+	}
+	result.Isolation = se.isolation.mode
+	result.NetworkIsolated = spec.Namespace != nil && !spec.Namespace.AllowNetwork
+	result.SeccompProfile = spec.Seccomp
+
+	se.logger.Info("开始执行任务",
+		zap.String("task_id", taskID),
+		zap.String("command", name),
+		zap.Strings("args", argv),
+		zap.String("env_type", req.EnvType),
+		zap.String("env_version", req.EnvVersion),
+		zap.String("limit_mode", result.LimitMode))
+
+	result.StartedAt = time.Now()
+	if err := process.Start(); err != nil {
+		if cgroup != nil {
+			cgroup.destroy()
+		}
+		return nil, fmt.Errorf("启动任务进程失败: %v", err)
+	}
+	result.PID = process.Process.Pid
+	if observer, ok := ctx.Value(processObserverKey{}).(func(pid int)); ok {
+		observer(result.PID)
+	}
+
+	exited := make(chan struct{})
+	terminated := make(chan struct{})
+	go se.terminateOnDone(ctx, taskID, result.PID, exited, terminated)
+
+	waitErr := process.Wait()
+	close(exited)
+	<-terminated
+	result.FinishedAt = time.Now()
+	result.Duration = result.FinishedAt.Sub(result.StartedAt)
+	if state := process.ProcessState; state != nil {
+		result.ExitCode = state.ExitCode()
+		if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			result.Signal = status.Signal().String()
+		} else if ok && spec.Namespace != nil && status.ExitStatus() > 128 {
+			// namespace 模式下初始化进程以 128+信号值转述任务命令被信号终止
+			result.Signal = syscall.Signal(status.ExitStatus() - 128).String()
+		}
+	}
+	if cgroup != nil {
+		result.Usage = cgroup.usage()
+		result.OOMKilled = result.Usage.OOMKills > 0
+		if err := cgroup.destroy(); err != nil {
+			se.logger.Warn("清理任务cgroup失败", zap.String("task_id", taskID), zap.Error(err))
+		}
+	}
+	switch ctxErr := ctx.Err(); {
+	case errors.Is(ctxErr, context.DeadlineExceeded):
+		result.Terminated = "timeout"
+	case ctxErr != nil:
+		result.Terminated = "cancelled"
+	}
+	if result.Terminated == "" && result.ExitCode != 0 {
+		switch {
+		case spec.Seccomp != "" && process.ProcessState != nil && killedBySeccomp(process.ProcessState):
+			result.FailureReason = FailureSeccompViolation
+		case result.OOMKilled:
+			result.FailureReason = FailureOutOfMemory
+		default:
+			result.FailureReason = FailureNonZeroExit
+		}
+	}
+	se.writeResult(logDir, result)
+
+	se.logger.Info("任务执行完成",
+		zap.String("task_id", taskID),
+		zap.Int("exit_code", result.ExitCode),
+		zap.String("signal", result.Signal),
+		zap.String("terminated", result.Terminated),
+		zap.Bool("oom_killed", result.OOMKilled),
+		zap.String("failure_reason", result.FailureReason),
+		zap.Int64("throttled_periods", result.Usage.ThrottledPeriods),
+		zap.Duration("duration", result.Duration))
+
+	switch result.Terminated {
+	case "timeout":
+		return result, ErrExecutionTimeout
+	case "cancelled":
+		return result, ErrExecutionCancelled
+	}
+	switch result.FailureReason {
+	case FailureSeccompViolation:
+		return result, fmt.Errorf("%w: 过滤策略 %s", ErrSeccompViolation, spec.Seccomp)
+	case FailureOutOfMemory:
+		return result, fmt.Errorf("%w: 内存上限 %d 字节", ErrOutOfMemory, limits.Memory)
+	case FailureNonZeroExit:
+		return result, fmt.Errorf("%w: 退出码 %d", ErrNonZeroExit, result.ExitCode)
+	}
+	if waitErr != nil {
+		return result, fmt.Errorf("等待任务进程失败: %v", waitErr)
+	}
+	return result, nil
+}
+
+// applyLimits 为任务进程应用资源限制
+// 优先将进程放入独立 cgroup，cgroup 不可用时在初始化参数中加入 setrlimit 设置
+// 参数: process 任务命令, spec 初始化参数, taskID 任务ID, limits 资源限制, timeout 执行超时时间
+// 返回: 实施方式，cgroup 模式下返回任务cgroup
+func (se *sandboxExecutor) applyLimits(process *exec.Cmd, spec *sandboxInitSpec, taskID string, limits ResourceLimits, timeout time.Duration) (string, *taskCgroup) {
+	if se.cgroups != nil {
+		cgroup, err := se.cgroups.create(taskID, limits)
+		if err == nil {
+			cgroup.attach(process)
+			return LimitModeCgroup, cgroup
+		}
+		se.logger.Warn("创建任务cgroup失败，回退为setrlimit", zap.String("task_id", taskID), zap.Error(err))
+	}
+
+	spec.Rlimits = rlimitsFor(limits, timeout)
+	if len(spec.Rlimits) == 0 {
+		return LimitModeNone, nil
+	}
+	return LimitModeRlimit, nil
+}
+
+// prepareSandbox 写入隔离设置，并在需要时改为经由沙盒初始化子进程启动任务
+// 启用隔离或系统调用过滤时无法经由初始化子进程启动会返回错误，仅有 setrlimit 设置时记录警告后直接启动
+// 参数: process 任务命令, spec 初始化参数, req 执行请求, runtimeDirs 运行环境目录
+func (se *sandboxExecutor) prepareSandbox(process *exec.Cmd, spec *sandboxInitSpec, req ExecutionRequest, runtimeDirs []string) error {
+	mountpoints, err := se.isolation.apply(spec, req.TaskDir, runtimeDirs, req.AllowNetwork)
+	if err != nil {
+		return err
+	}
+	if spec.Namespace != nil && req.AllowNetwork {
+		se.logger.Warn("审计: 任务在隔离环境中启用网络访问",
+			zap.String("task_id", req.TaskID),
+			zap.String("command", req.Command),
+			zap.Strings("args", req.Args))
+	}
+	spec.mountpoints = mountpoints
+	if !spec.needed() {
+		return nil
+	}
+	if err := wrapWithSandboxInit(process, *spec); err != nil {
+		if se.isolation.enabled() || spec.Seccomp != "" {
+			removeMountpoints(spec.Root, mountpoints)
+			return fmt.Errorf("无法启用沙箱隔离: %v", err)
+		}
+		se.logger.Warn("无法应用setrlimit资源限制", zap.Error(err))
+		return nil
+	}
+	spec.wrapped = true
 	return nil
 }
 
+// terminateOnDone 在 ctx 结束时终止任务进程组
+// 先发送 SIGTERM，进程在宽限期内退出或宽限期结束后再向整个进程组发送 SIGKILL，
+// 确保派生的子进程不会残留。任务进程自行结束时直接返回
+func (se *sandboxExecutor) terminateOnDone(ctx context.Context, taskID string, pid int, exited <-chan struct{}, terminated chan<- struct{}) {
+	defer close(terminated)
+
+	select {
+	case <-exited:
+		return
+	case <-ctx.Done():
+	}
+
+	grace := se.killGracePeriod()
+	se.logger.Warn("终止任务进程组",
+		zap.String("task_id", taskID),
+		zap.Int("pid", pid),
+		zap.Duration("grace_period", grace),
+		zap.Error(ctx.Err()))
+	if err := signalProcessGroup(pid, syscall.SIGTERM); err != nil {
+		se.logger.Error("发送SIGTERM失败", zap.String("task_id", taskID), zap.Error(err))
+	}
+
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case <-exited:
+	case <-timer.C:
+		se.logger.Warn("任务进程未在宽限期内退出，强制终止", zap.String("task_id", taskID), zap.Int("pid", pid))
+	}
+	if err := signalProcessGroup(pid, syscall.SIGKILL); err != nil {
+		se.logger.Error("发送SIGKILL失败", zap.String("task_id", taskID), zap.Error(err))
+	}
+}
+
+// executionTimeout 任务执行超时时间
+// 优先使用 sandbox.execution_timeout，未配置时使用 internal.service.sandbox_timeout（秒），均未配置时不设超时
+func (se *sandboxExecutor) executionTimeout() time.Duration {
+	if value := se.config.Sandbox.ExecutionTimeout; value != "" {
+		if timeout, err := time.ParseDuration(value); err == nil {
+			return timeout
+		}
+		se.logger.Warn("沙箱执行超时配置不合法", zap.String("execution_timeout", value))
+	}
+	if seconds := se.config.Internal.Service.SandboxTimeout; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return 0
+}
+
+// killGracePeriod SIGTERM 与 SIGKILL 之间的宽限期
+func (se *sandboxExecutor) killGracePeriod() time.Duration {
+	if grace, err := time.ParseDuration(se.config.Sandbox.KillGracePeriod); err == nil && grace >= 0 {
+		return grace
+	}
+	return defaultKillGracePeriod
+}
+
+// detectRuntime 请求中未指定运行时或命令时，根据任务目录中的文件自动识别
+// 指定了运行时但没有命令时只查找入口文件；未指定运行时且识别不出时返回错误，不在宿主上直接执行命令
+// 参数: req 执行请求
+// 返回: 补全运行时和命令后的请求，识别依据，错误信息
+func (se *sandboxExecutor) detectRuntime(req ExecutionRequest) (ExecutionRequest, string, error) {
+	if req.EnvType != "" {
+		if req.Command != "" {
+			return req, "", nil
+		}
+		entrypoint, err := environments.DetectEntrypoint(req.EnvType, req.TaskDir)
+		if err != nil {
+			return req, "", err
+		}
+		req.Command = entrypoint
+		return req, "entrypoint", nil
+	}
+
+	detected, err := environments.DetectRuntime(req.TaskDir, req.Command)
+	if err != nil {
+		return req, "", err
+	}
+	if detected == nil {
+		return req, "", fmt.Errorf("%w: 请指定 env_type", ErrRuntimeNotDetected)
+	}
+	se.logger.Info("自动识别任务运行时",
+		zap.String("task_id", req.TaskID),
+		zap.String("env_type", detected.EnvType),
+		zap.String("entrypoint", detected.Entrypoint),
+		zap.String("reason", detected.Reason))
+	req.EnvType = detected.EnvType
+	req.Command = detected.Entrypoint
+	return req, detected.Reason, nil
+}
+
+// resolveCommand 根据环境类型解析实际执行的程序和参数
+// 环境类型对应已注册的运行时，Python 任务目录中存在 requirements.txt 时使用安装了这些依赖的虚拟环境
+// 参数: ctx 上下文, req 执行请求（已由 detectRuntime 补全运行时）
+// 返回: 程序路径，参数列表，需要挂载到沙箱中的运行环境目录，运行时的环境变量（含 PATH），
+// 任务结束后释放运行环境占用的函数，错误信息
+func (se *sandboxExecutor) resolveCommand(ctx context.Context, req ExecutionRequest) (string, []string, []string, []string, func(), error) {
+	if req.Command == "" {
+		return "", nil, nil, nil, nil, fmt.Errorf("执行命令不能为空")
+	}
+
+	rt, err := se.envManager.GetRuntime(req.EnvType)
+	if err != nil {
+		return "", nil, nil, nil, nil, err
+	}
+	// 未指定版本时使用运行时的默认版本，占用期间该版本不能被卸载
+	version, envPath, releaseRuntime, err := se.envManager.ResolveRuntime(req.EnvType, req.EnvVersion)
+	if err != nil {
+		return "", nil, nil, nil, nil, err
+	}
+	releases := []func(){releaseRuntime}
+	release := func() {
+		for _, r := range releases {
+			r()
+		}
+	}
+	var runtimeDirs []string
+	if rt.Versioned() {
+		runtimeDirs = append(runtimeDirs, envPath)
+	}
+	env := rt.Environ(envPath)
+
+	if req.EnvType == "python" {
+		requirements := filepath.Join(req.TaskDir, pythonRequirementsFile)
+		if _, err := os.Stat(requirements); err == nil {
+			venvPath, releaseVenv, err := se.envManager.PreparePythonVenv(ctx, version, requirements)
+			if err != nil {
+				release()
+				return "", nil, nil, nil, nil, fmt.Errorf("准备Python依赖环境失败: %v", err)
+			}
+			releases = append(releases, releaseVenv)
+			runtimeDirs = append(runtimeDirs, venvPath)
+			envPath = venvPath
+			env = append(env, "VIRTUAL_ENV="+venvPath)
+		}
+	}
+
+	executable, err := rt.Executable(envPath)
+	if err != nil {
+		release()
+		return "", nil, nil, nil, nil, fmt.Errorf("%s版本 %s 中未找到可执行文件: %v", req.EnvType, version, err)
+	}
+	if executable != "" {
+		env = append(env, "PATH="+filepath.Dir(executable)+string(os.PathListSeparator)+defaultTaskPath)
+	}
+	name, argv := rt.CommandLine(executable, req.Command, req.Args)
+	return name, argv, runtimeDirs, env, release, nil
+}
+
+// taskEnv 构建任务进程的环境变量
+// 任务不继承服务进程的环境变量（其中可能有数据库路径、凭据等），只设置 PATH、HOME、LANG 和运行时自身的变量。
+// 以任务目录为根运行时 HOME 为新根目录 /，否则为任务目录
+// 参数: taskDir 任务目录, runtimeEnv 运行时的环境变量，同名时覆盖默认值
+func (se *sandboxExecutor) taskEnv(taskDir string, runtimeEnv []string) []string {
+	home := taskDir
+	if abs, err := filepath.Abs(taskDir); err == nil {
+		home = abs
+	}
+	if se.isolation.rooted() {
+		home = "/"
+	}
+	lang := os.Getenv("LANG")
+	if lang == "" {
+		lang = defaultTaskLang
+	}
+
+	env := []string{"PATH=" + defaultTaskPath, "HOME=" + home, "LANG=" + lang}
+	for _, entry := range runtimeEnv {
+		key, _, _ := strings.Cut(entry, "=")
+		replaced := false
+		for i, existing := range env {
+			if strings.HasPrefix(existing, key+"=") {
+				env[i] = entry
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			env = append(env, entry)
+		}
+	}
+	return env
+}
+
+// logDir 任务的日志目录，attempt 大于 0 时为该次执行的子目录 attempt-<n>
+func (se *sandboxExecutor) logDir(taskID string, attempt int) string {
+	logDir := filepath.Join(se.config.LoggerConf.BasePath, "execution", taskID)
+	if attempt > 0 {
+		logDir = filepath.Join(logDir, fmt.Sprintf("attempt-%d", attempt))
+	}
+	return logDir
+}
+
+// writeResult 将执行结果写入任务日志目录，失败只记录日志
+func (se *sandboxExecutor) writeResult(logDir string, result *ExecutionResult) {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		se.logger.Error("序列化执行结果失败", zap.String("task_id", result.TaskID), zap.Error(err))
+		return
+	}
+	if err := os.WriteFile(filepath.Join(logDir, "result.json"), data, 0644); err != nil {
+		se.logger.Error("写入执行结果失败", zap.String("task_id", result.TaskID), zap.Error(err))
+	}
+}
+
 // CreateTaskDirectory 创建任务执行目录
 // 参数: taskID 任务ID
 // 返回: 目录路径，错误信息
@@ -110,7 +684,9 @@ func (se *sandboxExecutor) CreateTaskDirectory(taskID string) (string, error) {
 	}
 	
 	// 记录任务目录
+	se.mu.Lock()
 	se.taskDirectories[taskID] = taskDir
+	se.mu.Unlock()
 	
 	se.logger.Info("创建任务目录")
 	
@@ -125,7 +701,9 @@ func (se *sandboxExecutor) CleanupTaskDirectory(taskID string) error {
 		return fmt.Errorf("沙盒执行器未初始化")
 	}
 	
+	se.mu.Lock()
 	taskDir, exists := se.taskDirectories[taskID]
+	se.mu.Unlock()
 	if !exists {
 		return fmt.Errorf("任务目录不存在: %s", taskID)
 	}
@@ -136,7 +714,9 @@ func (se *sandboxExecutor) CleanupTaskDirectory(taskID string) error {
 	}
 	
 	// 从记录中移除
+	se.mu.Lock()
 	delete(se.taskDirectories, taskID)
+	se.mu.Unlock()
 	
 	se.logger.Info("清理任务目录")
 	
@@ -0,0 +1,424 @@
+//go:build linux
+
+package execution
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// RunSandboxInit 沙盒初始化子进程入口
+// 当前进程由沙盒执行器作为初始化子进程启动时，完成隔离和资源限制设置后替换为任务命令，不会返回；
+// 普通启动时直接返回。必须在 main 函数最开始调用
+func RunSandboxInit() {
+	raw, ok := os.LookupEnv(sandboxInitEnv)
+	if !ok {
+		return
+	}
+	os.Unsetenv(sandboxInitEnv)
+
+	if err := runSandboxInit(raw); err != nil {
+		fmt.Fprintf(os.Stderr, "沙盒初始化失败: %v\n", err)
+		os.Exit(sandboxInitExitCode)
+	}
+	// 仅探测模式会执行到这里
+	os.Exit(0)
+}
+
+// runSandboxInit 应用初始化参数并执行任务命令，成功执行命令时不返回
+func runSandboxInit(raw string) error {
+	var spec sandboxInitSpec
+	if err := json.Unmarshal([]byte(raw), &spec); err != nil {
+		return fmt.Errorf("解析初始化参数失败: %v", err)
+	}
+	// 能力集按线程生效，放弃能力和 execve 必须在同一线程上进行
+	runtime.LockOSThread()
+
+	if spec.Root != "" {
+		if err := enterRoot(spec.Root, spec.Mounts, spec.Namespace); err != nil {
+			return err
+		}
+	}
+
+	for _, limit := range spec.Rlimits {
+		rlimit := unix.Rlimit{Cur: limit.Value, Max: limit.Value}
+		if err := unix.Setrlimit(limit.Resource, &rlimit); err != nil {
+			return fmt.Errorf("设置资源限制 %d 失败: %v", limit.Resource, err)
+		}
+	}
+
+	// 以任务目录为根或在命名空间中运行时，任务不保留任何能力：
+	// namespace 模式下任务是命名空间内的 root，保留能力即可重新挂载或逃出根目录，不能只依赖系统调用过滤策略
+	confined := spec.Root != "" || spec.Namespace != nil
+	if confined {
+		if err := dropBoundingCapabilities(); err != nil {
+			return err
+		}
+	}
+
+	// 切换用户必须在挂载和设置资源限制之后进行，之后不再具备相应权限
+	if spec.Credential != nil {
+		if err := dropPrivileges(spec.Credential); err != nil {
+			return err
+		}
+	}
+	if confined {
+		if err := clearCapabilities(); err != nil {
+			return err
+		}
+	}
+
+	// 过滤策略最后安装，此前的挂载、chroot、切换用户等调用不受限制
+	if spec.Seccomp != "" {
+		if err := installSeccomp(spec.Seccomp); err != nil {
+			return err
+		}
+	}
+
+	if spec.Probe {
+		return nil
+	}
+	// 新 PID 命名空间中当前进程是 1 号进程，内核不向其投递未设置处理函数的信号，
+	// 任务命令不能直接替换它，否则收不到 SIGTERM，遗留的孤儿进程也无人回收
+	if spec.Namespace != nil {
+		return superviseTask(spec)
+	}
+	if err := syscall.Exec(spec.Path, spec.Args, spec.Env); err != nil {
+		return fmt.Errorf("执行 %s 失败: %v", spec.Path, err)
+	}
+	return nil
+}
+
+// forwardedSignals 1 号进程转发给任务命令的信号
+var forwardedSignals = []os.Signal{syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGUSR1, syscall.SIGUSR2}
+
+// superviseTask 作为 PID 命名空间中的 1 号进程运行任务命令，任务命令结束后以其退出状态退出，不返回
+// 任务命令在自己的进程组中运行：执行器向初始化进程的进程组发送的信号只到达初始化进程，
+// 再由它转发给任务的进程组，任务不会重复收到同一信号；SIGKILL 终止 1 号进程时内核终止命名空间内的全部进程。
+// 期间回收任务遗留的孤儿进程。任务被信号终止时以 128+信号值退出，1 号进程无法用同一信号终止自身
+func superviseTask(spec sandboxInitSpec) error {
+	// 先登记信号再启动任务，避免错过任务立即退出时的 SIGCHLD
+	signals := make(chan os.Signal, len(forwardedSignals))
+	signal.Notify(signals, forwardedSignals...)
+	children := make(chan os.Signal, 1)
+	signal.Notify(children, syscall.SIGCHLD)
+
+	// 在锁定的线程上 fork，任务命令继承该线程已放弃的能力和安装的过滤策略
+	pid, err := syscall.ForkExec(spec.Path, spec.Args, &syscall.ProcAttr{
+		Env:   spec.Env,
+		Files: []uintptr{0, 1, 2},
+		Sys:   &syscall.SysProcAttr{Setpgid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("执行 %s 失败: %v", spec.Path, err)
+	}
+
+	for {
+		select {
+		case sig := <-signals:
+			syscall.Kill(-pid, sig.(syscall.Signal))
+		case <-children:
+			for {
+				var status syscall.WaitStatus
+				reaped, err := syscall.Wait4(-1, &status, syscall.WNOHANG, nil)
+				if err == syscall.EINTR {
+					continue
+				}
+				if err != nil || reaped <= 0 {
+					break
+				}
+				if reaped != pid {
+					continue
+				}
+				if status.Signaled() {
+					os.Exit(128 + int(status.Signal()))
+				}
+				os.Exit(status.ExitStatus())
+			}
+		}
+	}
+}
+
+// enterRoot 在私有挂载命名空间中绑定挂载指定路径并以 pivot_root 切换到 root
+// 调用方需以 CLONE_NEWNS 启动当前进程，挂载只在该命名空间内可见，进程结束后自动释放。
+// 原根目录切换后立即卸载，任务无法像 chroot 那样通过目录描述符或嵌套 chroot 回到宿主根目录。
+// namespace 模式下额外挂载私有的 /tmp 和当前 PID 命名空间的 /proc
+func enterRoot(root string, mounts []mountSpec, ns *namespaceSpec) error {
+	// 挂载传播设为私有，避免绑定挂载泄漏到宿主命名空间
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("设置挂载传播失败: %v", err)
+	}
+	// pivot_root 要求新根目录是挂载点，先把任务目录绑定挂载到自身
+	if err := unix.Mount(root, root, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("绑定挂载根目录 %s 失败: %v", root, err)
+	}
+
+	for _, m := range mounts {
+		target := filepath.Join(root, m.Target)
+		if err := createMountpoint(m.Source, target); err != nil {
+			return err
+		}
+		if err := unix.Mount(m.Source, target, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("绑定挂载 %s 失败: %v", m.Source, err)
+		}
+		if m.ReadOnly {
+			// 用户命名空间中重新挂载时必须保留源挂载已有的 nodev/noexec 等标志
+			flags := uintptr(unix.MS_BIND|unix.MS_REMOUNT|unix.MS_RDONLY|unix.MS_NOSUID) | lockedMountFlags(target)
+			if err := unix.Mount("", target, "", flags, ""); err != nil {
+				return fmt.Errorf("只读重新挂载 %s 失败: %v", m.Source, err)
+			}
+		}
+	}
+
+	if ns != nil {
+		tmp := filepath.Join(root, "tmp")
+		if err := os.MkdirAll(tmp, 0755); err != nil {
+			return fmt.Errorf("创建挂载点 %s 失败: %v", tmp, err)
+		}
+		options := "mode=1777"
+		if ns.TmpSize > 0 {
+			options = fmt.Sprintf("mode=1777,size=%d", ns.TmpSize)
+		}
+		if err := unix.Mount("tmpfs", tmp, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, options); err != nil {
+			return fmt.Errorf("挂载私有 /tmp 失败: %v", err)
+		}
+
+		proc := filepath.Join(root, "proc")
+		if err := os.MkdirAll(proc, 0555); err != nil {
+			return fmt.Errorf("创建挂载点 %s 失败: %v", proc, err)
+		}
+		// 宿主 /proc 被部分遮盖（如容器内）时内核不允许挂载新的 proc，此时任务中没有 /proc
+		if err := unix.Mount("proc", proc, "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, ""); err != nil {
+			fmt.Fprintf(os.Stderr, "沙盒初始化: 挂载 /proc 失败: %v\n", err)
+		}
+	}
+
+	// new_root 与 put_old 相同：原根目录叠放在新根目录之下，随后卸载
+	if err := os.Chdir(root); err != nil {
+		return fmt.Errorf("切换工作目录失败: %v", err)
+	}
+	if err := unix.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("pivot_root 到 %s 失败: %v", root, err)
+	}
+	if err := unix.Unmount(".", unix.MNT_DETACH); err != nil {
+		return fmt.Errorf("卸载原根目录失败: %v", err)
+	}
+	if err := os.Chdir("/"); err != nil {
+		return fmt.Errorf("切换工作目录失败: %v", err)
+	}
+	return nil
+}
+
+// lockedMountFlags 读取挂载点当前的 nodev/noexec/noatime 等标志
+func lockedMountFlags(path string) uintptr {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return 0
+	}
+	var flags uintptr
+	for _, f := range []struct{ st, ms int64 }{
+		{unix.ST_NODEV, unix.MS_NODEV},
+		{unix.ST_NOEXEC, unix.MS_NOEXEC},
+		{unix.ST_NOATIME, unix.MS_NOATIME},
+		{unix.ST_NODIRATIME, unix.MS_NODIRATIME},
+		{unix.ST_RELATIME, unix.MS_RELATIME},
+	} {
+		if int64(stat.Flags)&f.st != 0 {
+			flags |= uintptr(f.ms)
+		}
+	}
+	return flags
+}
+
+// createMountpoint 按挂载源类型创建目录或空文件作为挂载点
+func createMountpoint(source, target string) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return fmt.Errorf("挂载源 %s 不可用: %v", source, err)
+	}
+	if info.IsDir() {
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return fmt.Errorf("创建挂载点 %s 失败: %v", target, err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("创建挂载点 %s 失败: %v", target, err)
+	}
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_RDONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("创建挂载点 %s 失败: %v", target, err)
+	}
+	return file.Close()
+}
+
+// removeMountpoint 删除 root 下的一个空挂载点及其空的上级目录
+// 从 root 的目录描述符开始用 openat(O_NOFOLLOW) 逐级打开上级目录，再用 unlinkat 删除，
+// 任何一级是符号链接时打开失败并放弃清理，不会跟随任务创建的链接删除宿主上的文件
+// 参数: root 任务根目录, components 挂载点相对 root 的各级路径
+func removeMountpoint(root string, components []string) {
+	const dirFlags = unix.O_RDONLY | unix.O_DIRECTORY | unix.O_NOFOLLOW | unix.O_CLOEXEC
+	rootFd, err := unix.Open(root, dirFlags, 0)
+	if err != nil {
+		return
+	}
+	// dirs[i] 为 components[i] 所在目录的描述符
+	dirs := []int{rootFd}
+	defer func() {
+		for _, fd := range dirs {
+			unix.Close(fd)
+		}
+	}()
+	for _, name := range components[:len(components)-1] {
+		fd, err := unix.Openat(dirs[len(dirs)-1], name, dirFlags, 0)
+		if err != nil {
+			return
+		}
+		dirs = append(dirs, fd)
+	}
+
+	last := len(components) - 1
+	var stat unix.Stat_t
+	if err := unix.Fstatat(dirs[last], components[last], &stat, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		return
+	}
+	switch stat.Mode & unix.S_IFMT {
+	case unix.S_IFDIR:
+		if unix.Unlinkat(dirs[last], components[last], unix.AT_REMOVEDIR) != nil {
+			return
+		}
+	case unix.S_IFREG:
+		// 非空文件已被任务写入内容，不做清理
+		if stat.Size > 0 || unix.Unlinkat(dirs[last], components[last], 0) != nil {
+			return
+		}
+	default:
+		return
+	}
+	for i := last - 1; i >= 0; i-- {
+		if unix.Unlinkat(dirs[i], components[i], unix.AT_REMOVEDIR) != nil {
+			return
+		}
+	}
+}
+
+// dropBoundingCapabilities 清空能力边界集和 ambient 能力集，任务命令执行后无法再获得任何能力
+// 只影响之后的 execve，当前进程仍保留切换用户所需的能力
+func dropBoundingCapabilities() error {
+	for c := 0; c <= unix.CAP_LAST_CAP; c++ {
+		if err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(c), 0, 0, 0); err != nil {
+			// 内核支持的能力少于头文件中定义的能力
+			if err == unix.EINVAL {
+				break
+			}
+			return fmt.Errorf("清空能力边界集失败: %v", err)
+		}
+	}
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil && err != unix.EINVAL {
+		return fmt.Errorf("清空 ambient 能力集失败: %v", err)
+	}
+	return nil
+}
+
+// clearCapabilities 清空当前线程的 effective、permitted 和 inheritable 能力集
+func clearCapabilities() error {
+	header := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capset(&header, &data[0]); err != nil {
+		return fmt.Errorf("清空能力集失败: %v", err)
+	}
+	return nil
+}
+
+// dropPrivileges 清空附加组并切换到指定用户和用户组
+func dropPrivileges(credential *credentialSpec) error {
+	if err := syscall.Setgroups([]int{}); err != nil {
+		return fmt.Errorf("清空附加用户组失败: %v", err)
+	}
+	if err := syscall.Setgid(int(credential.GID)); err != nil {
+		return fmt.Errorf("切换用户组 %d 失败: %v", credential.GID, err)
+	}
+	if err := syscall.Setuid(int(credential.UID)); err != nil {
+		return fmt.Errorf("切换用户 %d 失败: %v", credential.UID, err)
+	}
+	return nil
+}
+
+// wrapWithSandboxInit 将任务命令改为经由沙盒初始化子进程启动
+// 参数: cmd 任务命令, spec 初始化参数（Path/Args/Env 由 cmd 填充）
+// 返回: 错误信息
+func wrapWithSandboxInit(cmd *exec.Cmd, spec sandboxInitSpec) error {
+	if cmd.Err != nil {
+		return cmd.Err
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("获取服务程序路径失败: %v", err)
+	}
+
+	spec.Path = cmd.Path
+	spec.Args = cmd.Args
+	spec.Env = cmd.Env
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return fmt.Errorf("序列化初始化参数失败: %v", err)
+	}
+
+	// 初始化子进程只接收初始化参数，任务命令的环境变量随参数传递，不经过服务进程的环境
+	cmd.Env = []string{sandboxInitEnv + "=" + string(data)}
+	cmd.Path = self
+	cmd.Args = []string{"file-flow-sandbox-init"}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	if spec.Root != "" {
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNS
+	}
+	if ns := spec.Namespace; ns != nil {
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC
+		if !ns.AllowNetwork {
+			cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNET
+		}
+		cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: int(ns.UID), Size: 1}}
+		cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: int(ns.GID), Size: 1}}
+		cmd.SysProcAttr.GidMappingsEnableSetgroups = false
+		// 子进程需在映射写入后切换为命名空间内的 root，否则仍是未映射的宿主身份，不具备命名空间内的权限；
+		// 完成挂载后、执行任务命令前放弃全部能力
+		cmd.SysProcAttr.Credential = &syscall.Credential{Uid: 0, Gid: 0, NoSetGroups: true}
+	}
+	return nil
+}
+
+// checkIsolationSupported 检查当前进程能否实施 chroot 和切换用户
+func checkIsolationSupported() error {
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("chroot 和切换用户需要以 root 身份运行服务")
+	}
+	return nil
+}
+
+// rlimitsFor 将资源限制转换为 setrlimit 设置
+// RLIMIT_AS 限制虚拟内存，RLIMIT_CPU 按超时时间内用满核数计算，
+// RLIMIT_NPROC 按运行用户计数，与服务进程使用同一用户时会包含服务自身的线程
+func rlimitsFor(limits ResourceLimits, timeout time.Duration) []rlimitSpec {
+	var rlimits []rlimitSpec
+	if limits.Memory > 0 {
+		rlimits = append(rlimits, rlimitSpec{Resource: unix.RLIMIT_AS, Value: uint64(limits.Memory)})
+	}
+	if seconds := cpuTimeLimit(limits, timeout); seconds > 0 {
+		rlimits = append(rlimits, rlimitSpec{Resource: unix.RLIMIT_CPU, Value: seconds})
+	}
+	if limits.MaxPids > 0 {
+		rlimits = append(rlimits, rlimitSpec{Resource: unix.RLIMIT_NPROC, Value: uint64(limits.MaxPids)})
+	}
+	return rlimits
+}
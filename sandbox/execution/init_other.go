@@ -0,0 +1,30 @@
+//go:build !linux
+
+package execution
+
+import (
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// RunSandboxInit 非 Linux 平台不使用沙盒初始化子进程，直接返回
+func RunSandboxInit() {}
+
+// wrapWithSandboxInit 非 Linux 平台不支持沙盒初始化子进程
+func wrapWithSandboxInit(cmd *exec.Cmd, spec sandboxInitSpec) error {
+	return fmt.Errorf("当前平台不支持沙盒初始化子进程")
+}
+
+// checkIsolationSupported 非 Linux 平台不支持 chroot 和切换用户
+func checkIsolationSupported() error {
+	return fmt.Errorf("当前平台不支持 chroot 和切换用户")
+}
+
+// rlimitsFor 非 Linux 平台不支持 setrlimit
+func rlimitsFor(limits ResourceLimits, timeout time.Duration) []rlimitSpec {
+	return nil
+}
+
+// removeMountpoint 非 Linux 平台不会创建挂载点，无需清理
+func removeMountpoint(root string, components []string) {}
@@ -0,0 +1,277 @@
+package execution
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"file-flow-service/config"
+)
+
+// 隔离模式
+const (
+	isolationNone      = "none"
+	isolationChroot    = "chroot"
+	isolationNamespace = "namespace"
+)
+
+// isolation 解析后的进程隔离设置
+type isolation struct {
+	// mode 隔离模式（none/chroot/namespace）
+	mode string
+	// credential 任务进程的运行身份，为空时沿用服务进程身份
+	credential *credentialSpec
+	// namespace namespace 模式的设置
+	namespace *namespaceSpec
+	// readonlyPaths 以任务目录为根时只读绑定挂载的系统路径（绝对路径，均已确认存在）
+	readonlyPaths []string
+}
+
+// enabled 是否启用了任何隔离
+func (iso *isolation) enabled() bool {
+	return iso.rooted() || iso.credential != nil
+}
+
+// rooted 是否以任务目录为根目录运行
+func (iso *isolation) rooted() bool {
+	return iso.mode == isolationChroot || iso.mode == isolationNamespace
+}
+
+// resolveIsolation 解析 sandbox.isolation 配置
+// 未配置 mode 时按 chroot 开关选择 chroot 或 none 模式
+// 参数: cfg 隔离配置
+// 返回: 隔离设置，用户/用户组不存在或当前环境无法实施隔离时返回错误
+func resolveIsolation(cfg config.Isolation) (*isolation, error) {
+	iso := &isolation{mode: cfg.Mode}
+	if iso.mode == "" {
+		iso.mode = isolationNone
+		if cfg.Chroot {
+			iso.mode = isolationChroot
+		}
+	}
+
+	if cfg.User != "" {
+		uid, gid, err := lookupUser(cfg.User)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Group != "" {
+			if gid, err = lookupGroup(cfg.Group); err != nil {
+				return nil, err
+			}
+		}
+		iso.credential = &credentialSpec{UID: uid, GID: gid}
+	} else if cfg.Group != "" {
+		return nil, fmt.Errorf("配置了沙箱用户组 %q 但未配置用户", cfg.Group)
+	}
+
+	if iso.mode == isolationNamespace {
+		// 命名空间内的 root 映射为沙箱用户，未配置用户时映射为服务进程自身
+		ns := &namespaceSpec{UID: uint32(os.Geteuid()), GID: uint32(os.Getegid())}
+		if iso.credential != nil {
+			ns.UID, ns.GID = iso.credential.UID, iso.credential.GID
+		}
+		if ns.UID == 0 {
+			return nil, fmt.Errorf("namespace 模式需要配置非 root 的沙箱用户")
+		}
+		if cfg.TmpSize != "" {
+			size, err := config.ParseSize(cfg.TmpSize)
+			if err != nil {
+				return nil, fmt.Errorf("沙箱临时目录大小 %q 格式不合法: %v", cfg.TmpSize, err)
+			}
+			ns.TmpSize = size
+		}
+		iso.namespace = ns
+	}
+
+	if !iso.enabled() {
+		return iso, nil
+	}
+	// chroot 和切换到其他用户需要 root 权限；namespace 模式映射为服务自身用户时无需 root
+	if iso.mode == isolationChroot || (iso.credential != nil && int(iso.credential.UID) != os.Geteuid()) {
+		if err := checkIsolationSupported(); err != nil {
+			return nil, err
+		}
+	}
+
+	if iso.rooted() {
+		for _, path := range cfg.ReadonlyPaths {
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return nil, fmt.Errorf("只读路径 %q 不合法: %v", path, err)
+			}
+			if _, err := os.Stat(abs); err != nil {
+				continue
+			}
+			iso.readonlyPaths = append(iso.readonlyPaths, abs)
+		}
+	}
+	return iso, nil
+}
+
+// lookupUser 解析用户名或数字用户ID
+// 返回: 用户ID，用户的主用户组ID
+func lookupUser(name string) (uint32, uint32, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		if _, numErr := strconv.ParseUint(name, 10, 32); numErr != nil {
+			return 0, 0, fmt.Errorf("沙箱用户 %q 不存在: %v", name, err)
+		}
+		if u, err = user.LookupId(name); err != nil {
+			return 0, 0, fmt.Errorf("沙箱用户 %q 不存在: %v", name, err)
+		}
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("沙箱用户 %q 的UID %q 不合法", name, u.Uid)
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("沙箱用户 %q 的GID %q 不合法", name, u.Gid)
+	}
+	return uint32(uid), uint32(gid), nil
+}
+
+// lookupGroup 解析用户组名或数字用户组ID
+func lookupGroup(name string) (uint32, error) {
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		if _, numErr := strconv.ParseUint(name, 10, 32); numErr != nil {
+			return 0, fmt.Errorf("沙箱用户组 %q 不存在: %v", name, err)
+		}
+		if g, err = user.LookupGroupId(name); err != nil {
+			return 0, fmt.Errorf("沙箱用户组 %q 不存在: %v", name, err)
+		}
+	}
+	gid, err := strconv.ParseUint(g.Gid, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("沙箱用户组 %q 的GID %q 不合法", name, g.Gid)
+	}
+	return uint32(gid), nil
+}
+
+// apply 将隔离设置写入初始化参数
+// 参数: spec 初始化参数, taskDir 任务目录, runtimeDirs 运行环境目录（可为空）,
+// allowNetwork namespace 模式下是否保留网络访问
+// 返回: 以任务目录为根时新建的挂载点，任务结束后需调用 removeMountpoints 清理
+func (iso *isolation) apply(spec *sandboxInitSpec, taskDir string, runtimeDirs []string, allowNetwork bool) ([]string, error) {
+	owner := iso.credential
+	if iso.namespace != nil {
+		// 命名空间内以映射后的 root 运行，不再切换用户
+		ns := *iso.namespace
+		ns.AllowNetwork = allowNetwork
+		spec.Namespace = &ns
+		owner = &credentialSpec{UID: ns.UID, GID: ns.GID}
+	} else if iso.credential != nil {
+		spec.Credential = iso.credential
+	}
+	if owner != nil && int(owner.UID) != os.Geteuid() {
+		if err := chownTree(taskDir, int(owner.UID), int(owner.GID)); err != nil {
+			return nil, fmt.Errorf("设置任务目录属主失败: %v", err)
+		}
+	}
+	if !iso.rooted() {
+		return nil, nil
+	}
+
+	root, err := filepath.Abs(taskDir)
+	if err != nil {
+		return nil, fmt.Errorf("任务目录 %q 不合法: %v", taskDir, err)
+	}
+	spec.Root = root
+
+	paths := append([]string(nil), iso.readonlyPaths...)
+	for _, runtimeDir := range runtimeDirs {
+		abs, err := filepath.Abs(runtimeDir)
+		if err != nil {
+			return nil, fmt.Errorf("运行环境目录 %q 不合法: %v", runtimeDir, err)
+		}
+		paths = append(paths, abs)
+	}
+
+	var mountpoints []string
+	for _, path := range paths {
+		spec.Mounts = append(spec.Mounts, mountSpec{Source: path, Target: path, ReadOnly: true})
+		mountpoints = appendMountpoint(mountpoints, filepath.Join(root, path))
+	}
+	if spec.Namespace != nil {
+		// 私有 /tmp 和 /proc 由初始化子进程挂载
+		mountpoints = appendMountpoint(mountpoints, filepath.Join(root, "tmp"))
+		mountpoints = appendMountpoint(mountpoints, filepath.Join(root, "proc"))
+	}
+	return mountpoints, nil
+}
+
+// probe 以探测模式启动初始化子进程，确认隔离设置在当前环境中能够生效
+// 参数: tempDir 用作探测根目录的父目录
+func (iso *isolation) probe(tempDir string) error {
+	if !iso.enabled() {
+		return nil
+	}
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return fmt.Errorf("创建临时目录失败: %v", err)
+	}
+	root, err := os.MkdirTemp(tempDir, "isolation-probe-")
+	if err != nil {
+		return fmt.Errorf("创建探测目录失败: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	spec := sandboxInitSpec{Probe: true}
+	mountpoints, err := iso.apply(&spec, root, nil, false)
+	if err != nil {
+		return err
+	}
+	defer removeMountpoints(root, mountpoints)
+
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("获取服务程序路径失败: %v", err)
+	}
+	cmd := exec.Command(self)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := wrapWithSandboxInit(cmd, spec); err != nil {
+		return err
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("隔离探测失败: %v: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return nil
+}
+
+// appendMountpoint 挂载点尚不存在时记录下来，已存在的路径属于任务自身，不做清理
+func appendMountpoint(mountpoints []string, target string) []string {
+	if _, err := os.Lstat(target); os.IsNotExist(err) {
+		return append(mountpoints, target)
+	}
+	return mountpoints
+}
+
+// removeMountpoints 删除为绑定挂载创建的空挂载点及其空的上级目录
+// 挂载只存在于任务的挂载命名空间中，任务结束后挂载点在宿主上是普通的空目录或空文件。
+// 任务对根目录有写权限，可能把上级目录替换为符号链接，逐级删除见 removeMountpoint
+func removeMountpoints(root string, mountpoints []string) {
+	for i := len(mountpoints) - 1; i >= 0; i-- {
+		rel, err := filepath.Rel(root, mountpoints[i])
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		removeMountpoint(root, strings.Split(rel, string(filepath.Separator)))
+	}
+}
+
+// chownTree 递归修改目录及其内容的属主
+func chownTree(dir string, uid, gid int) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(path, uid, gid)
+	})
+}
@@ -0,0 +1,93 @@
+package execution
+
+import (
+	"fmt"
+	"time"
+
+	"file-flow-service/config"
+)
+
+// 资源限制的实施方式
+const (
+	LimitModeCgroup = "cgroup"
+	LimitModeRlimit = "rlimit"
+	LimitModeNone   = "none"
+)
+
+// ResourceLimits 单个任务的资源限制，字段为 0 表示不限制
+type ResourceLimits struct {
+	// Memory 内存上限（字节）
+	Memory int64 `json:"memory"`
+	// CPUCores 可使用的CPU核数，可为小数
+	CPUCores float64 `json:"cpu_cores"`
+	// MaxPids 最大进程/线程数
+	MaxPids int64 `json:"max_pids"`
+}
+
+// ResourceUsage 任务执行期间的资源使用情况，仅 cgroup 模式下可采集
+type ResourceUsage struct {
+	// MemoryPeak 内存峰值（字节）
+	MemoryPeak int64 `json:"memory_peak,omitempty"`
+	// OOMKills 因内存超限被内核终止的进程数
+	OOMKills int64 `json:"oom_kills"`
+	// CPUUsage 累计CPU时间
+	CPUUsage time.Duration `json:"cpu_usage"`
+	// ThrottledPeriods 被CPU配额限流的调度周期数
+	ThrottledPeriods int64 `json:"throttled_periods"`
+	// ThrottledTime 被限流的累计时间
+	ThrottledTime time.Duration `json:"throttled_time"`
+}
+
+// resolveLimits 计算任务实际使用的资源限制
+// sandbox.resource_limits 中的配置既是默认值也是上限，override 中非零字段只能在上限内调低
+// 参数: cfg 配置对象, override 任务指定的限制
+// 返回: 资源限制，超出上限时返回错误
+func resolveLimits(cfg *config.AppConfig, override ResourceLimits) (ResourceLimits, error) {
+	var ceiling ResourceLimits
+	if value := cfg.Sandbox.ResourceLimits.Memory; value != "" {
+		memory, err := config.ParseSize(value)
+		if err != nil {
+			return ResourceLimits{}, fmt.Errorf("沙箱内存限制 %q 格式不合法: %v", value, err)
+		}
+		ceiling.Memory = memory
+	}
+	ceiling.CPUCores = float64(cfg.Sandbox.ResourceLimits.CpuCores)
+	ceiling.MaxPids = int64(cfg.Sandbox.ResourceLimits.MaxPids)
+
+	limits := ceiling
+	if override.Memory < 0 || override.CPUCores < 0 || override.MaxPids < 0 {
+		return ResourceLimits{}, fmt.Errorf("资源限制不能为负数")
+	}
+	if override.Memory > 0 {
+		if ceiling.Memory > 0 && override.Memory > ceiling.Memory {
+			return ResourceLimits{}, fmt.Errorf("内存限制 %d 超出上限 %d", override.Memory, ceiling.Memory)
+		}
+		limits.Memory = override.Memory
+	}
+	if override.CPUCores > 0 {
+		if ceiling.CPUCores > 0 && override.CPUCores > ceiling.CPUCores {
+			return ResourceLimits{}, fmt.Errorf("CPU核数 %g 超出上限 %g", override.CPUCores, ceiling.CPUCores)
+		}
+		limits.CPUCores = override.CPUCores
+	}
+	if override.MaxPids > 0 {
+		if ceiling.MaxPids > 0 && override.MaxPids > ceiling.MaxPids {
+			return ResourceLimits{}, fmt.Errorf("进程数限制 %d 超出上限 %d", override.MaxPids, ceiling.MaxPids)
+		}
+		limits.MaxPids = override.MaxPids
+	}
+	return limits, nil
+}
+
+// cpuTimeLimit rlimit 模式下的CPU时间上限（秒）
+// 按执行超时时间内用满全部核数计算，未设置超时或核数时不限制
+func cpuTimeLimit(limits ResourceLimits, timeout time.Duration) uint64 {
+	if limits.CPUCores <= 0 || timeout <= 0 {
+		return 0
+	}
+	seconds := timeout.Seconds() * limits.CPUCores
+	if seconds < 1 {
+		seconds = 1
+	}
+	return uint64(seconds + 0.5)
+}
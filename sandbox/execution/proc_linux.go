@@ -0,0 +1,56 @@
+//go:build linux
+
+package execution
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ProcessStartTime 读取进程的启动时间（系统启动后的时钟滴答数）
+// 与 PID 一起记录，服务重启后据此确认 PID 没有被其他进程复用
+// 参数: pid 进程ID
+// 返回: 启动时间，错误信息
+func ProcessStartTime(pid int) (int64, error) {
+	_, startTime, err := readProcStat(pid)
+	return startTime, err
+}
+
+// ProcessAlive 判断进程是否仍在运行且就是当初启动的任务进程
+// 参数: pid 进程ID, startTime 记录的启动时间（为 0 时不校验）
+func ProcessAlive(pid int, startTime int64) bool {
+	if pid <= 0 {
+		return false
+	}
+	state, actual, err := readProcStat(pid)
+	if err != nil || state == "Z" || state == "X" {
+		return false
+	}
+	return startTime == 0 || actual == startTime
+}
+
+// readProcStat 读取 /proc/<pid>/stat 中的进程状态和启动时间
+func readProcStat(pid int) (string, int64, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return "", 0, err
+	}
+	// 进程名可能包含空格和括号，从最后一个右括号之后开始解析，
+	// 其后第 1 个字段为状态，第 20 个字段为启动时间
+	stat := string(data)
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return "", 0, fmt.Errorf("进程 %d 的状态格式不合法", pid)
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 20 {
+		return "", 0, fmt.Errorf("进程 %d 的状态格式不合法", pid)
+	}
+	startTime, err := strconv.ParseInt(fields[19], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("进程 %d 的启动时间不合法: %v", pid, err)
+	}
+	return fields[0], startTime, nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package execution
+
+import "fmt"
+
+// ProcessStartTime 非 Linux 平台无法读取进程启动时间
+func ProcessStartTime(pid int) (int64, error) {
+	return 0, fmt.Errorf("当前平台不支持读取进程启动时间")
+}
+
+// ProcessAlive 非 Linux 平台无法确认 PID 未被复用，不重新接管任务进程
+func ProcessAlive(pid int, startTime int64) bool {
+	return false
+}
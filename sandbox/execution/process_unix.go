@@ -0,0 +1,38 @@
+//go:build !windows
+
+package execution
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup 让任务进程成为新进程组的组长，便于整体终止其派生的子进程
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// signalProcessGroup 向以 pid 为组长的进程组发送信号，进程组已不存在时忽略
+func signalProcessGroup(pid int, sig syscall.Signal) error {
+	if err := syscall.Kill(-pid, sig); err != nil && err != syscall.ESRCH {
+		return err
+	}
+	return nil
+}
+
+// killedBySeccomp 任务进程是否因违反系统调用过滤策略被终止
+// 进程直接收到 SIGSYS，或由 shell 等父进程以 128+SIGSYS 退出码转述子进程的 SIGSYS
+func killedBySeccomp(state *os.ProcessState) bool {
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok {
+		return false
+	}
+	if status.Signaled() {
+		return status.Signal() == syscall.SIGSYS
+	}
+	return status.Exited() && status.ExitStatus() == 128+int(syscall.SIGSYS)
+}
@@ -0,0 +1,34 @@
+//go:build windows
+
+package execution
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup 在新进程组中启动任务进程
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
+
+// signalProcessGroup Windows 不支持向进程组发送信号，SIGTERM 忽略，SIGKILL 直接结束任务进程
+func signalProcessGroup(pid int, sig syscall.Signal) error {
+	if sig != syscall.SIGKILL {
+		return nil
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return nil
+	}
+	return process.Kill()
+}
+
+// killedBySeccomp Windows 不支持系统调用过滤
+func killedBySeccomp(state *os.ProcessState) bool {
+	return false
+}
@@ -0,0 +1,66 @@
+package execution
+
+// sandboxInitEnv 传递沙盒初始化参数的环境变量
+// 服务进程以该变量启动自身作为初始化子进程，子进程完成设置后替换为任务命令
+const sandboxInitEnv = "FILE_FLOW_SANDBOX_INIT"
+
+// sandboxInitExitCode 沙盒初始化失败时的退出码
+const sandboxInitExitCode = 126
+
+// rlimitSpec 单项 setrlimit 设置
+type rlimitSpec struct {
+	Resource int    `json:"resource"`
+	Value    uint64 `json:"value"`
+}
+
+// mountSpec 绑定挂载，Target 为相对新根目录的绝对路径
+type mountSpec struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"read_only"`
+}
+
+// credentialSpec 任务进程的运行身份
+type credentialSpec struct {
+	UID uint32 `json:"uid"`
+	GID uint32 `json:"gid"`
+}
+
+// namespaceSpec namespace 隔离模式的设置
+// 初始化子进程在新的 user/mount/pid/ipc（及 network）命名空间中启动，
+// 命名空间内的 root 映射为宿主上的 UID/GID
+type namespaceSpec struct {
+	UID uint32 `json:"uid"`
+	GID uint32 `json:"gid"`
+	// AllowNetwork 为 true 时不创建新的网络命名空间
+	AllowNetwork bool `json:"allow_network"`
+	// TmpSize 私有 /tmp 的大小（字节），0 表示使用 tmpfs 默认大小
+	TmpSize int64 `json:"tmp_size"`
+}
+
+// sandboxInitSpec 沙盒初始化子进程的启动参数
+// 子进程依次完成挂载与 pivot_root、资源限制、放弃能力与切换用户、安装系统调用过滤，最后执行任务命令
+type sandboxInitSpec struct {
+	Path       string          `json:"path"`
+	Args       []string        `json:"args"`
+	Env        []string        `json:"env"` // 任务命令的环境变量，初始化子进程自身不继承服务进程的环境变量
+	Root       string          `json:"root,omitempty"`
+	Mounts     []mountSpec     `json:"mounts,omitempty"`
+	Rlimits    []rlimitSpec    `json:"rlimits,omitempty"`
+	Credential *credentialSpec `json:"credential,omitempty"`
+	Namespace  *namespaceSpec  `json:"namespace,omitempty"`
+	// Seccomp 执行任务命令前安装的系统调用过滤策略
+	Seccomp string `json:"seccomp,omitempty"`
+	// Probe 只完成设置不执行命令，用于启动时检查隔离能否生效
+	Probe bool `json:"probe,omitempty"`
+
+	// mountpoints 服务端为绑定挂载新建的挂载点，任务结束后清理，不传给子进程
+	mountpoints []string
+	// wrapped 任务命令是否已改为经由初始化子进程启动
+	wrapped bool
+}
+
+// needed 是否需要经由初始化子进程启动
+func (s *sandboxInitSpec) needed() bool {
+	return s.Root != "" || len(s.Rlimits) > 0 || s.Credential != nil || s.Namespace != nil || s.Seccomp != ""
+}
@@ -0,0 +1,82 @@
+package execution
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"file-flow-service/config"
+)
+
+// 系统调用过滤策略，违反策略的进程会被内核以 SIGSYS 终止
+const (
+	// SeccompNone 不过滤系统调用
+	SeccompNone = "none"
+	// SeccompPermissive 仅禁止加载内核模块、重启、修改系统时间、挂载等影响宿主的调用
+	SeccompPermissive = "permissive"
+	// SeccompDefault 在 permissive 基础上禁止 ptrace、keyctl、bpf、创建命名空间等调用
+	SeccompDefault = "default"
+	// SeccompStrict 在 default 基础上禁止创建 Unix 域以外的网络套接字
+	SeccompStrict = "strict"
+)
+
+// 任务失败原因
+const (
+	FailureNonZeroExit      = "non_zero_exit"
+	FailureOutOfMemory      = "out_of_memory"
+	FailureSeccompViolation = "seccomp_violation"
+)
+
+// resolveSeccompProfile 计算任务使用的系统调用过滤策略
+// 运行环境类型配置了 seccomp_profile 时优先使用，否则使用 sandbox.seccomp.profile
+// 参数: cfg 配置对象, envType 运行环境类型
+// 返回: 过滤策略，不过滤时返回空字符串
+func resolveSeccompProfile(cfg *config.AppConfig, envType string) string {
+	profile := cfg.Sandbox.Seccomp.Profile
+	if envType != "" {
+		if envProfile := cfg.Sandbox.Environments.Runtime(envType).SeccompProfile; envProfile != "" {
+			profile = envProfile
+		}
+	}
+	if profile == SeccompNone {
+		return ""
+	}
+	return profile
+}
+
+// configuredSeccompProfiles 返回配置中用到的全部过滤策略（去重，不含 none）
+func configuredSeccompProfiles(cfg *config.AppConfig) []string {
+	seen := make(map[string]bool)
+	var profiles []string
+	for _, envType := range append([]string{""}, cfg.Sandbox.Environments.RuntimeNames()...) {
+		profile := resolveSeccompProfile(cfg, envType)
+		if profile != "" && !seen[profile] {
+			seen[profile] = true
+			profiles = append(profiles, profile)
+		}
+	}
+	return profiles
+}
+
+// probeSeccomp 以探测模式启动初始化子进程安装过滤策略，确认内核支持 seccomp 过滤
+// 参数: profile 过滤策略
+func probeSeccomp(profile string) error {
+	if err := checkSeccompSupported(profile); err != nil {
+		return err
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("获取服务程序路径失败: %v", err)
+	}
+	cmd := exec.Command(self)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := wrapWithSandboxInit(cmd, sandboxInitSpec{Seccomp: profile, Probe: true}); err != nil {
+		return err
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("系统调用过滤策略 %s 探测失败: %v: %s", profile, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+//go:build linux
+
+package execution
+
+import "golang.org/x/sys/unix"
+
+// seccompAuditArch 过滤策略只接受本架构的系统调用
+const seccompAuditArch = unix.AUDIT_ARCH_X86_64
+
+// seccompSyscallBit x32 ABI 的系统调用编号标志位
+const seccompSyscallBit = 0x40000000
+
+// archPermissiveDeniedSyscalls 本架构特有、permissive 策略禁止的系统调用
+var archPermissiveDeniedSyscalls = []uint32{
+	unix.SYS_IOPL, unix.SYS_IOPERM, unix.SYS_USELIB, unix.SYS_CREATE_MODULE,
+}
+
+// archStrictDeniedSyscalls 本架构特有、strict 策略禁止的系统调用
+var archStrictDeniedSyscalls = []uint32{
+	unix.SYS_MKNOD,
+}
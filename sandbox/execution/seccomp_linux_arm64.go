@@ -0,0 +1,15 @@
+//go:build linux
+
+package execution
+
+import "golang.org/x/sys/unix"
+
+// seccompAuditArch 过滤策略只接受本架构的系统调用
+const seccompAuditArch = unix.AUDIT_ARCH_AARCH64
+
+// seccompSyscallBit 本架构只有一套系统调用编号
+const seccompSyscallBit = 0
+
+var archPermissiveDeniedSyscalls []uint32
+
+var archStrictDeniedSyscalls []uint32
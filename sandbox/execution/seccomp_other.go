@@ -0,0 +1,15 @@
+//go:build !linux || !(amd64 || arm64)
+
+package execution
+
+import "fmt"
+
+// installSeccomp 当前平台不支持系统调用过滤
+func installSeccomp(profile string) error {
+	return fmt.Errorf("当前平台不支持系统调用过滤")
+}
+
+// checkSeccompSupported 当前平台不支持系统调用过滤
+func checkSeccompSupported(profile string) error {
+	return fmt.Errorf("当前平台不支持系统调用过滤策略 %q", profile)
+}
@@ -14,12 +14,12 @@ import (
 )
 
 type Instance struct {
-	Config      config.AppConfig
+	Config      *config.AppConfig
 	Logger      logger.Logger
 	LockManager *FileLockManager // 新增锁管理器
 }
 
-func NewInstance(cfg config.AppConfig, logger logger.Logger) *Instance {
+func NewInstance(cfg *config.AppConfig, logger logger.Logger) *Instance {
 	return &Instance{
 		Config:      cfg,
 		Logger:      logger,
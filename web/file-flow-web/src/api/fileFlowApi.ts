@@ -224,7 +224,7 @@ export const TaskApi = {
    * POST /api/tasks
    * {
    *   "id": "task_456",
-   *   "status": "completed"
+   *   "status": "cancelled"
    * }
    *
    * 示例响应:
@@ -1,12 +1,27 @@
 package web
 
 import (
+	"errors"
+	"file-flow-service/config"
+	"file-flow-service/database"
+	"file-flow-service/file"
 	"file-flow-service/internal/service"
+	"file-flow-service/internal/service/interfaces"
+	"file-flow-service/internal/workflow"
+	"file-flow-service/internal/scheduler"
+	"file-flow-service/internal/threadpool"
 	"file-flow-service/utils/logger"
 	"net/http"
 	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// maxFormMemory 解析 multipart 表单时保存在内存中的最大字节数，超出部分写入临时文件
+const maxFormMemory = 32 << 20
+
 type WebInterface struct {
 	logger logger.Logger
 	service *service.Service
@@ -19,11 +34,24 @@ func NewWebInterface(service *service.Service, logger logger.Logger) *WebInterfa
 	}
 }
 
-func (w *WebInterface) SetupAllRoutes() http.Handler {
+// SetupAllRoutes 在默认路由上注册全部接口，需在 StartServer 之前调用且只能调用一次
+func (w *WebInterface) SetupAllRoutes() {
 	http.HandleFunc("/api/upload", w.HandleUpload)
 	http.HandleFunc("/api/execute", w.HandleExecute)
 	http.HandleFunc("/api/status", w.HandleStatus)
-	return nil
+	http.HandleFunc("/api/tasks", w.HandleTasks)
+	http.HandleFunc("/api/tasks/position", w.HandleTaskPosition)
+	http.HandleFunc("/api/tasks/attempts", w.HandleTaskAttempts)
+	http.HandleFunc("/api/tasks/events", w.HandleTaskEvents)
+	http.HandleFunc("/api/workflows", w.HandleWorkflows)
+	http.HandleFunc("/api/schedules", w.HandleSchedules)
+	http.HandleFunc("/api/schedules/pause", w.HandleSchedulePause)
+	http.HandleFunc("/api/schedules/delete", w.HandleScheduleDelete)
+	http.HandleFunc("/api/schedules/runs", w.HandleScheduleRuns)
+	http.HandleFunc("/api/environments", w.HandleEnvironments)
+	http.HandleFunc("/api/environments/default", w.HandleEnvironmentDefault)
+	http.HandleFunc("/api/environments/uninstall", w.HandleEnvironmentUninstall)
+	http.HandleFunc("/api/environments/gc", w.HandleEnvironmentGC)
 }
 
 func (w *WebInterface) HandleUpload(rw http.ResponseWriter, r *http.Request) {
@@ -35,33 +63,157 @@ func (w *WebInterface) HandleUpload(rw http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	fileName, err := w.service.UploadFile(fileHeader)
+	uploadID, err := w.service.UploadFile(fileHeader)
 	if err != nil {
 		w.logger.Error("文件上传处理失败: " + err.Error())
 		http.Error(rw, "文件处理失败", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteJSON(rw, map[string]string{"file": fileName})
+	// 执行请求的 files 参数和工作流节点的 files 字段按 upload_id 引用该文件
+	w.WriteJSON(rw, map[string]string{"file": fileHeader.Filename, "upload_id": uploadID})
 }
 
 func (w *WebInterface) HandleExecute(rw http.ResponseWriter, r *http.Request) {
-	cmd := r.FormValue("cmd")
-	args := r.FormValue("args")
-	if cmd == "" {
-		w.logger.Error("命令参数缺失")
-		http.Error(rw, "缺少命令", http.StatusBadRequest)
+	// 先解析表单再读取参数，args、retry_on 等可重复参数直接读取 r.Form
+	if err := r.ParseMultipartForm(maxFormMemory); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		w.logger.Error("解析请求参数失败: " + err.Error())
+		http.Error(rw, "请求参数格式不合法", http.StatusBadRequest)
 		return
 	}
 
-	err := w.service.ExecuteCommand(cmd, []string{args})
+	// cmd 和 env_type 都可省略，由执行器根据任务文件自动识别，显式指定时以请求为准
+	req := interfaces.ExecuteRequest{
+		Cmd:        r.FormValue("cmd"),
+		Args:       r.Form["args"],
+		EnvType:    r.FormValue("env_type"),
+		EnvVersion: r.FormValue("env_version"),
+		Name:       r.FormValue("name"),
+		Creator:    r.FormValue("creator"),
+		Priority:   r.FormValue("priority"),
+	}
+	// files 为 /api/upload 返回的上传ID，可重复或以逗号分隔
+	for _, value := range r.Form["files"] {
+		for _, uploadID := range strings.Split(value, ",") {
+			if uploadID = strings.TrimSpace(uploadID); uploadID != "" {
+				req.Files = append(req.Files, uploadID)
+			}
+		}
+	}
+	if memory := r.FormValue("expected_memory"); memory != "" {
+		size, err := config.ParseSize(memory)
+		if err != nil {
+			http.Error(rw, "预计内存格式不合法", http.StatusBadRequest)
+			return
+		}
+		req.ExpectedMemory = size
+	}
+	if memory := r.FormValue("memory_limit"); memory != "" {
+		size, err := config.ParseSize(memory)
+		if err != nil {
+			http.Error(rw, "内存限制格式不合法", http.StatusBadRequest)
+			return
+		}
+		req.MemoryLimit = size
+	}
+	if cores := r.FormValue("cpu_cores"); cores != "" {
+		value, err := strconv.ParseFloat(cores, 64)
+		if err != nil {
+			http.Error(rw, "CPU核数格式不合法", http.StatusBadRequest)
+			return
+		}
+		req.CPUCores = value
+	}
+	if pids := r.FormValue("max_pids"); pids != "" {
+		value, err := strconv.ParseInt(pids, 10, 64)
+		if err != nil {
+			http.Error(rw, "进程数限制格式不合法", http.StatusBadRequest)
+			return
+		}
+		req.MaxPids = value
+	}
+	if network := r.FormValue("allow_network"); network != "" {
+		value, err := strconv.ParseBool(network)
+		if err != nil {
+			http.Error(rw, "网络访问开关格式不合法", http.StatusBadRequest)
+			return
+		}
+		req.AllowNetwork = value
+	}
+	if idempotent := r.FormValue("idempotent"); idempotent != "" {
+		value, err := strconv.ParseBool(idempotent)
+		if err != nil {
+			http.Error(rw, "幂等标记格式不合法", http.StatusBadRequest)
+			return
+		}
+		req.Idempotent = value
+	}
+	if attempts := r.FormValue("max_attempts"); attempts != "" {
+		value, err := strconv.Atoi(attempts)
+		if err != nil || value < 1 {
+			http.Error(rw, "最多执行次数格式不合法", http.StatusBadRequest)
+			return
+		}
+		req.MaxAttempts = value
+	}
+	for name, target := range map[string]*time.Duration{
+		"retry_backoff":     &req.RetryBackoff,
+		"retry_backoff_max": &req.RetryBackoffMax,
+	} {
+		value := r.FormValue(name)
+		if value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration <= 0 {
+			http.Error(rw, name+" 格式不合法", http.StatusBadRequest)
+			return
+		}
+		*target = duration
+	}
+	if jitter := r.FormValue("retry_jitter"); jitter != "" {
+		value, err := strconv.ParseFloat(jitter, 64)
+		if err != nil || value < 0 || value > 1 {
+			http.Error(rw, "重试等待时间浮动比例应在 0 到 1 之间", http.StatusBadRequest)
+			return
+		}
+		req.RetryJitter = value
+	}
+	// retry_on 可重复或以逗号分隔
+	for _, value := range r.Form["retry_on"] {
+		for _, reason := range strings.Split(value, ",") {
+			if reason = strings.TrimSpace(reason); reason == "" {
+				continue
+			}
+			valid := false
+			for _, allowed := range config.RetryReasons {
+				valid = valid || reason == allowed
+			}
+			if !valid {
+				http.Error(rw, "不支持的重试条件: "+reason, http.StatusBadRequest)
+				return
+			}
+			req.RetryOn = append(req.RetryOn, reason)
+		}
+	}
+
+	taskID, err := w.service.ExecuteCommand(req)
 	if err != nil {
 		w.logger.Error("命令执行失败: " + err.Error())
+		// 队列已满属于暂时不可用，客户端可稍后重试
+		if errors.Is(err, threadpool.ErrQueueFull) {
+			http.Error(rw, "任务队列已满，请稍后重试", http.StatusServiceUnavailable)
+			return
+		}
+		if errors.Is(err, file.ErrUploadNotFound) {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(rw, "命令执行失败", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteJSON(rw, map[string]string{"status": "success"})
+	w.WriteJSON(rw, map[string]string{"status": "success", "task_id": taskID})
 }
 
 func (w *WebInterface) HandleStatus(rw http.ResponseWriter, r *http.Request) {
@@ -69,6 +221,400 @@ func (w *WebInterface) HandleStatus(rw http.ResponseWriter, r *http.Request) {
 	w.WriteJSON(rw, map[string]string{"status": status})
 }
 
+func (w *WebInterface) HandleTasks(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(rw, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// status 可重复或以逗号分隔；时间参数为 Unix 秒或 RFC3339 格式
+	params := r.URL.Query()
+	query := interfaces.TaskQuery{
+		Creator:      params.Get("creator"),
+		AssignedTo:   params.Get("assigned_to"),
+		NameContains: params.Get("name"),
+		Runtime:      params.Get("runtime"),
+		Sort:         params.Get("sort"),
+		Order:        params.Get("order"),
+		Cursor:       params.Get("cursor"),
+	}
+	for _, value := range params["status"] {
+		for _, status := range strings.Split(value, ",") {
+			if status = strings.TrimSpace(status); status != "" {
+				query.Statuses = append(query.Statuses, status)
+			}
+		}
+	}
+	if size := params.Get("size"); size != "" {
+		value, err := strconv.Atoi(size)
+		if err != nil || value < 0 {
+			http.Error(rw, "每页数量格式不合法", http.StatusBadRequest)
+			return
+		}
+		query.Size = value
+	}
+	if page := params.Get("page"); page != "" {
+		value, err := strconv.Atoi(page)
+		if err != nil || value < 1 {
+			http.Error(rw, "页码格式不合法", http.StatusBadRequest)
+			return
+		}
+		query.Page = value
+	}
+	for name, target := range map[string]*int64{
+		"created_after":   &query.CreatedAfter,
+		"created_before":  &query.CreatedBefore,
+		"finished_after":  &query.FinishedAfter,
+		"finished_before": &query.FinishedBefore,
+	} {
+		value := params.Get(name)
+		if value == "" {
+			continue
+		}
+		seconds, err := parseTimeParam(value)
+		if err != nil {
+			http.Error(rw, name+" 时间格式不合法", http.StatusBadRequest)
+			return
+		}
+		*target = seconds
+	}
+
+	tasks, err := w.service.ListTasks(query)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidTaskFilter) {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.logger.Error("查询任务列表失败: " + err.Error())
+		http.Error(rw, "查询任务列表失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteJSON(rw, tasks)
+}
+
+// parseTimeParam 解析 Unix 秒或 RFC3339 格式的时间参数
+func parseTimeParam(value string) (int64, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return seconds, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, err
+	}
+	return t.Unix(), nil
+}
+
+func (w *WebInterface) HandleTaskPosition(rw http.ResponseWriter, r *http.Request) {
+	taskID := r.URL.Query().Get("id")
+	if taskID == "" {
+		http.Error(rw, "缺少任务ID", http.StatusBadRequest)
+		return
+	}
+
+	position, err := w.service.GetTaskQueuePosition(taskID)
+	if err != nil {
+		w.logger.Error("查询任务排队位置失败: " + err.Error())
+		http.Error(rw, "任务不存在", http.StatusNotFound)
+		return
+	}
+
+	w.WriteJSON(rw, map[string]interface{}{"id": taskID, "position": position})
+}
+
+func (w *WebInterface) HandleTaskAttempts(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(rw, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	taskID := r.URL.Query().Get("id")
+	if taskID == "" {
+		http.Error(rw, "缺少任务ID", http.StatusBadRequest)
+		return
+	}
+
+	attempts, err := w.service.GetTaskAttempts(taskID)
+	if err != nil {
+		w.logger.Error("查询任务执行记录失败: " + err.Error())
+		http.Error(rw, "查询任务执行记录失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteJSON(rw, map[string]interface{}{"id": taskID, "items": attempts, "total": len(attempts)})
+}
+
+// HandleTaskEvents GET 按发生顺序查询任务的状态转换事件
+func (w *WebInterface) HandleTaskEvents(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(rw, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	taskID := r.URL.Query().Get("id")
+	if taskID == "" {
+		http.Error(rw, "缺少任务ID", http.StatusBadRequest)
+		return
+	}
+
+	events, err := w.service.GetTaskEvents(taskID)
+	if err != nil {
+		w.logger.Error("查询任务状态转换事件失败: " + err.Error())
+		http.Error(rw, "查询任务状态转换事件失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteJSON(rw, map[string]interface{}{"id": taskID, "items": events, "total": len(events)})
+}
+
+// maxWorkflowDefinitionSize 工作流定义的最大字节数
+const maxWorkflowDefinitionSize = 1 << 20
+
+// HandleWorkflows POST 提交工作流（请求体为 YAML 或 JSON 格式的定义），GET 按 id 查询工作流状态
+func (w *WebInterface) HandleWorkflows(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		definition, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, maxWorkflowDefinitionSize))
+		if err != nil {
+			http.Error(rw, "读取工作流定义失败", http.StatusBadRequest)
+			return
+		}
+		workflowID, err := w.service.SubmitWorkflow(definition, r.URL.Query().Get("creator"))
+		if err != nil {
+			if errors.Is(err, workflow.ErrInvalidDefinition) {
+				http.Error(rw, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.logger.Error("提交工作流失败: " + err.Error())
+			http.Error(rw, "提交工作流失败", http.StatusInternalServerError)
+			return
+		}
+		w.WriteJSON(rw, map[string]string{"status": "success", "workflow_id": workflowID})
+	case http.MethodGet:
+		workflowID := r.URL.Query().Get("id")
+		if workflowID == "" {
+			http.Error(rw, "缺少工作流ID", http.StatusBadRequest)
+			return
+		}
+		wf, err := w.service.GetWorkflow(workflowID)
+		if err != nil {
+			if errors.Is(err, database.ErrWorkflowNotFound) {
+				http.Error(rw, "工作流不存在", http.StatusNotFound)
+				return
+			}
+			w.logger.Error("查询工作流失败: " + err.Error())
+			http.Error(rw, "查询工作流失败", http.StatusInternalServerError)
+			return
+		}
+		w.WriteJSON(rw, wf)
+	default:
+		http.Error(rw, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
+
+// maxScheduleDefinitionSize 定时任务定义（含脚本）的最大字节数
+const maxScheduleDefinitionSize = 1 << 20
+
+// HandleSchedules POST 创建定时任务（请求体为 JSON 格式的定义），GET 查询全部定时任务
+func (w *WebInterface) HandleSchedules(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		definition, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, maxScheduleDefinitionSize))
+		if err != nil {
+			http.Error(rw, "读取定时任务定义失败", http.StatusBadRequest)
+			return
+		}
+		schedule, err := w.service.CreateSchedule(definition, r.URL.Query().Get("creator"))
+		if err != nil {
+			if errors.Is(err, scheduler.ErrInvalidDefinition) {
+				http.Error(rw, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.logger.Error("创建定时任务失败: " + err.Error())
+			http.Error(rw, "创建定时任务失败", http.StatusInternalServerError)
+			return
+		}
+		w.WriteJSON(rw, schedule)
+	case http.MethodGet:
+		schedules, err := w.service.ListSchedules()
+		if err != nil {
+			w.logger.Error("查询定时任务失败: " + err.Error())
+			http.Error(rw, "查询定时任务失败", http.StatusInternalServerError)
+			return
+		}
+		w.WriteJSON(rw, map[string]interface{}{"items": schedules, "total": len(schedules)})
+	default:
+		http.Error(rw, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleSchedulePause 暂停（paused=true，默认）或恢复（paused=false）定时任务
+func (w *WebInterface) HandleSchedulePause(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	scheduleID := r.FormValue("id")
+	if scheduleID == "" {
+		http.Error(rw, "缺少定时任务ID", http.StatusBadRequest)
+		return
+	}
+	paused := true
+	if value := r.FormValue("paused"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			http.Error(rw, "暂停标记格式不合法", http.StatusBadRequest)
+			return
+		}
+		paused = parsed
+	}
+
+	if err := w.service.PauseSchedule(scheduleID, paused); err != nil {
+		if errors.Is(err, database.ErrScheduleNotFound) {
+			http.Error(rw, "定时任务不存在", http.StatusNotFound)
+			return
+		}
+		w.logger.Error("更新定时任务失败: " + err.Error())
+		http.Error(rw, "更新定时任务失败", http.StatusInternalServerError)
+		return
+	}
+	w.WriteJSON(rw, map[string]interface{}{"status": "success", "id": scheduleID, "paused": paused})
+}
+
+// HandleScheduleDelete 删除定时任务，已提交的任务继续执行
+func (w *WebInterface) HandleScheduleDelete(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	scheduleID := r.FormValue("id")
+	if scheduleID == "" {
+		http.Error(rw, "缺少定时任务ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := w.service.DeleteSchedule(scheduleID); err != nil {
+		if errors.Is(err, database.ErrScheduleNotFound) {
+			http.Error(rw, "定时任务不存在", http.StatusNotFound)
+			return
+		}
+		w.logger.Error("删除定时任务失败: " + err.Error())
+		http.Error(rw, "删除定时任务失败", http.StatusInternalServerError)
+		return
+	}
+	w.WriteJSON(rw, map[string]string{"status": "success", "id": scheduleID})
+}
+
+// defaultScheduleRunLimit 未指定 limit 时返回的执行记录条数
+const defaultScheduleRunLimit = 50
+
+// HandleScheduleRuns GET 查询定时任务最近的执行记录，包括跳过和错过的执行
+func (w *WebInterface) HandleScheduleRuns(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(rw, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	scheduleID := query.Get("id")
+	if scheduleID == "" {
+		http.Error(rw, "缺少定时任务ID", http.StatusBadRequest)
+		return
+	}
+	limit := defaultScheduleRunLimit
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			http.Error(rw, "limit 格式不合法", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	runs, err := w.service.GetScheduleRuns(scheduleID, limit)
+	if err != nil {
+		if errors.Is(err, database.ErrScheduleNotFound) {
+			http.Error(rw, "定时任务不存在", http.StatusNotFound)
+			return
+		}
+		w.logger.Error("查询定时任务执行记录失败: " + err.Error())
+		http.Error(rw, "查询定时任务执行记录失败", http.StatusInternalServerError)
+		return
+	}
+	w.WriteJSON(rw, map[string]interface{}{"id": scheduleID, "items": runs, "total": len(runs)})
+}
+
+func (w *WebInterface) HandleEnvironments(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(rw, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	envs, err := w.service.ListEnvironments()
+	if err != nil {
+		w.logger.Error("查询运行环境失败: " + err.Error())
+		http.Error(rw, "查询运行环境失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteJSON(rw, map[string]interface{}{"items": envs, "total": len(envs)})
+}
+
+func (w *WebInterface) HandleEnvironmentDefault(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	envType := r.FormValue("env_type")
+	version := r.FormValue("version")
+	if envType == "" || version == "" {
+		http.Error(rw, "缺少环境类型或版本", http.StatusBadRequest)
+		return
+	}
+
+	if err := w.service.SetDefaultEnvironment(envType, version); err != nil {
+		w.logger.Error("设置默认运行环境失败: " + err.Error())
+		http.Error(rw, "设置默认版本失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteJSON(rw, map[string]string{"env_type": envType, "default_version": version})
+}
+
+func (w *WebInterface) HandleEnvironmentUninstall(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	envType := r.FormValue("env_type")
+	version := r.FormValue("version")
+	if envType == "" || version == "" {
+		http.Error(rw, "缺少环境类型或版本", http.StatusBadRequest)
+		return
+	}
+
+	if err := w.service.UninstallEnvironment(envType, version); err != nil {
+		w.logger.Error("卸载运行环境失败: " + err.Error())
+		http.Error(rw, "卸载失败: "+err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.WriteJSON(rw, map[string]string{"status": "success", "env_type": envType, "version": version})
+}
+
+func (w *WebInterface) HandleEnvironmentGC(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	report, err := w.service.CollectEnvironmentGarbage()
+	if err != nil {
+		w.logger.Error("回收运行环境缓存失败: " + err.Error())
+		http.Error(rw, "回收失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteJSON(rw, report)
+}
+
 func (w *WebInterface) WriteJSON(rw http.ResponseWriter, data interface{}) {
 	rw.Header().Set("Content-Type", "application/json")
 